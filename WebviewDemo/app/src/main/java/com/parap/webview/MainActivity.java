@@ -1,11 +1,19 @@
 package com.parap.webview;
 
+import android.Manifest;
+import android.content.pm.ApplicationInfo;
+import android.content.pm.PackageManager;
 import android.net.http.SslError;
 import android.os.Build;
 import android.os.Bundle;
+import android.os.SystemClock;
 import android.view.View;
+import android.view.ViewTreeObserver;
 import android.view.WindowManager;
+import android.webkit.GeolocationPermissions;
+import android.webkit.PermissionRequest;
 import android.webkit.SslErrorHandler;
+import android.webkit.WebChromeClient;
 import android.webkit.WebResourceRequest;
 import android.webkit.WebSettings;
 import android.webkit.WebView;
@@ -14,13 +22,40 @@ import android.webkit.WebViewClient;
 import androidx.appcompat.app.AppCompatActivity;
 import androidx.swiperefreshlayout.widget.SwipeRefreshLayout;
 
+import org.json.JSONException;
+import org.json.JSONObject;
+
 import java.io.BufferedReader;
 import java.io.IOException;
 import java.io.InputStreamReader;
+import java.util.ArrayList;
+import java.util.List;
 
 public class MainActivity extends AppCompatActivity {
     private WebView webView;
     private SwipeRefreshLayout swipeRefreshLayout;
+    // apkEditor写入的assets/webview.json, 没有时使用默认值
+    private JSONObject config = new JSONObject();
+    private static final int REQUEST_PERMISSIONS = 1;
+    // 等待运行时权限结果的网页请求
+    private PermissionRequest pendingRequest;
+    private GeolocationPermissions.Callback pendingGeolocation;
+    private String pendingOrigin;
+
+    private void loadConfig() {
+        StringBuilder json = new StringBuilder();
+        try {
+            BufferedReader reader = new BufferedReader(new InputStreamReader(getAssets().open("webview.json")));
+            String line;
+            while ((line = reader.readLine()) != null) {
+                json.append(line);
+            }
+            reader.close();
+            config = new JSONObject(json.toString());
+        } catch (IOException | JSONException e) {
+            // 使用默认配置
+        }
+    }
 
     private void loadWebPage() {
         // 首先尝试读取url.txt
@@ -79,30 +114,38 @@ public class MainActivity extends AppCompatActivity {
     @Override
     protected void onCreate(Bundle savedInstanceState) {
         super.onCreate(savedInstanceState);
-        
-        // 设置全屏并使用刘海区域
-        if (Build.VERSION.SDK_INT >= Build.VERSION_CODES.P) {
-            WindowManager.LayoutParams lp = getWindow().getAttributes();
-            lp.layoutInDisplayCutoutMode = WindowManager.LayoutParams.LAYOUT_IN_DISPLAY_CUTOUT_MODE_SHORT_EDGES;
-            getWindow().setAttributes(lp);
+        loadConfig();
+
+        if (config.optBoolean("fullscreen", true)) {
+            // 设置全屏并使用刘海区域
+            if (Build.VERSION.SDK_INT >= Build.VERSION_CODES.P) {
+                WindowManager.LayoutParams lp = getWindow().getAttributes();
+                lp.layoutInDisplayCutoutMode = WindowManager.LayoutParams.LAYOUT_IN_DISPLAY_CUTOUT_MODE_SHORT_EDGES;
+                getWindow().setAttributes(lp);
+            }
+
+            // 隐藏系统UI
+            getWindow().getDecorView().setSystemUiVisibility(
+                View.SYSTEM_UI_FLAG_LAYOUT_STABLE
+                | View.SYSTEM_UI_FLAG_LAYOUT_HIDE_NAVIGATION
+                | View.SYSTEM_UI_FLAG_LAYOUT_FULLSCREEN
+                | View.SYSTEM_UI_FLAG_HIDE_NAVIGATION
+                | View.SYSTEM_UI_FLAG_FULLSCREEN
+                | View.SYSTEM_UI_FLAG_IMMERSIVE_STICKY);
         }
-        
-        // 隐藏系统UI
-        getWindow().getDecorView().setSystemUiVisibility(
-            View.SYSTEM_UI_FLAG_LAYOUT_STABLE
-            | View.SYSTEM_UI_FLAG_LAYOUT_HIDE_NAVIGATION
-            | View.SYSTEM_UI_FLAG_LAYOUT_FULLSCREEN
-            | View.SYSTEM_UI_FLAG_HIDE_NAVIGATION
-            | View.SYSTEM_UI_FLAG_FULLSCREEN
-            | View.SYSTEM_UI_FLAG_IMMERSIVE_STICKY);
             
+        // debuggable 的构建允许 chrome://inspect 远程调试 WebView
+        if ((getApplicationInfo().flags & ApplicationInfo.FLAG_DEBUGGABLE) != 0) {
+            WebView.setWebContentsDebuggingEnabled(true);
+        }
         setContentView(R.layout.activity_main);
+        keepSplash(config.optLong("splash_min_duration", 0));
 
         webView = findViewById(R.id.webview);
         swipeRefreshLayout = findViewById(R.id.swipeRefresh);
 
         WebSettings settings = webView.getSettings();
-        settings.setJavaScriptEnabled(true);
+        settings.setJavaScriptEnabled(config.optBoolean("javascript", true));
         // 允许访问文件
         settings.setAllowFileAccess(true);
         // 设置可以访问assets目录
@@ -114,7 +157,7 @@ public class MainActivity extends AppCompatActivity {
         settings.setDatabaseEnabled(true);
         settings.setLoadsImagesAutomatically(true);
         // 完全禁用 SSL 证书检查
-        settings.setUserAgentString("Mozilla/5.0 (Linux; Android 10; Mobile) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.120 Mobile Safari/537.36");
+        settings.setUserAgentString(config.optString("user_agent", "Mozilla/5.0 (Linux; Android 10; Mobile) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.120 Mobile Safari/537.36"));
         if (Build.VERSION.SDK_INT >= Build.VERSION_CODES.LOLLIPOP) {
             settings.setMixedContentMode(WebSettings.MIXED_CONTENT_ALWAYS_ALLOW);
         }
@@ -124,11 +167,44 @@ public class MainActivity extends AppCompatActivity {
         settings.setLoadWithOverviewMode(true);
         settings.setUseWideViewPort(true);
         settings.setSupportMultipleWindows(true);
-        settings.setBuiltInZoomControls(true);
+        settings.setBuiltInZoomControls(config.optBoolean("zoom", true));
         settings.setDisplayZoomControls(false);
         settings.setSaveFormData(true);
         settings.setAppCacheEnabled(true);
         settings.setCacheMode(WebSettings.LOAD_DEFAULT);
+        settings.setGeolocationEnabled(true);
+
+        // 网页申请摄像头/麦克风/定位时, 申请 apkEditor -permission 写入manifest的运行时权限
+        webView.setWebChromeClient(new WebChromeClient() {
+            @Override
+            public void onPermissionRequest(PermissionRequest request) {
+                List<String> needed = new ArrayList<>();
+                for (String resource : request.getResources()) {
+                    if (PermissionRequest.RESOURCE_VIDEO_CAPTURE.equals(resource)) {
+                        needed.add(Manifest.permission.CAMERA);
+                    } else if (PermissionRequest.RESOURCE_AUDIO_CAPTURE.equals(resource)) {
+                        needed.add(Manifest.permission.RECORD_AUDIO);
+                    }
+                }
+                pendingRequest = request;
+                if (!requestRuntimePermissions(needed)) {
+                    request.grant(request.getResources());
+                    pendingRequest = null;
+                }
+            }
+
+            @Override
+            public void onGeolocationPermissionsShowPrompt(String origin, GeolocationPermissions.Callback callback) {
+                List<String> needed = new ArrayList<>();
+                needed.add(Manifest.permission.ACCESS_FINE_LOCATION);
+                pendingOrigin = origin;
+                pendingGeolocation = callback;
+                if (!requestRuntimePermissions(needed)) {
+                    callback.invoke(origin, true, false);
+                    pendingGeolocation = null;
+                }
+            }
+        });
         
         webView.setWebViewClient(new WebViewClient() {
             @Override
@@ -148,8 +224,12 @@ public class MainActivity extends AppCompatActivity {
 
             @Override
             public void onReceivedSslError(WebView view, SslErrorHandler handler, SslError error) {
-                // 完全忽略所有SSL证书错误
-                handler.proceed();
+                // 默认完全忽略所有SSL证书错误
+                if (config.optBoolean("ignore_ssl_errors", true)) {
+                    handler.proceed();
+                } else {
+                    handler.cancel();
+                }
             }
 
 
@@ -159,6 +239,7 @@ public class MainActivity extends AppCompatActivity {
             }
         });
         
+        swipeRefreshLayout.setEnabled(config.optBoolean("swipe_refresh", true));
         // 设置下拉刷新的监听器
         swipeRefreshLayout.setOnRefreshListener(new SwipeRefreshLayout.OnRefreshListener() {
             @Override
@@ -171,6 +252,67 @@ public class MainActivity extends AppCompatActivity {
         loadWebPage();
     }
 
+    // keepSplash 推迟第一次绘制, 使主题的启动画面(windowBackground / Android 12 的系统启动画面)至少显示 minDuration 毫秒
+    private void keepSplash(long minDuration) {
+        if (minDuration <= 0) {
+            return;
+        }
+        final long end = SystemClock.uptimeMillis() + minDuration;
+        final View content = findViewById(android.R.id.content);
+        content.getViewTreeObserver().addOnPreDrawListener(new ViewTreeObserver.OnPreDrawListener() {
+            @Override
+            public boolean onPreDraw() {
+                if (SystemClock.uptimeMillis() < end) {
+                    return false;
+                }
+                content.getViewTreeObserver().removeOnPreDrawListener(this);
+                return true;
+            }
+        });
+    }
+
+    // requestRuntimePermissions 申请还没有授予的权限, 不需要申请时返回false
+    private boolean requestRuntimePermissions(List<String> permissions) {
+        if (Build.VERSION.SDK_INT < Build.VERSION_CODES.M) {
+            return false;
+        }
+        List<String> missing = new ArrayList<>();
+        for (String permission : permissions) {
+            if (checkSelfPermission(permission) != PackageManager.PERMISSION_GRANTED) {
+                missing.add(permission);
+            }
+        }
+        if (missing.isEmpty()) {
+            return false;
+        }
+        requestPermissions(missing.toArray(new String[0]), REQUEST_PERMISSIONS);
+        return true;
+    }
+
+    @Override
+    public void onRequestPermissionsResult(int requestCode, String[] permissions, int[] grantResults) {
+        super.onRequestPermissionsResult(requestCode, permissions, grantResults);
+        if (requestCode != REQUEST_PERMISSIONS) {
+            return;
+        }
+        boolean granted = grantResults.length > 0;
+        for (int result : grantResults) {
+            granted &= result == PackageManager.PERMISSION_GRANTED;
+        }
+        if (pendingRequest != null) {
+            if (granted) {
+                pendingRequest.grant(pendingRequest.getResources());
+            } else {
+                pendingRequest.deny();
+            }
+            pendingRequest = null;
+        }
+        if (pendingGeolocation != null) {
+            pendingGeolocation.invoke(pendingOrigin, granted, false);
+            pendingGeolocation = null;
+        }
+    }
+
     @Override
     public void onBackPressed() {
         if (webView.canGoBack()) {
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pzx521521/apk-editor/editor"
+)
+
+// isApkSet 判断模板是否为 split apk 集合: bundletool 的 .apks, .xapk 或包含 base 和 split apk 的目录
+func isApkSet(p string) bool {
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".apks", ".xapk":
+		return true
+	}
+	stat, err := os.Stat(p)
+	return err == nil && stat.IsDir()
+}
+
+// readApkSet 读取 .apks, .xapk 或目录, 目录中的文件路径使用 / 分隔
+func readApkSet(p string) (*editor.ApkSet, error) {
+	stat, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.IsDir() {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		return editor.ReadApkSet(b)
+	}
+	var files []*editor.MergeEntry
+	err = filepath.WalkDir(p, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(p, file)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		files = append(files, &editor.MergeEntry{Name: filepath.ToSlash(rel), Data: data})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return editor.NewApkSet(files)
+}
+
+// writeApkSet 输出为 .apks/.xapk 时写为一个zip, 否则写入 output 目录
+func writeApkSet(set *editor.ApkSet, output string) error {
+	switch strings.ToLower(filepath.Ext(output)) {
+	case ".apks", ".xapk":
+		b, err := set.Zip()
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(output, b, 0644)
+	}
+	for _, e := range set.Entries() {
+		if !editor.IsLocalName(e.Name) {
+			return fmt.Errorf("%s: not a relative path inside %s", e.Name, output)
+		}
+		p := filepath.Join(output, filepath.FromSlash(e.Name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, e.Data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// save 生成并保存 apk/aab, set 不为空时修改并保存整个 apk 集合
+func save(apkEditor *editor.ApkEditor, set *editor.ApkSet, output string) error {
+	if set != nil {
+		edited, err := apkEditor.EditSet(set)
+		if err != nil {
+			return err
+		}
+		logManifestChanges(apkEditor)
+		if err := writeApkSet(edited, output); err != nil {
+			return err
+		}
+	} else {
+		edit, err := editOutput(apkEditor, output)
+		if err != nil {
+			return err
+		}
+		logManifestChanges(apkEditor)
+		if err := os.WriteFile(output, edit, 0644); err != nil {
+			return err
+		}
+	}
+	log.Printf("success save at:%s\n", output)
+	// apk 集合的v4签名已在集合中
+	if set == nil {
+		if err := writeIDSig(apkEditor, output); err != nil {
+			return err
+		}
+	}
+	return writeAssetLinks(apkEditor, output)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pzx521521/apk-editor/editor"
+)
+
+// batchRequest 是 /tool/batch 的请求体, 每个变体的 editor 与 editor.ApkEditor 的json格式相同
+type batchRequest struct {
+	Workers  int `json:"workers"`
+	Variants []struct {
+		Name   string          `json:"name"`
+		Editor json.RawMessage `json:"editor"`
+	} `json:"variants"`
+}
+
+// batchResult 是 report.json 中的一项
+type batchResult struct {
+	Name     string   `json:"name"`
+	Size     int      `json:"size,omitempty"`
+	Duration float64  `json:"duration"`
+	Error    string   `json:"error,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// BatchApk 用同一个模板并发构建多个变体, 返回包含所有apk和 report.json 的zip.
+// 每个变体构建时占用 slots 中的一个构建位, 一个请求最多 maxVariants 个变体
+func BatchApk(slots buildSlots, maxVariants int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req batchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), errorStatus(badRequest(err)))
+			return
+		}
+		if len(req.Variants) > maxVariants {
+			http.Error(w, fmt.Sprintf("too many variants: %d, at most %d", len(req.Variants), maxVariants), http.StatusBadRequest)
+			return
+		}
+		editors, err := batchEditors(&req)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		if req.Workers <= 0 || req.Workers > cap(slots) {
+			req.Workers = cap(slots)
+		}
+		results := make([]batchResult, len(editors))
+		apks := make([][]byte, len(editors))
+		durations := make([]time.Duration, len(editors))
+		for i := range editors {
+			i := i
+			editors[i].OnStage = func(stage string, elapsed time.Duration) {
+				durations[i] += elapsed
+				observeStage(stage, elapsed)
+			}
+		}
+		editAll(r.Context(), slots, editors, req.Workers, func(i int, apk []byte, err error) {
+			if !errors.Is(err, context.Canceled) {
+				observeBuild(inputKind(editors[i]), durations[i], len(apk), failedStage(err))
+			}
+			results[i] = batchResult{Name: req.Variants[i].Name, Size: len(apk), Duration: durations[i].Seconds(), Warnings: editors[i].Warnings}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+			apks[i] = apk
+		})
+		if r.Context().Err() != nil {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="webview-batch.zip"`)
+		zw := zip.NewWriter(w)
+		for i, apk := range apks {
+			if apk == nil {
+				continue
+			}
+			// apk本身已经压缩, 直接存储
+			f, err := zw.CreateHeader(&zip.FileHeader{Name: results[i].Name + ".apk", Method: zip.Store, Modified: time.Now()})
+			if err != nil {
+				return
+			}
+			if _, err := f.Write(apk); err != nil {
+				return
+			}
+			if links := editors[i].AssetLinks; links != nil {
+				f, err := zw.Create(results[i].Name + ".assetlinks.json")
+				if err != nil {
+					return
+				}
+				if _, err := f.Write(links); err != nil {
+					return
+				}
+			}
+		}
+		f, err := zw.Create("report.json")
+		if err != nil {
+			return
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return
+		}
+		zw.Close()
+	}
+}
+
+// editAll 与 editor.EditAll 相同, 但每个构建开始前等待 slots 中的构建位,
+// 请求结束后还没开始的构建以 ctx.Err() 结束
+func editAll(ctx context.Context, slots buildSlots, editors []*editor.ApkEditor, workers int, done func(i int, apk []byte, err error)) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(editors); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if !slots.acquire(ctx) {
+					done(i, nil, ctx.Err())
+					continue
+				}
+				apk, err := editors[i].Edit()
+				slots.release()
+				done(i, apk, err)
+			}
+		}()
+	}
+	for i := range editors {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// batchEditors 为每个变体创建共用模板的 ApkEditor
+func batchEditors(req *batchRequest) ([]*editor.ApkEditor, error) {
+	if len(req.Variants) == 0 {
+		return nil, badRequest(errors.New("variants is required"))
+	}
+	tpl, err := loadTemplate()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	editors := make([]*editor.ApkEditor, len(req.Variants))
+	for i, v := range req.Variants {
+		if v.Name == "" {
+			return nil, badRequest(fmt.Errorf("variants[%d]: name is required", i))
+		}
+		if strings.ContainsAny(v.Name, `/\`) || v.Name == ".." {
+			return nil, badRequest(fmt.Errorf("variants[%d]: invalid name %q", i, v.Name))
+		}
+		if seen[v.Name] {
+			return nil, badRequest(fmt.Errorf("duplicate variant %q", v.Name))
+		}
+		seen[v.Name] = true
+		editors[i] = tpl.NewEditor()
+		if err := json.Unmarshal(v.Editor, editors[i]); err != nil {
+			return nil, badRequest(fmt.Errorf("%s: %w", v.Name, err))
+		}
+	}
+	return editors, nil
+}
+
+// inputKind 返回变体的输入类型(url/html/zip)用于统计
+func inputKind(a *editor.ApkEditor) string {
+	switch {
+	case a.Url != "":
+		return "url"
+	case a.IndexHtml != nil:
+		return "html"
+	case a.HtmlZip != nil:
+		return "zip"
+	}
+	return "unknown"
+}
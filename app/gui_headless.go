@@ -0,0 +1,10 @@
+//go:build headless
+
+package main
+
+import "log"
+
+// runGui 在无界面构建(-tags headless)中不可用, 不依赖cgo和webview, 便于放进容器
+func runGui() {
+	log.Fatalln("built without GUI, use: apkEditor serve -listen=:8080")
+}
@@ -1,87 +1,288 @@
 package main
 
 import (
+	"bytes"
 	"embed"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/pzx521521/apk-editor/editor"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 //go:embed release/*
 var embedFiles embed.FS
 
+var (
+	templateOnce sync.Once
+	apkTemplate  *editor.Template
+	templateErr  error
+)
+
+// loadTemplate 只解析一次内置的模板apk和签名密钥, 所有请求共用
+func loadTemplate() (*editor.Template, error) {
+	templateOnce.Do(func() {
+		var files [3][]byte
+		for i, name := range []string{"release/app-release.apk", "release/signing.key", "release/signing.crt"} {
+			if files[i], templateErr = embedFiles.ReadFile(name); templateErr != nil {
+				return
+			}
+		}
+		apkTemplate, templateErr = editor.NewTemplate(files[0], files[1], files[2])
+	})
+	return apkTemplate, templateErr
+}
+
 func Html2Apk(w http.ResponseWriter, r *http.Request) {
 	err := html2Apk(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+	}
+}
+
+// DownloadApk 与 Html2Apk 相同, 但直接把生成的apk返回给客户端, 用于无界面的服务模式
+func DownloadApk(w http.ResponseWriter, r *http.Request) {
+	edit, err := buildApk(r)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	name, contentType := outputName(r)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(edit)))
+	w.Write(edit)
+}
+
+// errorStatus 请求体超过 maxBody 的限制时返回413, 请求参数或 editor 检查设置失败时返回400, 其他错误返回500
+func errorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	var invalid *editor.ValidationError
+	if errors.As(err, &invalid) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// badRequest 把请求参数的错误标记为 editor.ValidationError, errorStatus 对其返回400
+func badRequest(err error) error {
+	return &editor.ValidationError{Err: err}
+}
+
+// AssetLinks 返回包名为 package 的应用的 assetlinks.json, 用于 App Link (applink) 的验证
+func AssetLinks(w http.ResponseWriter, r *http.Request) {
+	pkg := r.FormValue("package")
+	if pkg == "" {
+		http.Error(w, "package is required", http.StatusBadRequest)
+		return
+	}
+	tpl, err := loadTemplate()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	links, err := tpl.AssetLinks(pkg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(links)
+}
+
+// outputName 返回表单 format 对应的文件名和 Content-Type, format=aab 时生成 Android App Bundle
+func outputName(r *http.Request) (string, string) {
+	if r.FormValue("format") == "aab" {
+		return "webview.aab", "application/octet-stream"
+	}
+	return "webview.apk", "application/vnd.android.package-archive"
 }
+
 func html2Apk(w http.ResponseWriter, r *http.Request) error {
-	crt, err := embedFiles.ReadFile("release/signing.crt")
+	edit, err := buildApk(r)
 	if err != nil {
 		return err
 	}
-	apk, err := embedFiles.ReadFile("release/app-release.apk")
+	// 用户主目录
+	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return err
 	}
+	// 获取桌面路径
+	name, _ := outputName(r)
+	desktopPath := filepath.Join(homeDir, "Desktop", name)
 
-	key, err := embedFiles.ReadFile("release/signing.key")
+	err = os.WriteFile(desktopPath, edit, 0644)
 	if err != nil {
 		return err
 	}
-	apkEditor := editor.NewApkEditor(apk, key, crt)
+	_, err = w.Write([]byte("success save at: " + desktopPath))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func buildApk(r *http.Request) ([]byte, error) {
+	start := time.Now()
+	input, edit, err := editApk(r)
+	observeBuild(input, time.Since(start), len(edit), failedStage(err))
+	return edit, err
+}
+
+// failedStage 返回构建失败的阶段, 成功时返回空字符串
+func failedStage(err error) string {
+	if err == nil {
+		return ""
+	}
+	var stageErr *editor.StageError
+	if errors.As(err, &stageErr) {
+		return stageErr.Stage
+	}
+	return "request"
+}
+
+// editApk 根据表单生成apk, 并返回输入的类型(url/html/zip)用于统计
+func editApk(r *http.Request) (string, []byte, error) {
+	tpl, err := loadTemplate()
+	if err != nil {
+		return "unknown", nil, err
+	}
+	// FormValue 会忽略解析错误, 先解析表单以便报告请求体过大等错误
+	if err := r.ParseMultipartForm(32 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return "unknown", nil, badRequest(err)
+	}
+	apkEditor := tpl.NewEditor()
+	apkEditor.OnStage = observeStage
 	// 获取manifest信息
 	var manifest editor.Manifest
 	manifestJson := r.FormValue("manifest")
 	if err := json.Unmarshal([]byte(manifestJson), &manifest); err != nil {
-		return err
+		return "unknown", nil, badRequest(fmt.Errorf("manifest: %w", err))
+	}
+	for _, s := range r.Form["permission"] {
+		p, err := editor.ParsePermission(s)
+		if err != nil {
+			return "unknown", nil, badRequest(err)
+		}
+		manifest.AddPermissions = append(manifest.AddPermissions, p)
+	}
+	manifest.RemovePermissions = append(manifest.RemovePermissions, r.Form["remove_permission"]...)
+	for _, s := range r.Form["meta"] {
+		k, v, ok := strings.Cut(s, "=")
+		if !ok || k == "" {
+			return "unknown", nil, badRequest(fmt.Errorf("meta %q: want key=value", s))
+		}
+		if manifest.MetaData == nil {
+			manifest.MetaData = map[string]string{}
+		}
+		manifest.MetaData[k] = v
+	}
+	for i, field := range []string{"deeplink", "applink"} {
+		for _, s := range r.Form[field] {
+			l, err := editor.ParseDeepLink(s, i == 1)
+			if err != nil {
+				return "unknown", nil, badRequest(err)
+			}
+			manifest.DeepLinks = append(manifest.DeepLinks, l)
+		}
 	}
 	apkEditor.Manifest = &manifest
+	// color 为 primary=#112233 形式, key 为 editor.ThemeColors 的json字段名
+	if colors := r.Form["color"]; len(colors) > 0 {
+		theme := map[string]string{}
+		for _, s := range colors {
+			k, v, ok := strings.Cut(s, "=")
+			if !ok || k == "" {
+				return "unknown", nil, badRequest(fmt.Errorf("color %q: want name=#RRGGBB", s))
+			}
+			theme[k] = v
+		}
+		b, _ := json.Marshal(theme)
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&apkEditor.Theme); err != nil {
+			return "unknown", nil, badRequest(fmt.Errorf("color: %w", err))
+		}
+	}
+	// 有任一网络安全字段时重新生成 network_security_config.xml
+	if domains, pins := r.Form["cleartext_domain"], r.Form["pin"]; len(domains) > 0 || len(pins) > 0 || r.FormValue("debug_user_ca") == "true" {
+		n := &editor.NetworkSecurityConfig{CleartextDomains: domains, DebugUserCAs: r.FormValue("debug_user_ca") == "true"}
+		for _, s := range pins {
+			p, err := editor.ParseCertificatePin(s)
+			if err != nil {
+				return "unknown", nil, badRequest(err)
+			}
+			n.Pins = append(n.Pins, p)
+		}
+		apkEditor.NetworkSecurity = n
+	}
+	// 有任一启动画面字段时生成启动画面
+	if background, duration := r.FormValue("splash_background"), r.FormValue("splash_min_duration"); background != "" || duration != "" {
+		apkEditor.Splash = &editor.Splash{Background: background}
+		if duration != "" {
+			if apkEditor.Splash.MinDuration, err = strconv.Atoi(duration); err != nil {
+				return "unknown", nil, badRequest(fmt.Errorf("splash_min_duration: %w", err))
+			}
+		}
+	}
+	if r.MultipartForm != nil {
+		if f, ok := r.MultipartForm.File["manifest_file"]; ok {
+			if apkEditor.ManifestXML, err = getFileData(f[0]); err != nil {
+				return "unknown", nil, err
+			}
+		}
+		if f, ok := r.MultipartForm.File["splash_file"]; ok {
+			if apkEditor.Splash == nil {
+				apkEditor.Splash = &editor.Splash{}
+			}
+			if apkEditor.Splash.Image, err = getFileData(f[0]); err != nil {
+				return "unknown", nil, err
+			}
+		}
+	}
+	input := "unknown"
 	if url := r.FormValue("url"); url != "" {
+		input = "url"
 		if !strings.HasPrefix(url, "http") {
-			return errors.New("url must start with http")
+			return input, nil, badRequest(errors.New("url must start with http"))
 		}
 		apkEditor.Url = url
+	} else if r.MultipartForm == nil {
+		return input, nil, badRequest(errors.New("url, html_file or zip_file is required"))
 	} else if html, ok := r.MultipartForm.File["html_file"]; ok {
+		input = "html"
 		apkEditor.IndexHtml, err = getFileData(html[0])
 		if err != nil {
-			return err
+			return input, nil, err
 		}
 	} else if zip, ok := r.MultipartForm.File["zip_file"]; ok {
+		input = "zip"
 		apkEditor.HtmlZip, err = getFileData(zip[0])
 		if err != nil {
-			return err
+			return input, nil, err
 		}
+	} else {
+		return input, nil, badRequest(errors.New("url, html_file or zip_file is required"))
 	}
 
-	edit, err := apkEditor.Edit()
-	if err != nil {
-		return err
+	if r.FormValue("format") == "aab" {
+		edit, err := apkEditor.EditBundle()
+		return input, edit, err
 	}
-	// 用户主目录
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return err
-	}
-	// 获取桌面路径
-	desktopPath := filepath.Join(homeDir, "Desktop", "webview.apk")
-
-	err = os.WriteFile(desktopPath, edit, 0644)
-	if err != nil {
-		return err
-	}
-	_, err = w.Write([]byte("success save at: " + desktopPath))
-	if err != nil {
-		return err
-	}
-	return nil
+	edit, err := apkEditor.Edit()
+	return input, edit, err
 }
 func getFileData(file *multipart.FileHeader) ([]byte, error) {
 	open, err := file.Open()
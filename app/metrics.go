@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 简单的Prometheus文本格式指标, 不引入client_golang依赖
+
+var (
+	buildsTotal = newCounterVec("apkeditor_builds_total",
+		"Number of apk builds by input type and outcome.", "input", "outcome")
+	buildFailuresTotal = newCounterVec("apkeditor_build_failures_total",
+		"Number of failed apk builds by the stage that failed.", "stage")
+	buildDuration = newHistogramVec("apkeditor_build_duration_seconds",
+		"Total duration of apk builds.", durationBuckets, "input")
+	stageDuration = newHistogramVec("apkeditor_build_stage_duration_seconds",
+		"Duration of each apk build stage.", durationBuckets, "stage")
+	outputBytes = newHistogramVec("apkeditor_build_output_bytes",
+		"Size of generated apks.", sizeBuckets)
+	buildsQueued = newGauge("apkeditor_builds_queued",
+		"Number of builds waiting for a free build slot.")
+	buildsInFlight = newGauge("apkeditor_builds_in_flight",
+		"Number of builds currently running.")
+
+	allMetrics = []metric{buildsTotal, buildFailuresTotal, buildDuration, stageDuration, outputBytes, buildsQueued, buildsInFlight}
+
+	durationBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	sizeBuckets     = []float64{1 << 20, 2 << 20, 4 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20, 128 << 20}
+)
+
+type metric interface {
+	write(w io.Writer)
+}
+
+// MetricsHandler 输出所有指标
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, m := range allMetrics {
+		m.write(w)
+	}
+}
+
+// observeStage 作为 editor.ApkEditor.OnStage 使用
+func observeStage(stage string, elapsed time.Duration) {
+	stageDuration.observe(elapsed.Seconds(), stage)
+}
+
+// observeBuild 记录一次构建的结果, failedStage 为空表示成功
+func observeBuild(input string, elapsed time.Duration, size int, failedStage string) {
+	buildDuration.observe(elapsed.Seconds(), input)
+	if failedStage != "" {
+		buildsTotal.inc(input, "error")
+		buildFailuresTotal.inc(failedStage)
+		return
+	}
+	buildsTotal.inc(input, "success")
+	outputBytes.observe(float64(size))
+}
+
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func formatLabels(names, values []string, extra ...string) string {
+	var pairs []string
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, values[i]))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", extra[i], extra[i+1]))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return fmt.Sprint(f)
+}
+
+type counterVec struct {
+	name, help string
+	labels     []string
+	mu         sync.Mutex
+	values     map[string]float64
+	keys       map[string][]string
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels,
+		values: map[string]float64{}, keys: map[string][]string{}}
+}
+
+func (c *counterVec) inc(labelValues ...string) {
+	k := labelKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[k]++
+	c.keys[k] = labelValues
+}
+
+func (c *counterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, c.keys[k]), formatFloat(c.values[k]))
+	}
+}
+
+type gauge struct {
+	name, help string
+	mu         sync.Mutex
+	value      float64
+}
+
+func newGauge(name, help string) *gauge {
+	return &gauge{name: name, help: help}
+}
+
+func (g *gauge) add(v float64) {
+	g.mu.Lock()
+	g.value += v
+	g.mu.Unlock()
+}
+
+func (g *gauge) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", g.name, g.help, g.name, g.name, formatFloat(g.value))
+}
+
+type histogram struct {
+	labelValues []string
+	counts      []uint64 // 与buckets一一对应, 非累计
+	count       uint64
+	sum         float64
+}
+
+type histogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+	mu         sync.Mutex
+	values     map[string]*histogram
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, buckets: buckets,
+		values: map[string]*histogram{}}
+}
+
+func (h *histogramVec) observe(v float64, labelValues ...string) {
+	k := labelKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hist, ok := h.values[k]
+	if !ok {
+		hist = &histogram{labelValues: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.values[k] = hist
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			hist.counts[i]++
+			break
+		}
+	}
+	hist.count++
+	hist.sum += v
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	keys := make([]string, 0, len(h.values))
+	for k := range h.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		hist := h.values[k]
+		var cumulative uint64
+		for i, b := range h.buckets {
+			cumulative += hist.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, hist.labelValues, "le", formatFloat(b)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, hist.labelValues, "le", "+Inf"), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, hist.labelValues), formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, hist.labelValues), hist.count)
+	}
+}
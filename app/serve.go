@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// runServe 以无界面的方式运行与GUI相同的http服务, 便于部署到容器中
+//
+//	apkEditor serve -listen=:8080 [-tls-cert=cert.pem -tls-key=key.pem]
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := fs.String("listen", ":8080", "监听地址")
+	certFile := fs.String("tls-cert", "", "TLS证书路径, 为空时使用http")
+	keyFile := fs.String("tls-key", "", "TLS私钥路径")
+	maxBuilds := fs.Int("max-builds", runtime.NumCPU(), "同时进行的最大构建数, 超出的请求排队等待")
+	maxBodySize := fs.Int64("max-body", 64<<20, "请求体的最大字节数, 超出时返回413")
+	maxVariants := fs.Int("max-variants", 100, "批量构建一个请求最多的变体数")
+	shutdownTimeout := fs.Duration("shutdown-timeout", 30*time.Second, "收到SIGTERM后等待请求完成的最长时间")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (*certFile == "") != (*keyFile == "") {
+		return errors.New("-tls-cert and -tls-key must be set together")
+	}
+	// 启动前确认模板可用, 避免就绪后才发现镜像缺少文件
+	if _, err := loadTemplate(); err != nil {
+		return err
+	}
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	var ready atomic.Bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", fileHandle)
+	// 单个构建和批量构建的每个变体共用同一组构建位
+	slots := newBuildSlots(*maxBuilds)
+	mux.Handle("/tool/html2apk", limitBuilds(slots, maxBody(*maxBodySize, http.HandlerFunc(DownloadApk))))
+	mux.Handle("/tool/batch", maxBody(*maxBodySize, BatchApk(slots, *maxVariants)))
+	mux.HandleFunc("/tool/assetlinks", AssetLinks)
+	mux.HandleFunc("/metrics", MetricsHandler)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	server := &http.Server{
+		Handler:           accessLog(logger, mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errCh := make(chan error, 1)
+	go func() {
+		if *certFile != "" {
+			errCh <- server.ServeTLS(listener, *certFile, *keyFile)
+		} else {
+			errCh <- server.Serve(listener)
+		}
+	}()
+	ready.Store(true)
+	logger.Info("listening", "addr", listener.Addr().String(), "tls", *certFile != "")
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	// 先标记为未就绪, 让ingress停止转发新请求, 再等待已有请求完成
+	ready.Store(false)
+	logger.Info("shutting down", "timeout", shutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// buildSlots 限制同时进行的构建数, 等待中的构建计入 apkeditor_builds_queued
+type buildSlots chan struct{}
+
+func newBuildSlots(n int) buildSlots {
+	if n < 1 {
+		n = 1
+	}
+	return make(buildSlots, n)
+}
+
+// acquire 等待一个空闲的构建位, ctx 结束时放弃并返回false
+func (s buildSlots) acquire(ctx context.Context) bool {
+	buildsQueued.add(1)
+	defer buildsQueued.add(-1)
+	select {
+	case s <- struct{}{}:
+		buildsInFlight.add(1)
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s buildSlots) release() {
+	buildsInFlight.add(-1)
+	<-s
+}
+
+// limitBuilds 每个请求占用一个构建位
+func limitBuilds(slots buildSlots, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !slots.acquire(r.Context()) {
+			return
+		}
+		defer slots.release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// maxBody 限制请求体的大小, 避免未认证的客户端让服务缓存任意大的上传
+func maxBody(n int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, n)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusRecorder 记录响应的状态码和大小, 用于访问日志
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.size += n
+	return n, err
+}
+
+func accessLog(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		logger.Info("access",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"bytes", rec.size,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"remote", r.RemoteAddr,
+			"user_agent", r.UserAgent(),
+		)
+	})
+}
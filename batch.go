@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pzx521521/apk-editor/editor"
+)
+
+// BatchConfig 是 apkEditor batch -c 使用的配置文件. 顶层字段与 BuildConfig 相同, 作为所有变体的默认值,
+// variants 列出每个变体, matrix 中每一组是一个维度, 各维度的笛卡尔积生成更多变体, 名称用 - 连接
+type BatchConfig struct {
+	BuildConfig `yaml:",inline"`
+	// Workers 并发构建数, 默认为CPU核数
+	Workers int `yaml:"workers" json:"workers" toml:"workers"`
+	// OutputDir 变体未设置 output 时输出到 <outputDir>/<name>.apk, 默认为 dist
+	OutputDir string `yaml:"outputDir" json:"outputDir" toml:"outputDir"`
+	// Report 构建报告路径, 默认为 <outputDir>/report.json
+	Report   string            `yaml:"report" json:"report" toml:"report"`
+	Variants []VariantConfig   `yaml:"variants" json:"variants" toml:"variants"`
+	Matrix   [][]VariantConfig `yaml:"matrix" json:"matrix" toml:"matrix"`
+}
+
+// VariantConfig 覆盖 BatchConfig 中的默认值, 为空的字段沿用默认值
+type VariantConfig struct {
+	Name     string         `yaml:"name" json:"name" toml:"name"`
+	Input    string         `yaml:"input" json:"input" toml:"input"`
+	Output   string         `yaml:"output" json:"output" toml:"output"`
+	Manifest ManifestConfig `yaml:"manifest" json:"manifest" toml:"manifest"`
+	Icon     string         `yaml:"icon" json:"icon" toml:"icon"`
+	WebView  *WebViewConfig `yaml:"webview" json:"webview" toml:"webview"`
+	Theme    *ThemeConfig   `yaml:"theme" json:"theme" toml:"theme"`
+	Splash   *SplashConfig  `yaml:"splash" json:"splash" toml:"splash"`
+}
+
+// BatchResult 是构建报告中的一项
+type BatchResult struct {
+	Name     string   `json:"name"`
+	Output   string   `json:"output,omitempty"`
+	Size     int      `json:"size,omitempty"`
+	Duration float64  `json:"duration"`
+	Error    string   `json:"error,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+func loadBatchConfig(path string) (*BatchConfig, error) {
+	conf := &BatchConfig{}
+	if err := decodeConfig(path, conf); err != nil {
+		return nil, err
+	}
+	conf.dir = filepath.Dir(path)
+	return conf, nil
+}
+
+// merge 用 o 中非空的字段覆盖 v, 名称用 - 连接
+func (v VariantConfig) merge(o VariantConfig) VariantConfig {
+	if v.Name == "" {
+		v.Name = o.Name
+	} else if o.Name != "" {
+		v.Name += "-" + o.Name
+	}
+	if o.Input != "" {
+		v.Input = o.Input
+	}
+	if o.Output != "" {
+		v.Output = o.Output
+	}
+	v.Manifest = v.Manifest.merge(o.Manifest)
+	if o.Icon != "" {
+		v.Icon = o.Icon
+	}
+	if o.WebView != nil {
+		v.WebView = o.WebView
+	}
+	if o.Theme != nil {
+		v.Theme = o.Theme
+	}
+	if o.Splash != nil {
+		v.Splash = o.Splash
+	}
+	return v
+}
+
+// variants 返回 variants 和 matrix 展开后的所有变体, 名称必须唯一
+func (c *BatchConfig) variants() ([]VariantConfig, error) {
+	all := append([]VariantConfig{}, c.Variants...)
+	if len(c.Matrix) > 0 {
+		product := []VariantConfig{{}}
+		for _, axis := range c.Matrix {
+			var next []VariantConfig
+			for _, p := range product {
+				for _, v := range axis {
+					next = append(next, p.merge(v))
+				}
+			}
+			product = next
+		}
+		all = append(all, product...)
+	}
+	if len(all) == 0 {
+		return nil, fmt.Errorf("no variants")
+	}
+	seen := map[string]bool{}
+	for _, v := range all {
+		if v.Name == "" {
+			return nil, fmt.Errorf("variant name is required")
+		}
+		if seen[v.Name] {
+			return nil, fmt.Errorf("duplicate variant %q", v.Name)
+		}
+		seen[v.Name] = true
+	}
+	return all, nil
+}
+
+// variant 返回变体合并默认值后的 BuildConfig
+func (c *BatchConfig) variant(v VariantConfig) *BuildConfig {
+	base := VariantConfig{
+		Input:    c.Input,
+		Manifest: c.Manifest,
+		Icon:     c.Icon,
+		WebView:  c.WebView,
+		Theme:    c.Theme,
+		Splash:   c.Splash,
+	}
+	v = base.merge(v)
+	conf := c.BuildConfig
+	conf.Input, conf.Manifest, conf.Icon, conf.WebView, conf.Theme, conf.Splash, conf.Output = v.Input, v.Manifest, v.Icon, v.WebView, v.Theme, v.Splash, v.Output
+	return &conf
+}
+
+// runBatch apkEditor batch -c batch.yaml
+func runBatch(args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	configPath := fs.String("c", "apkEditor.yaml", "配置文件路径 (yaml/json/toml)")
+	workers := fs.Int("j", 0, "并发构建数, 覆盖配置文件中的workers")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	conf, err := loadBatchConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if *workers > 0 {
+		conf.Workers = *workers
+	}
+	variants, err := conf.variants()
+	if err != nil {
+		return fmt.Errorf("%s: %w", *configPath, err)
+	}
+	outputDir := conf.path(conf.OutputDir)
+	if outputDir == "" {
+		outputDir = conf.path("dist")
+	}
+	report := conf.path(conf.Report)
+	if report == "" {
+		report = filepath.Join(outputDir, "report.json")
+	}
+	// 模板和密钥只解析一次, 所有变体共用
+	tpl, err := conf.template()
+	if err != nil {
+		return err
+	}
+	results := make([]BatchResult, len(variants))
+	editors := make([]*editor.ApkEditor, len(variants))
+	outputs := make([]string, len(variants))
+	for i, v := range variants {
+		results[i].Name = v.Name
+		vc := conf.variant(v)
+		if vc.Input == "" {
+			return fmt.Errorf("%s: input is required", v.Name)
+		}
+		outputs[i] = vc.path(vc.Output)
+		if outputs[i] == "" {
+			outputs[i] = filepath.Join(outputDir, v.Name+".apk")
+		}
+		editors[i] = tpl.NewEditor()
+		if err := vc.apply(editors[i]); err != nil {
+			return fmt.Errorf("%s: %w", v.Name, err)
+		}
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+	// 每个 editor 只在一个 worker 中构建, 各阶段耗时之和即构建耗时
+	durations := make([]time.Duration, len(variants))
+	for i := range editors {
+		i := i
+		editors[i].OnStage = func(stage string, elapsed time.Duration) {
+			durations[i] += elapsed
+		}
+	}
+	editor.EditAll(editors, conf.Workers, func(i int, apk []byte, err error) {
+		res := &results[i]
+		res.Duration = durations[i].Seconds()
+		res.Warnings = editors[i].Warnings
+		if err == nil {
+			err = os.WriteFile(outputs[i], apk, 0644)
+		}
+		if err == nil {
+			err = writeIDSig(editors[i], outputs[i])
+		}
+		if err == nil {
+			err = writeAssetLinks(editors[i], outputs[i])
+		}
+		if err != nil {
+			res.Error = err.Error()
+			log.Printf("%s: %v\n", res.Name, err)
+			return
+		}
+		res.Output, res.Size = outputs[i], len(apk)
+		log.Printf("%s: success save at:%s\n", res.Name, outputs[i])
+	})
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(report, data, 0644); err != nil {
+		return err
+	}
+	var failed []string
+	for _, res := range results {
+		if res.Error != "" {
+			failed = append(failed, res.Name)
+		}
+	}
+	log.Printf("%d/%d variants built, report at:%s\n", len(results)-len(failed), len(results), report)
+	if len(failed) > 0 {
+		return fmt.Errorf("failed variants: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+)
+
+// runBuild apkEditor build -c app.yaml
+func runBuild(args []string) error {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	configPath := fs.String("c", "apkEditor.yaml", "配置文件路径 (yaml/json/toml)")
+	output := fs.String("o", "", "输出文件路径, 覆盖配置文件中的output, 扩展名为 .aab 时生成 Android App Bundle")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	conf, err := loadBuildConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	out := *output
+	if out == "" {
+		out = conf.path(conf.Output)
+	}
+	if out == "" {
+		out = "webview.apk"
+	}
+	abs, err := filepath.Abs(out)
+	if err != nil {
+		return err
+	}
+	apkEditor, set, err := conf.editor()
+	if err != nil {
+		return err
+	}
+	return save(apkEditor, set, abs)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pzx521521/apk-editor/editor/signv2"
+)
+
+// runChannel apkEditor channel -o dist app.apk huawei xiaomi, 把渠道号写入已签名apk的签名块, 每个渠道生成一个apk.
+// 不需要重新签名, 与 Walle 兼容. 没有指定渠道时以json格式输出apk中的渠道信息
+func runChannel(args []string) error {
+	fs := flag.NewFlagSet("channel", flag.ExitOnError)
+	outputDir := fs.String("o", "", "输出目录, 为空时与apk在同一目录, 文件名为 <apk名>_<渠道>.apk")
+	channelFile := fs.String("f", "", "渠道列表文件, 每行一个渠道, # 开头的行为注释")
+	var extra keyValues
+	fs.Var(&extra, "extra", "写入每个渠道的额外信息, 可重复, 如 campaign=spring")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return errors.New("usage: apkEditor channel [-o dir] [-f channels.txt] [-extra key=value] <apk> [channel...]")
+	}
+	apkPath, channels := fs.Arg(0), fs.Args()[1:]
+	if *channelFile != "" {
+		list, err := readChannels(*channelFile)
+		if err != nil {
+			return err
+		}
+		channels = append(channels, list...)
+	}
+	apk, err := os.ReadFile(apkPath)
+	if err != nil {
+		return err
+	}
+	z, err := signv2.NewApkSign(apk)
+	if err != nil {
+		return err
+	}
+	if len(channels) == 0 {
+		payload, err := z.Channel()
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(payload)
+	}
+	dir := *outputDir
+	if dir == "" {
+		dir = filepath.Dir(apkPath)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	name := strings.TrimSuffix(filepath.Base(apkPath), filepath.Ext(apkPath))
+	for _, channel := range channels {
+		if strings.ContainsAny(channel, `/\`) {
+			return fmt.Errorf("%q: channel cannot contain path separators", channel)
+		}
+		payload := map[string]string{}
+		for k, v := range extra {
+			payload[k] = v
+		}
+		payload[signv2.ChannelKey] = channel
+		p := filepath.Join(dir, name+"_"+channel+".apk")
+		if err := writeChannel(z, p, payload); err != nil {
+			return err
+		}
+		log.Printf("%s: success save at:%s\n", channel, p)
+	}
+	return nil
+}
+
+func writeChannel(z *signv2.ApkSign, p string, payload map[string]string) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := z.WriteChannel(w, payload); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readChannels 读取渠道列表文件, 忽略空行和 # 开头的注释
+func readChannels(p string) ([]string, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var channels []string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			channels = append(channels, line)
+		}
+	}
+	return channels, nil
+}
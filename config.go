@@ -0,0 +1,552 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pzx521521/apk-editor/editor"
+	"gopkg.in/yaml.v3"
+)
+
+// BuildConfig 是 apkEditor build -c 使用的配置文件, 支持 yaml/json/toml.
+// 字符串值中的 ${VAR} 和 ${VAR:-default} 会被替换为环境变量, $$ 表示 $.
+// 相对路径相对于配置文件所在的目录
+type BuildConfig struct {
+	// Template 模板apk, 为空时使用内置的模板; .apks, .xapk 或目录时修改整个 split apk 集合(只用于 build)
+	Template string `yaml:"template" json:"template" toml:"template"`
+	// Descriptor 模板的描述文件(json), 为空时使用模板中的 assets/apk-editor.json
+	Descriptor string `yaml:"descriptor" json:"descriptor" toml:"descriptor"`
+	// Input 网址, index.html, 包含网页的目录或zip
+	Input    string         `yaml:"input" json:"input" toml:"input"`
+	Output   string         `yaml:"output" json:"output" toml:"output"`
+	Manifest ManifestConfig `yaml:"manifest" json:"manifest" toml:"manifest"`
+	// ManifestXML 文本格式的 AndroidManifest.xml, 编译后替换模板的manifest, manifest 中的修改在其之上进行
+	ManifestXML string `yaml:"manifestXml" json:"manifestXml" toml:"manifestXml"`
+	// Icon png/jpeg 启动图标
+	Icon    string         `yaml:"icon" json:"icon" toml:"icon"`
+	Signing SigningConfig  `yaml:"signing" json:"signing" toml:"signing"`
+	Zip     ZipConfig      `yaml:"zip" json:"zip" toml:"zip"`
+	WebView *WebViewConfig `yaml:"webview" json:"webview" toml:"webview"`
+	// Theme 主题的主色, 状态栏和窗口背景颜色
+	Theme *ThemeConfig `yaml:"theme" json:"theme" toml:"theme"`
+	// NetworkSecurity 重新生成 network_security_config.xml, 默认禁止明文http
+	NetworkSecurity *NetworkSecurityConfig `yaml:"networkSecurity" json:"networkSecurity" toml:"networkSecurity"`
+	// Splash 网页加载前的启动画面
+	Splash *SplashConfig `yaml:"splash" json:"splash" toml:"splash"`
+
+	dir string
+}
+
+type ManifestConfig struct {
+	VersionCode uint32 `yaml:"versionCode" json:"versionCode" toml:"versionCode"`
+	VersionName string `yaml:"versionName" json:"versionName" toml:"versionName"`
+	Label       string `yaml:"label" json:"label" toml:"label"`
+	// Labels 各语言的应用名, 如 zh-CN: 演示, 写入 string/app_name
+	Labels  map[string]string `yaml:"labels" json:"labels" toml:"labels"`
+	Package string            `yaml:"package" json:"package" toml:"package"`
+	// Permissions 添加的权限, CAMERA 或 WRITE_EXTERNAL_STORAGE:28 (maxSdkVersion)
+	Permissions       []string `yaml:"permissions" json:"permissions" toml:"permissions"`
+	RemovePermissions []string `yaml:"removePermissions" json:"removePermissions" toml:"removePermissions"`
+	// MinSdkVersion, TargetSdkVersion 为0时不修改, 会检查模板的dex和资源能否支持
+	MinSdkVersion    int `yaml:"minSdkVersion" json:"minSdkVersion" toml:"minSdkVersion"`
+	TargetSdkVersion int `yaml:"targetSdkVersion" json:"targetSdkVersion" toml:"targetSdkVersion"`
+	// DeepLinks 由应用打开的链接, 如 myapp://open 或 https://app.example.com/docs/*
+	DeepLinks []string `yaml:"deepLinks" json:"deepLinks" toml:"deepLinks"`
+	// AppLinks 需要验证(autoVerify)的 https 链接, 构建时在apk旁生成 assetlinks.json
+	AppLinks []string `yaml:"appLinks" json:"appLinks" toml:"appLinks"`
+	// Debuggable 等为空时不修改
+	Debuggable           *bool `yaml:"debuggable" json:"debuggable" toml:"debuggable"`
+	AllowBackup          *bool `yaml:"allowBackup" json:"allowBackup" toml:"allowBackup"`
+	UsesCleartextTraffic *bool `yaml:"usesCleartextTraffic" json:"usesCleartextTraffic" toml:"usesCleartextTraffic"`
+	ResizeableActivity   *bool `yaml:"resizeableActivity" json:"resizeableActivity" toml:"resizeableActivity"`
+	// ScreenOrientation 如 portrait, landscape, unspecified 删除属性
+	ScreenOrientation string `yaml:"screenOrientation" json:"screenOrientation" toml:"screenOrientation"`
+	// WindowSoftInputMode 如 adjustResize|stateHidden
+	WindowSoftInputMode string `yaml:"windowSoftInputMode" json:"windowSoftInputMode" toml:"windowSoftInputMode"`
+	// MetaData <application> 下的 meta-data, 按值的类型分开, 资源为 @xml/config 形式
+	MetaData         map[string]string `yaml:"metaData" json:"metaData" toml:"metaData"`
+	MetaDataInt      map[string]int32  `yaml:"metaDataInt" json:"metaDataInt" toml:"metaDataInt"`
+	MetaDataBool     map[string]bool   `yaml:"metaDataBool" json:"metaDataBool" toml:"metaDataBool"`
+	MetaDataResource map[string]string `yaml:"metaDataResource" json:"metaDataResource" toml:"metaDataResource"`
+	RemoveMetaData   []string          `yaml:"removeMetaData" json:"removeMetaData" toml:"removeMetaData"`
+}
+
+// merge 用 o 中非空的字段覆盖 m
+func (m ManifestConfig) merge(o ManifestConfig) ManifestConfig {
+	if o.VersionCode != 0 {
+		m.VersionCode = o.VersionCode
+	}
+	if o.VersionName != "" {
+		m.VersionName = o.VersionName
+	}
+	if o.Label != "" {
+		m.Label = o.Label
+	}
+	if o.Package != "" {
+		m.Package = o.Package
+	}
+	if o.Permissions != nil {
+		m.Permissions = o.Permissions
+	}
+	if o.RemovePermissions != nil {
+		m.RemovePermissions = o.RemovePermissions
+	}
+	if o.MinSdkVersion != 0 {
+		m.MinSdkVersion = o.MinSdkVersion
+	}
+	if o.TargetSdkVersion != 0 {
+		m.TargetSdkVersion = o.TargetSdkVersion
+	}
+	if o.DeepLinks != nil {
+		m.DeepLinks = o.DeepLinks
+	}
+	if o.AppLinks != nil {
+		m.AppLinks = o.AppLinks
+	}
+	if o.Debuggable != nil {
+		m.Debuggable = o.Debuggable
+	}
+	if o.AllowBackup != nil {
+		m.AllowBackup = o.AllowBackup
+	}
+	if o.UsesCleartextTraffic != nil {
+		m.UsesCleartextTraffic = o.UsesCleartextTraffic
+	}
+	if o.ResizeableActivity != nil {
+		m.ResizeableActivity = o.ResizeableActivity
+	}
+	if o.ScreenOrientation != "" {
+		m.ScreenOrientation = o.ScreenOrientation
+	}
+	if o.WindowSoftInputMode != "" {
+		m.WindowSoftInputMode = o.WindowSoftInputMode
+	}
+	m.Labels = mergeMap(m.Labels, o.Labels)
+	m.MetaData = mergeMap(m.MetaData, o.MetaData)
+	m.MetaDataInt = mergeMap(m.MetaDataInt, o.MetaDataInt)
+	m.MetaDataBool = mergeMap(m.MetaDataBool, o.MetaDataBool)
+	m.MetaDataResource = mergeMap(m.MetaDataResource, o.MetaDataResource)
+	if o.RemoveMetaData != nil {
+		m.RemoveMetaData = o.RemoveMetaData
+	}
+	return m
+}
+
+// mergeMap 返回 m 和 o 合并后的新map, 相同的key使用 o 中的值
+func mergeMap[V any](m, o map[string]V) map[string]V {
+	if len(o) == 0 {
+		return m
+	}
+	ret := make(map[string]V, len(m)+len(o))
+	for k, v := range m {
+		ret[k] = v
+	}
+	for k, v := range o {
+		ret[k] = v
+	}
+	return ret
+}
+
+// manifest 返回要对 AndroidManifest.xml 做的修改, 没有修改时为nil
+func (m ManifestConfig) manifest() (*editor.Manifest, error) {
+	manifest := &editor.Manifest{
+		VersionCode:          m.VersionCode,
+		VersionName:          m.VersionName,
+		Label:                m.Label,
+		Labels:               m.Labels,
+		Package:              m.Package,
+		RemovePermissions:    m.RemovePermissions,
+		MinSdkVersion:        m.MinSdkVersion,
+		TargetSdkVersion:     m.TargetSdkVersion,
+		Debuggable:           m.Debuggable,
+		AllowBackup:          m.AllowBackup,
+		UsesCleartextTraffic: m.UsesCleartextTraffic,
+		ResizeableActivity:   m.ResizeableActivity,
+		ScreenOrientation:    m.ScreenOrientation,
+		WindowSoftInputMode:  m.WindowSoftInputMode,
+		MetaData:             m.MetaData,
+		MetaDataInt:          m.MetaDataInt,
+		MetaDataBool:         m.MetaDataBool,
+		MetaDataResource:     m.MetaDataResource,
+		RemoveMetaData:       m.RemoveMetaData,
+	}
+	for _, s := range m.Permissions {
+		p, err := editor.ParsePermission(s)
+		if err != nil {
+			return nil, err
+		}
+		manifest.AddPermissions = append(manifest.AddPermissions, p)
+	}
+	for i, links := range [][]string{m.DeepLinks, m.AppLinks} {
+		for _, s := range links {
+			l, err := editor.ParseDeepLink(s, i == 1)
+			if err != nil {
+				return nil, err
+			}
+			manifest.DeepLinks = append(manifest.DeepLinks, l)
+		}
+	}
+	if reflect.ValueOf(*manifest).IsZero() {
+		return nil, nil
+	}
+	return manifest, nil
+}
+
+// SigningConfig PEM格式的RSA私钥和证书, 为空时使用内置的签名
+type SigningConfig struct {
+	Key  string `yaml:"key" json:"key" toml:"key"`
+	Cert string `yaml:"cert" json:"cert" toml:"cert"`
+	// V4 同时生成v4签名 <apk>.idsig, 用于 adb install --incremental
+	V4 bool `yaml:"v4" json:"v4" toml:"v4"`
+}
+
+type ZipConfig struct {
+	Store bool `yaml:"store" json:"store" toml:"store"`
+	Align int  `yaml:"align" json:"align" toml:"align"`
+	// Comment zip的注释, 如构建号, 为空时保留模板的注释
+	Comment string `yaml:"comment" json:"comment" toml:"comment"`
+	// Remove 从模板中删除的文件, 以/结尾时删除整个目录
+	Remove []string `yaml:"remove" json:"remove" toml:"remove"`
+}
+
+type WebViewConfig struct {
+	UserAgent       string `yaml:"userAgent" json:"userAgent" toml:"userAgent"`
+	JavaScript      *bool  `yaml:"javascript" json:"javascript" toml:"javascript"`
+	Zoom            *bool  `yaml:"zoom" json:"zoom" toml:"zoom"`
+	SwipeRefresh    *bool  `yaml:"swipeRefresh" json:"swipeRefresh" toml:"swipeRefresh"`
+	Fullscreen      *bool  `yaml:"fullscreen" json:"fullscreen" toml:"fullscreen"`
+	IgnoreSslErrors *bool  `yaml:"ignoreSslErrors" json:"ignoreSslErrors" toml:"ignoreSslErrors"`
+}
+
+// ThemeConfig 的颜色为 #RRGGBB 或 #AARRGGBB, 为空时不修改
+type ThemeConfig struct {
+	Primary          string `yaml:"primary" json:"primary" toml:"primary"`
+	PrimaryDark      string `yaml:"primaryDark" json:"primaryDark" toml:"primaryDark"`
+	Background       string `yaml:"background" json:"background" toml:"background"`
+	NightPrimary     string `yaml:"nightPrimary" json:"nightPrimary" toml:"nightPrimary"`
+	NightPrimaryDark string `yaml:"nightPrimaryDark" json:"nightPrimaryDark" toml:"nightPrimaryDark"`
+	NightBackground  string `yaml:"nightBackground" json:"nightBackground" toml:"nightBackground"`
+}
+
+// parseTheme 解析 primary=#112233 形式的颜色, key 为 ThemeConfig 的字段名
+func parseTheme(colors map[string]string) (*ThemeConfig, error) {
+	t := &ThemeConfig{}
+	fields := map[string]*string{
+		"primary":          &t.Primary,
+		"primaryDark":      &t.PrimaryDark,
+		"background":       &t.Background,
+		"nightPrimary":     &t.NightPrimary,
+		"nightPrimaryDark": &t.NightPrimaryDark,
+		"nightBackground":  &t.NightBackground,
+	}
+	for k, v := range colors {
+		f, ok := fields[k]
+		if !ok {
+			return nil, fmt.Errorf("color %s: want primary, primaryDark, background, nightPrimary, nightPrimaryDark or nightBackground", k)
+		}
+		*f = v
+	}
+	return t, nil
+}
+
+func (t *ThemeConfig) colors() *editor.ThemeColors {
+	return &editor.ThemeColors{
+		Primary:          t.Primary,
+		PrimaryDark:      t.PrimaryDark,
+		Background:       t.Background,
+		NightPrimary:     t.NightPrimary,
+		NightPrimaryDark: t.NightPrimaryDark,
+		NightBackground:  t.NightBackground,
+	}
+}
+
+type NetworkSecurityConfig struct {
+	// CleartextDomains 允许明文http的域名, 包含子域名
+	CleartextDomains []string `yaml:"cleartextDomains" json:"cleartextDomains" toml:"cleartextDomains"`
+	// Pins 证书公钥固定, 如 api.example.com=sha256,sha256@2027-01-01
+	Pins []string `yaml:"pins" json:"pins" toml:"pins"`
+	// DebugUserCAs 只在 debuggable 时信任用户安装的CA
+	DebugUserCAs bool `yaml:"debugUserCAs" json:"debugUserCAs" toml:"debugUserCAs"`
+}
+
+// config 转换为 editor.NetworkSecurityConfig
+func (n *NetworkSecurityConfig) config() (*editor.NetworkSecurityConfig, error) {
+	c := &editor.NetworkSecurityConfig{CleartextDomains: n.CleartextDomains, DebugUserCAs: n.DebugUserCAs}
+	for _, s := range n.Pins {
+		p, err := editor.ParseCertificatePin(s)
+		if err != nil {
+			return nil, err
+		}
+		c.Pins = append(c.Pins, p)
+	}
+	return c, nil
+}
+
+type SplashConfig struct {
+	// Image png/jpeg 启动画面居中的图片, 为空时只显示背景色
+	Image string `yaml:"image" json:"image" toml:"image"`
+	// Background 背景色 #RRGGBB, 为空时使用 theme.background 或白色
+	Background string `yaml:"background" json:"background" toml:"background"`
+	// MinDuration 至少显示的毫秒数
+	MinDuration int `yaml:"minDuration" json:"minDuration" toml:"minDuration"`
+}
+
+func loadBuildConfig(path string) (*BuildConfig, error) {
+	conf := &BuildConfig{}
+	if err := decodeConfig(path, conf); err != nil {
+		return nil, err
+	}
+	conf.dir = filepath.Dir(path)
+	if conf.Input == "" {
+		return nil, fmt.Errorf("%s: input is required", path)
+	}
+	return conf, nil
+}
+
+// decodeConfig 按扩展名解码配置文件后替换字符串值中的环境变量, 不认识的字段视为错误
+func decodeConfig(path string, conf any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(conf)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(conf)
+	case ".toml":
+		var md toml.MetaData
+		md, err = toml.NewDecoder(bytes.NewReader(data)).Decode(conf)
+		if err == nil && len(md.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown fields %v", md.Undecoded())
+		}
+	default:
+		return fmt.Errorf("%s: unsupported config format %q, use yaml, json or toml", path, ext)
+	}
+	if err == nil {
+		err = expandEnv(reflect.ValueOf(conf))
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// expandEnv 替换解码后所有字符串值中的 ${VAR}, $VAR 和 ${VAR:-default},
+// 注释和键不替换, 未设置且没有默认值的变量视为错误
+func expandEnv(v reflect.Value) error {
+	var missing []string
+	expandValue(v, func(s string) string {
+		return os.Expand(s, func(name string) string {
+			if name == "$" {
+				return "$"
+			}
+			name, def, hasDef := strings.Cut(name, ":-")
+			if v, ok := os.LookupEnv(name); ok && (v != "" || !hasDef) {
+				return v
+			}
+			if hasDef {
+				return def
+			}
+			missing = append(missing, name)
+			return ""
+		})
+	})
+	if len(missing) > 0 {
+		return fmt.Errorf("environment variables not set: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// expandValue 对 v 中可修改的字符串调用 expand, 递归进入指针, 结构体, 切片和map的值
+func expandValue(v reflect.Value, expand func(string) string) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			expandValue(v.Elem(), expand)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				expandValue(v.Field(i), expand)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			expandValue(v.Index(i), expand)
+		}
+	case reflect.Map:
+		// map的值不可寻址, 复制后写回
+		iter := v.MapRange()
+		for iter.Next() {
+			e := reflect.New(iter.Value().Type()).Elem()
+			e.Set(iter.Value())
+			expandValue(e, expand)
+			v.SetMapIndex(iter.Key(), e)
+		}
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(expand(v.String()))
+		}
+	}
+}
+
+// path 把配置中的相对路径转换为相对于配置文件的路径
+func (c *BuildConfig) path(p string) string {
+	if p == "" || filepath.IsAbs(p) || strings.HasPrefix(p, "http") {
+		return p
+	}
+	return filepath.Join(c.dir, p)
+}
+
+// readFile 读取配置中的文件, 为空时从内置文件中读取
+func (c *BuildConfig) readFile(p, embedded string) ([]byte, error) {
+	if p == "" {
+		return embedFiles.ReadFile(embedded)
+	}
+	return os.ReadFile(c.path(p))
+}
+
+// editor 创建配置的 apkEditor, 模板为 split apk 集合时同时返回集合
+func (c *BuildConfig) editor() (*editor.ApkEditor, *editor.ApkSet, error) {
+	var apkEditor *editor.ApkEditor
+	var set *editor.ApkSet
+	if c.Template != "" && isApkSet(c.path(c.Template)) {
+		key, crt, err := c.signing()
+		if err != nil {
+			return nil, nil, err
+		}
+		if set, err = readApkSet(c.path(c.Template)); err != nil {
+			return nil, nil, err
+		}
+		apkEditor = editor.NewApkEditor(nil, key, crt)
+	} else {
+		tpl, err := c.template()
+		if err != nil {
+			return nil, nil, err
+		}
+		apkEditor = tpl.NewEditor()
+	}
+	if err := c.apply(apkEditor); err != nil {
+		return nil, nil, err
+	}
+	return apkEditor, set, nil
+}
+
+// template 读取并解析模板apk和签名密钥
+func (c *BuildConfig) template() (*editor.Template, error) {
+	if c.Template != "" && isApkSet(c.path(c.Template)) {
+		return nil, errors.New("template: apk sets are only supported by build")
+	}
+	apk, err := c.readFile(c.Template, "release/app-release.apk")
+	if err != nil {
+		return nil, err
+	}
+	key, crt, err := c.signing()
+	if err != nil {
+		return nil, err
+	}
+	return editor.NewTemplate(apk, key, crt)
+}
+
+// signing 读取签名密钥和证书, 为空时使用内置的密钥
+func (c *BuildConfig) signing() ([]byte, []byte, error) {
+	if (c.Signing.Key == "") != (c.Signing.Cert == "") {
+		return nil, nil, errors.New("signing: key and cert must be set together")
+	}
+	key, err := c.readFile(c.Signing.Key, "release/signing.key")
+	if err != nil {
+		return nil, nil, err
+	}
+	crt, err := c.readFile(c.Signing.Cert, "release/signing.crt")
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, crt, nil
+}
+
+// apply 把配置中的输入, manifest, 图标, WebView, 主题颜色, 启动画面, 网络安全配置和 zip 设置写入 apkEditor
+func (c *BuildConfig) apply(apkEditor *editor.ApkEditor) error {
+	if err := setInput(apkEditor, c.path(c.Input)); err != nil {
+		return err
+	}
+	manifest, err := c.Manifest.manifest()
+	if err != nil {
+		return err
+	}
+	apkEditor.Manifest = manifest
+	if c.ManifestXML != "" {
+		if apkEditor.ManifestXML, err = os.ReadFile(c.path(c.ManifestXML)); err != nil {
+			return err
+		}
+	}
+	if c.Icon != "" {
+		icon, err := os.ReadFile(c.path(c.Icon))
+		if err != nil {
+			return err
+		}
+		apkEditor.Icon = icon
+	}
+	if w := c.WebView; w != nil {
+		apkEditor.WebView = &editor.WebViewConfig{
+			UserAgent:       w.UserAgent,
+			JavaScript:      w.JavaScript,
+			Zoom:            w.Zoom,
+			SwipeRefresh:    w.SwipeRefresh,
+			Fullscreen:      w.Fullscreen,
+			IgnoreSslErrors: w.IgnoreSslErrors,
+		}
+	}
+	if c.Theme != nil {
+		apkEditor.Theme = c.Theme.colors()
+	}
+	if s := c.Splash; s != nil {
+		apkEditor.Splash = &editor.Splash{Background: s.Background, MinDuration: s.MinDuration}
+		if s.Image != "" {
+			if apkEditor.Splash.Image, err = os.ReadFile(c.path(s.Image)); err != nil {
+				return err
+			}
+		}
+	}
+	if c.NetworkSecurity != nil {
+		if apkEditor.NetworkSecurity, err = c.NetworkSecurity.config(); err != nil {
+			return err
+		}
+	}
+	if c.Descriptor != "" {
+		d, err := readDescriptor(c.path(c.Descriptor))
+		if err != nil {
+			return err
+		}
+		apkEditor.Descriptor = d
+	}
+	apkEditor.Store = c.Zip.Store
+	apkEditor.Align = c.Zip.Align
+	apkEditor.Comment = c.Zip.Comment
+	apkEditor.Remove = c.Zip.Remove
+	apkEditor.V4 = c.Signing.V4
+	return nil
+}
+
+func readDescriptor(p string) (*editor.Descriptor, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	d, err := editor.ParseDescriptor(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	return d, nil
+}
@@ -0,0 +1,51 @@
+package editor
+
+import (
+	"bytes"
+
+	"github.com/pzx521521/apk-editor/editor/zip"
+)
+
+// realign 检查apk中不压缩文件的数据是否按 align 字节对齐, 与 zipalign -c 相同.
+// 追加的文件写入时已经对齐, 模板原有的文件只有在模板的对齐小于 align 时才需要重写整个apk
+func realign(apk []byte, align int) ([]byte, error) {
+	if align <= 1 {
+		return apk, nil
+	}
+	r, err := zip.NewReader(bytes.NewReader(apk), int64(len(apk)))
+	if err != nil {
+		return nil, err
+	}
+	aligned := true
+	for _, f := range r.File {
+		if f.Method != zip.Store {
+			continue
+		}
+		offset, err := f.DataOffset()
+		if err != nil {
+			return nil, err
+		}
+		if offset%int64(align) != 0 {
+			aligned = false
+			break
+		}
+	}
+	if aligned {
+		return apk, nil
+	}
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	w.SetAlignment(align)
+	if err := w.SetComment(r.Comment); err != nil {
+		return nil, err
+	}
+	for _, f := range r.File {
+		if err := w.Copy(f); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
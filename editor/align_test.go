@@ -0,0 +1,69 @@
+package editor
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/pzx521521/apk-editor/editor/signv2"
+	"github.com/pzx521521/apk-editor/editor/zip"
+)
+
+func TestEditAlign(t *testing.T) {
+	a := newTestEditor(t)
+	a.Url = "https://example.com"
+	a.Comment = "aligned"
+	// 模板按4字节对齐, 16字节对齐需要重写模板原有的文件
+	a.Align = 16
+	var stages []string
+	a.OnStage = func(stage string, _ time.Duration) { stages = append(stages, stage) }
+	apk := mustEdit(t, a)
+	want := []string{StageMerge, StageManifest, StageResources, StageFinalize, StageAlign, StageSign}
+	if len(stages) != len(want) {
+		t.Fatalf("stages %v, want %v", stages, want)
+	}
+	for i := range want {
+		if stages[i] != want[i] {
+			t.Fatalf("stages %v, want %v", stages, want)
+		}
+	}
+	r, err := zip.NewReader(bytes.NewReader(apk), int64(len(apk)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Comment != a.Comment {
+		t.Errorf("comment %q", r.Comment)
+	}
+	for _, f := range r.File {
+		if f.Method != zip.Store {
+			continue
+		}
+		offset, err := f.DataOffset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if offset%16 != 0 {
+			t.Errorf("%s at %d", f.Name, offset)
+		}
+	}
+	z, err := signv2.NewApkSign(apk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := z.VerifyV2(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRealignUnchanged(t *testing.T) {
+	a := newTestEditor(t)
+	a.Url = "https://example.com"
+	apk := mustEdit(t, a)
+	out, err := realign(apk, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &out[0] != &apk[0] {
+		t.Error("aligned apk was rewritten")
+	}
+}
@@ -0,0 +1,305 @@
+package editor
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+	"github.com/pzx521521/apk-editor/editor/zip"
+)
+
+// AndroidManifest 是 ReadManifest 读取的 AndroidManifest.xml.
+// 资源引用会通过 resources.arsc 解析: @string 引用解析为字符串, 其他引用写为 @type/name
+type AndroidManifest struct {
+	Package           string           `json:"package"`
+	VersionCode       uint32           `json:"version_code"`
+	VersionName       string           `json:"version_name,omitempty"`
+	MinSdkVersion     int              `json:"min_sdk_version,omitempty"`
+	TargetSdkVersion  int              `json:"target_sdk_version,omitempty"`
+	MaxSdkVersion     int              `json:"max_sdk_version,omitempty"`
+	CompileSdkVersion int              `json:"compile_sdk_version,omitempty"`
+	Permissions       []UsesPermission `json:"permissions,omitempty"`
+	Features          []UsesFeature    `json:"features,omitempty"`
+	Application       Application      `json:"application"`
+}
+
+// UsesPermission 是 <uses-permission>
+type UsesPermission struct {
+	Name          string `json:"name"`
+	MaxSdkVersion int    `json:"max_sdk_version,omitempty"`
+}
+
+// UsesFeature 是 <uses-feature>, 没有写 required 时为true
+type UsesFeature struct {
+	Name        string `json:"name,omitempty"`
+	Required    bool   `json:"required"`
+	GlEsVersion uint32 `json:"gl_es_version,omitempty"`
+}
+
+// Application 是 <application>, Attributes 包含所有属性
+type Application struct {
+	Name                  string            `json:"name,omitempty"`
+	Label                 string            `json:"label,omitempty"`
+	Icon                  string            `json:"icon,omitempty"`
+	RoundIcon             string            `json:"round_icon,omitempty"`
+	Theme                 string            `json:"theme,omitempty"`
+	Debuggable            bool              `json:"debuggable"`
+	AllowBackup           bool              `json:"allow_backup"`
+	UsesCleartextTraffic  bool              `json:"uses_cleartext_traffic"`
+	NetworkSecurityConfig string            `json:"network_security_config,omitempty"`
+	Attributes            map[string]string `json:"attributes,omitempty"`
+	MetaData              []MetaData        `json:"meta_data,omitempty"`
+	Activities            []Component       `json:"activities,omitempty"`
+	Services              []Component       `json:"services,omitempty"`
+	Receivers             []Component       `json:"receivers,omitempty"`
+	Providers             []Component       `json:"providers,omitempty"`
+}
+
+// Component 是 activity, activity-alias, service, receiver 或 provider
+type Component struct {
+	Type          string            `json:"type"`
+	Name          string            `json:"name"`
+	Exported      *bool             `json:"exported,omitempty"`
+	Attributes    map[string]string `json:"attributes,omitempty"`
+	IntentFilters []IntentFilter    `json:"intent_filters,omitempty"`
+	MetaData      []MetaData        `json:"meta_data,omitempty"`
+}
+
+// IntentFilter 是 <intent-filter>
+type IntentFilter struct {
+	AutoVerify bool         `json:"auto_verify,omitempty"`
+	Actions    []string     `json:"actions,omitempty"`
+	Categories []string     `json:"categories,omitempty"`
+	Data       []IntentData `json:"data,omitempty"`
+}
+
+// IntentData 是 intent-filter 中的 <data>
+type IntentData struct {
+	Scheme      string `json:"scheme,omitempty"`
+	Host        string `json:"host,omitempty"`
+	Port        string `json:"port,omitempty"`
+	Path        string `json:"path,omitempty"`
+	PathPrefix  string `json:"path_prefix,omitempty"`
+	PathPattern string `json:"path_pattern,omitempty"`
+	MimeType    string `json:"mime_type,omitempty"`
+}
+
+// MetaData 是 <meta-data>, Value 和 Resource 只有一个有值
+type MetaData struct {
+	Name     string `json:"name"`
+	Value    string `json:"value,omitempty"`
+	Resource string `json:"resource,omitempty"`
+}
+
+// ReadManifest 读取apk中 AndroidManifest.xml 声明的信息
+func ReadManifest(apk io.ReaderAt, size int64) (*AndroidManifest, error) {
+	r, err := zip.NewReader(apk, size)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := readManifest(r)
+	if err != nil {
+		return nil, err
+	}
+	x, err := res.ParseXML(raw)
+	if err != nil {
+		return nil, err
+	}
+	var table *res.Table
+	if arsc, err := readEntry(r, RESOURCES_ARSC); err == nil {
+		if table, err = res.ParseTable(arsc); err != nil {
+			return nil, err
+		}
+	}
+	return newAndroidManifest(x, table), nil
+}
+
+func newAndroidManifest(x *res.XML, table *res.Table) *AndroidManifest {
+	v := &manifestValues{table}
+	root := x.Root
+	m := &AndroidManifest{
+		Package:           v.str(root.Attr("", "package")),
+		VersionCode:       uint32(v.int(root.AndroidAttr(res.AttrVersionCode))),
+		VersionName:       v.str(root.AndroidAttr(res.AttrVersionName)),
+		CompileSdkVersion: v.int(root.AndroidAttr(res.AttrCompileSdkVersion)),
+	}
+	if sdk := root.Child("uses-sdk"); sdk != nil {
+		m.MinSdkVersion = v.int(sdk.AndroidAttr(res.AttrMinSdkVersion))
+		m.TargetSdkVersion = v.int(sdk.AndroidAttr(res.AttrTargetSdkVersion))
+		m.MaxSdkVersion = v.int(sdk.AndroidAttr(res.AttrMaxSdkVersion))
+	}
+	for _, e := range root.Children {
+		switch e.Name {
+		case "uses-permission", "uses-permission-sdk-23":
+			m.Permissions = append(m.Permissions, UsesPermission{
+				Name:          v.str(e.AndroidAttr(res.AttrName)),
+				MaxSdkVersion: v.int(e.AndroidAttr(res.AttrMaxSdkVersion)),
+			})
+		case "uses-feature":
+			f := UsesFeature{Name: v.str(e.AndroidAttr(res.AttrName)), Required: true}
+			if a := e.AndroidAttr(res.AttrRequired); a != nil {
+				f.Required = v.bool(a)
+			}
+			if a := e.AndroidAttr(res.AttrGlEsVersion); a != nil {
+				f.GlEsVersion = a.Value.Data
+			}
+			m.Features = append(m.Features, f)
+		}
+	}
+	app := root.Child("application")
+	if app == nil {
+		return m
+	}
+	m.Application = Application{
+		Name:                  v.str(app.AndroidAttr(res.AttrName)),
+		Label:                 v.str(app.AndroidAttr(res.AttrLabel)),
+		Icon:                  v.str(app.AndroidAttr(res.AttrIcon)),
+		RoundIcon:             v.str(app.AndroidAttr(res.AttrRoundIcon)),
+		Theme:                 v.str(app.AndroidAttr(res.AttrTheme)),
+		Debuggable:            v.bool(app.AndroidAttr(res.AttrDebuggable)),
+		AllowBackup:           true,
+		UsesCleartextTraffic:  m.TargetSdkVersion < 28,
+		NetworkSecurityConfig: v.str(app.AndroidAttr(res.AttrNetworkSecurityConfig)),
+		Attributes:            v.attrs(app),
+		MetaData:              v.metaData(app),
+	}
+	if a := app.AndroidAttr(res.AttrAllowBackup); a != nil {
+		m.Application.AllowBackup = v.bool(a)
+	}
+	if a := app.AndroidAttr(res.AttrUsesCleartextTraffic); a != nil {
+		m.Application.UsesCleartextTraffic = v.bool(a)
+	}
+	for _, e := range app.Children {
+		if !componentElements[e.Name] || e.Name == "application" || e.Name == "instrumentation" {
+			continue
+		}
+		c := Component{
+			Type:       e.Name,
+			Name:       v.str(e.AndroidAttr(res.AttrName)),
+			Attributes: v.attrs(e),
+			MetaData:   v.metaData(e),
+		}
+		if a := e.AndroidAttr(res.AttrExported); a != nil {
+			exported := v.bool(a)
+			c.Exported = &exported
+		}
+		for _, f := range e.ChildrenNamed("intent-filter") {
+			c.IntentFilters = append(c.IntentFilters, v.intentFilter(f))
+		}
+		switch e.Name {
+		case "activity", "activity-alias":
+			m.Application.Activities = append(m.Application.Activities, c)
+		case "service":
+			m.Application.Services = append(m.Application.Services, c)
+		case "receiver":
+			m.Application.Receivers = append(m.Application.Receivers, c)
+		case "provider":
+			m.Application.Providers = append(m.Application.Providers, c)
+		}
+	}
+	return m
+}
+
+// manifestValues 把属性值转换为文本, table 为nil时不解析资源引用
+type manifestValues struct {
+	table *res.Table
+}
+
+func (v *manifestValues) str(a *res.Attr) string {
+	if a == nil {
+		return ""
+	}
+	if a.Value.Type != res.TypeReference || v.table == nil {
+		return a.String()
+	}
+	name, ok := v.table.Name(a.Value.Data)
+	if !ok {
+		return a.String()
+	}
+	if strings.HasPrefix(name, "string/") {
+		if s, ok := v.table.String(v.table.Resolve(a.Value)); ok {
+			return s
+		}
+	}
+	return "@" + name
+}
+
+func (v *manifestValues) int(a *res.Attr) int {
+	if a == nil {
+		return 0
+	}
+	val := a.Value
+	if v.table != nil {
+		val = v.table.Resolve(val)
+	}
+	switch val.Type {
+	case res.TypeIntDec, res.TypeIntHex:
+		return int(int32(val.Data))
+	case res.TypeString:
+		var i int
+		fmt.Sscan(a.Raw, &i)
+		return i
+	}
+	return 0
+}
+
+func (v *manifestValues) bool(a *res.Attr) bool {
+	if a == nil {
+		return false
+	}
+	val := a.Value
+	if v.table != nil {
+		val = v.table.Resolve(val)
+	}
+	if val.Type == res.TypeString {
+		return a.Raw == "true"
+	}
+	return val.Data != 0
+}
+
+func (v *manifestValues) attrs(e *res.Element) map[string]string {
+	if len(e.Attrs) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(e.Attrs))
+	for _, a := range e.Attrs {
+		m[a.Name] = v.str(a)
+	}
+	return m
+}
+
+func (v *manifestValues) metaData(e *res.Element) []MetaData {
+	var ret []MetaData
+	for _, c := range e.ChildrenNamed("meta-data") {
+		ret = append(ret, MetaData{
+			Name:     v.str(c.AndroidAttr(res.AttrName)),
+			Value:    v.str(c.AndroidAttr(res.AttrValue)),
+			Resource: v.str(c.AndroidAttr(res.AttrResource)),
+		})
+	}
+	return ret
+}
+
+func (v *manifestValues) intentFilter(e *res.Element) IntentFilter {
+	f := IntentFilter{AutoVerify: v.bool(e.AndroidAttr(res.AttrAutoVerify))}
+	for _, c := range e.Children {
+		switch c.Name {
+		case "action":
+			f.Actions = append(f.Actions, v.str(c.AndroidAttr(res.AttrName)))
+		case "category":
+			f.Categories = append(f.Categories, v.str(c.AndroidAttr(res.AttrName)))
+		case "data":
+			f.Data = append(f.Data, IntentData{
+				Scheme:      v.str(c.AndroidAttr(res.AttrScheme)),
+				Host:        v.str(c.AndroidAttr(res.AttrHost)),
+				Port:        v.str(c.AndroidAttr(res.AttrPort)),
+				Path:        v.str(c.AndroidAttr(res.AttrPath)),
+				PathPrefix:  v.str(c.AndroidAttr(res.AttrPathPrefix)),
+				PathPattern: v.str(c.AndroidAttr(res.AttrPathPattern)),
+				MimeType:    v.str(c.AndroidAttr(res.AttrMimeType)),
+			})
+		}
+	}
+	return f
+}
@@ -0,0 +1,50 @@
+package editor
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReadManifest(t *testing.T) {
+	apk, _, _ := templateFiles(t)
+	m, err := ReadManifest(bytes.NewReader(apk), int64(len(apk)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Package != "com.parap.webview" || m.VersionCode != 111 || m.VersionName != "111.111.111" {
+		t.Errorf("manifest %+v", m)
+	}
+	if m.MinSdkVersion != 24 || m.TargetSdkVersion != 31 {
+		t.Errorf("sdk %d %d", m.MinSdkVersion, m.TargetSdkVersion)
+	}
+	if len(m.Permissions) != 2 || m.Permissions[0].Name != "android.permission.INTERNET" {
+		t.Errorf("permissions %+v", m.Permissions)
+	}
+	app := m.Application
+	if app.Label != "WebViewDemo" || app.Icon != "@mipmap/ic_launcher" || app.NetworkSecurityConfig != "@xml/network_security_config" {
+		t.Errorf("application %+v", app)
+	}
+	if !app.AllowBackup || !app.UsesCleartextTraffic || app.Debuggable {
+		t.Errorf("application flags %+v", app)
+	}
+	if len(app.Activities) != 1 {
+		t.Fatalf("activities %+v", app.Activities)
+	}
+	a := app.Activities[0]
+	if a.Name != "com.parap.webview.MainActivity" || a.Exported == nil || !*a.Exported {
+		t.Errorf("activity %+v", a)
+	}
+	if len(a.IntentFilters) != 1 || a.IntentFilters[0].Actions[0] != "android.intent.action.MAIN" {
+		t.Errorf("intent filters %+v", a.IntentFilters)
+	}
+}
+
+// manifestOf 返回apk中 AndroidManifest.xml 的类型化视图
+func manifestOf(t *testing.T, apk []byte) *AndroidManifest {
+	t.Helper()
+	m, err := ReadManifest(bytes.NewReader(apk), int64(len(apk)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
@@ -0,0 +1,338 @@
+package editor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+	"github.com/pzx521521/apk-editor/editor/signv2"
+	"github.com/pzx521521/apk-editor/editor/zip"
+)
+
+// XAPK_MANIFEST .xapk 中描述包名, 版本和 split 的文件
+const XAPK_MANIFEST = "manifest.json"
+
+// ApkSet 是 base apk 和 config/feature split 组成的apk集合, 来自 bundletool 的 .apks, .xapk 或一个目录
+type ApkSet struct {
+	Apks []*SplitApk
+	// Files 集合中的其他文件(toc.pb, manifest.json, icon.png, obb 等), 除 .xapk 的 manifest.json 外原样写回
+	Files []*MergeEntry
+}
+
+// SplitApk 是集合中的一个apk
+type SplitApk struct {
+	// Name 在集合中的路径, 如 splits/base-master.apk
+	Name string
+	// Split manifest 的 split 属性, base 为空
+	Split string
+	Data  []byte
+}
+
+// NewApkSet 按 manifest 的 split 属性从 files 中找出 base 和 split, 其他文件原样保留.
+// 集合中必须只有一个 base, bundletool 为旧系统生成的 standalones/ 不支持
+func NewApkSet(files []*MergeEntry) (*ApkSet, error) {
+	s := &ApkSet{}
+	bases := 0
+	for _, f := range files {
+		if !IsLocalName(f.Name) {
+			return nil, fmt.Errorf("apk set entry %q is not a relative path inside the set", f.Name)
+		}
+		// 修改后原来的v4签名失效, 不保留
+		if path.Ext(f.Name) == ".idsig" {
+			continue
+		}
+		if path.Ext(f.Name) != ".apk" {
+			s.Files = append(s.Files, f)
+			continue
+		}
+		split, err := splitName(f.Data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		if split == "" {
+			bases++
+		}
+		s.Apks = append(s.Apks, &SplitApk{Name: f.Name, Split: split, Data: f.Data})
+	}
+	switch bases {
+	case 0:
+		return nil, errors.New("apk set has no base apk")
+	case 1:
+		return s, nil
+	}
+	return nil, errors.New("apk set has more than one base apk, standalone apks are not supported")
+}
+
+// ReadApkSet 读取 .apks 或 .xapk
+func ReadApkSet(b []byte) (*ApkSet, error) {
+	r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	var files []*MergeEntry
+	for _, f := range r.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, &MergeEntry{f.Name, data})
+	}
+	return NewApkSet(files)
+}
+
+// IsLocalName 判断集合中的文件名是否为集合内的相对路径(使用 / 分隔), 防止写入目录时写到目录外
+func IsLocalName(name string) bool {
+	if !filepath.IsLocal(name) || strings.Contains(name, `\`) {
+		return false
+	}
+	for _, e := range strings.Split(name, "/") {
+		if e == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// splitName 返回apk的manifest中的 split 属性
+func splitName(apk []byte) (string, error) {
+	r, err := zip.NewReader(bytes.NewReader(apk), int64(len(apk)))
+	if err != nil {
+		return "", err
+	}
+	manifest, err := readManifest(r)
+	if err != nil {
+		return "", err
+	}
+	x, err := res.ParseXML(manifest)
+	if err != nil {
+		return "", err
+	}
+	return attrString(x.Root.Attr("", "split")), nil
+}
+
+// Base 返回 base apk
+func (s *ApkSet) Base() *SplitApk {
+	for _, a := range s.Apks {
+		if a.Split == "" {
+			return a
+		}
+	}
+	return nil
+}
+
+// Entries 返回集合中的所有文件, apk 在前
+func (s *ApkSet) Entries() []*MergeEntry {
+	var entries []*MergeEntry
+	for _, a := range s.Apks {
+		entries = append(entries, &MergeEntry{a.Name, a.Data})
+	}
+	return append(entries, s.Files...)
+}
+
+// Zip 把集合写为 .apks/.xapk, apk 不压缩
+func (s *ApkSet) Zip() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for _, e := range s.Entries() {
+		header := &zip.FileHeader{Name: e.Name, Method: zip.Deflate}
+		if path.Ext(e.Name) == ".apk" {
+			header.Method = zip.Store
+		}
+		header.SetMode(0o666)
+		f, err := w.CreateHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = f.Write(e.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EditSet 把 a 的修改应用到集合的 base 上, 与 Edit 相同; 每个 split 的 manifest 的包名和 versionCode 改为与 base 一致,
+// 替换了图标时 split 中各密度的图标也一起替换, 然后用同一个密钥重新签名所有apk. .xapk 的 manifest.json 同步更新.
+// V4 为true时每个apk的v4签名 <apk>.idsig 放在 Files 的最后
+func (a *ApkEditor) EditSet(s *ApkSet) (*ApkSet, error) {
+	base := s.Base()
+	if base == nil {
+		return nil, errors.New("apk set has no base apk")
+	}
+	keys, err := a.signingKeys()
+	if err != nil {
+		return nil, &StageError{StageMerge, err}
+	}
+	t, err := newTemplate(base.Data, keys)
+	if err != nil {
+		return nil, &StageError{StageMerge, fmt.Errorf("%s: %w", base.Name, err)}
+	}
+	saved := a.template
+	a.template = t
+	edited, err := a.Edit()
+	a.template = saved
+	if err != nil {
+		return nil, err
+	}
+	r, err := zip.NewReader(bytes.NewReader(edited), int64(len(edited)))
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := readManifest(r)
+	if err != nil {
+		return nil, err
+	}
+	x, err := res.ParseXML(manifest)
+	if err != nil {
+		return nil, err
+	}
+	sync := &Manifest{Package: attrString(x.Root.Attr("", "package"))}
+	if v := x.Root.AndroidAttr(res.AttrVersionCode); v != nil && v.Value.Type != res.TypeString {
+		sync.VersionCode = v.Value.Data
+	}
+	d := a.Descriptor
+	if d == nil {
+		d = t.descriptor
+	}
+	icons := d.withDefaults().Icons
+	out := &ApkSet{}
+	var idsigs []*MergeEntry
+	for _, apk := range s.Apks {
+		data, idsig := edited, a.IDSig
+		if apk != base {
+			if data, err = a.editSplit(apk.Data, sync, icons, keys); err != nil {
+				return nil, &StageError{StageSign, fmt.Errorf("%s: %w", apk.Name, err)}
+			}
+			if a.V4 {
+				if idsig, err = signV4(data, keys); err != nil {
+					return nil, &StageError{StageSign, fmt.Errorf("%s: %w", apk.Name, err)}
+				}
+			}
+		}
+		out.Apks = append(out.Apks, &SplitApk{Name: apk.Name, Split: apk.Split, Data: data})
+		if a.V4 {
+			idsigs = append(idsigs, &MergeEntry{apk.Name + ".idsig", idsig})
+		}
+	}
+	for _, f := range s.Files {
+		if f.Name == XAPK_MANIFEST {
+			b, err := xapkManifest(f.Data, x, sync)
+			if err != nil {
+				return nil, &StageError{StageFinalize, fmt.Errorf("%s: %w", XAPK_MANIFEST, err)}
+			}
+			f = &MergeEntry{f.Name, b}
+		}
+		out.Files = append(out.Files, f)
+	}
+	out.Files = append(out.Files, idsigs...)
+	return out, nil
+}
+
+// signingKeys 返回模板的签名密钥, 没有模板时加载 NewApkEditor 传入的密钥
+func (a *ApkEditor) signingKeys() ([]*signv2.SigningCert, error) {
+	if a.template != nil {
+		return a.template.keys, nil
+	}
+	return signingKeys(a.keyBytes, a.certBytes)
+}
+
+// editSplit 修改 split 的 manifest 的包名和 versionCode, 替换其中的启动图标, 然后重新签名
+func (a *ApkEditor) editSplit(apk []byte, sync *Manifest, icons []string, keys []*signv2.SigningCert) ([]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(apk), int64(len(apk)))
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := readManifest(r)
+	if err != nil {
+		return nil, err
+	}
+	x, err := res.ParseXML(manifest)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	buf.Write(apk[:r.AppendOffset()])
+	w := r.Append(buf, true)
+	w.SetAlignment(4)
+	if _, err := sync.Apply(x, nil); err != nil {
+		return nil, err
+	}
+	mergeEntries := []*MergeEntry{{zip.ANDROIDMANIFEST, x.Marshal()}}
+	arsc, table, err := readTable(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(a.Icon) > 0 && table != nil && hasResource(table, icons) {
+		icon, err := a.iconContent(table, icons)
+		if err != nil {
+			return nil, err
+		}
+		mergeEntries = append(mergeEntries, icon...)
+		if b := table.Marshal(); !bytes.Equal(b, arsc) {
+			mergeEntries = append(mergeEntries, &MergeEntry{RESOURCES_ARSC, b})
+		}
+	}
+	if err := a.merge(w, mergeEntries...); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return sign(buf.Bytes(), keys)
+}
+
+// hasResource 判断 table 中是否有 names 中的任一资源
+func hasResource(table *res.Table, names []string) bool {
+	for _, name := range names {
+		if _, ok := table.Find(name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// xapkManifest 把 .xapk 的 manifest.json 中的包名, 版本, 应用名和 min/target SDK 改为修改后的 base 的值, 其他字段不变
+func xapkManifest(b []byte, x *res.XML, sync *Manifest) ([]byte, error) {
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	m["package_name"] = sync.Package
+	if sync.VersionCode != 0 {
+		m["version_code"] = fmt.Sprint(sync.VersionCode)
+	}
+	if v := x.Root.AndroidAttr(res.AttrVersionName); v != nil {
+		m["version_name"] = v.String()
+	}
+	if app := x.Root.Child("application"); app != nil {
+		if l := app.AndroidAttr(res.AttrLabel); l != nil && l.Value.Type == res.TypeString {
+			m["name"] = l.Raw
+		}
+	}
+	if sdk := x.Root.Child("uses-sdk"); sdk != nil {
+		v := &manifestValues{}
+		if min := v.int(sdk.AndroidAttr(res.AttrMinSdkVersion)); min > 0 {
+			m["min_sdk_version"] = fmt.Sprint(min)
+		}
+		if target := v.int(sdk.AndroidAttr(res.AttrTargetSdkVersion)); target > 0 {
+			m["target_sdk_version"] = fmt.Sprint(target)
+		}
+	}
+	return json.MarshalIndent(m, "", "  ")
+}
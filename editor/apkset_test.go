@@ -0,0 +1,139 @@
+package editor
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+	"github.com/pzx521521/apk-editor/editor/signv2"
+	"github.com/pzx521521/apk-editor/editor/zip"
+)
+
+// testSplit 用模板的manifest生成一个只有manifest的 config split
+func testSplit(t *testing.T, split string) []byte {
+	t.Helper()
+	x, err := res.ParseXML(templateManifest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	x.Root.SetAttr(res.StringAttr("", "split", 0, split))
+	x.Root.Children = nil
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	f, err := w.Create(zip.ANDROIDMANIFEST)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(x.Marshal())
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEditSet(t *testing.T) {
+	apk, key, crt := templateFiles(t)
+	files := []*MergeEntry{
+		{"com.parap.webview.apk", apk},
+		{"config.xxhdpi.apk", testSplit(t, "config.xxhdpi")},
+		{XAPK_MANIFEST, []byte(`{"package_name":"com.parap.webview","version_code":"111","min_sdk_version":"24","target_sdk_version":"31","split_apks":[{"file":"config.xxhdpi.apk","id":"config.xxhdpi"}]}`)},
+		{"icon.png", []byte("png")},
+	}
+	set, err := NewApkSet(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Base().Name != "com.parap.webview.apk" || set.Apks[1].Split != "config.xxhdpi" {
+		t.Fatalf("base %s, split %q", set.Base().Name, set.Apks[1].Split)
+	}
+	a := NewApkEditor(nil, key, crt)
+	a.Url = "https://example.com"
+	a.Manifest = &Manifest{Package: "com.example.set", VersionCode: 9, Label: "Set", MinSdkVersion: 26}
+	out, err := a.EditSet(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Apks) != 2 || len(out.Files) != 2 {
+		t.Fatalf("%d apks, %d files", len(out.Apks), len(out.Files))
+	}
+	for _, s := range out.Apks {
+		x := apkManifest(t, s.Data)
+		if pkg := attrString(x.Root.Attr("", "package")); pkg != "com.example.set" {
+			t.Errorf("%s: package %s", s.Name, pkg)
+		}
+		if v := x.Root.AndroidAttr(res.AttrVersionCode); v == nil || v.Value.Data != 9 {
+			t.Errorf("%s: versionCode %v", s.Name, v)
+		}
+		if split := attrString(x.Root.Attr("", "split")); split != s.Split {
+			t.Errorf("%s: split %q, want %q", s.Name, split, s.Split)
+		}
+		z, err := signv2.NewApkSign(s.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := z.VerifyV2(); err != nil {
+			t.Errorf("%s: %v", s.Name, err)
+		}
+	}
+	var m map[string]any
+	if err := json.Unmarshal(out.Files[0].Data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["package_name"] != "com.example.set" || m["version_code"] != "9" || m["name"] != "Set" || m["split_apks"] == nil {
+		t.Errorf("manifest.json %v", m)
+	}
+	if m["min_sdk_version"] != "26" || m["target_sdk_version"] != "31" {
+		t.Errorf("manifest.json sdk %v %v", m["min_sdk_version"], m["target_sdk_version"])
+	}
+	if !bytes.Equal(out.Files[1].Data, []byte("png")) {
+		t.Error("other files changed")
+	}
+	// 写为zip后可以再次读取
+	b, err := out.Zip()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ReadApkSet(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Base().Name != "com.parap.webview.apk" || len(again.Apks) != 2 {
+		t.Errorf("read back %d apks", len(again.Apks))
+	}
+}
+
+func TestNewApkSetBases(t *testing.T) {
+	apk, _, _ := templateFiles(t)
+	if _, err := NewApkSet([]*MergeEntry{{"config.xxhdpi.apk", testSplit(t, "config.xxhdpi")}}); err == nil {
+		t.Error("set without base accepted")
+	}
+	if _, err := NewApkSet([]*MergeEntry{{"splits/base-master.apk", apk}, {"standalones/standalone-xxhdpi.apk", apk}}); err == nil {
+		t.Error("set with two bases accepted")
+	}
+}
+
+func TestNewApkSetNames(t *testing.T) {
+	apk, _, _ := templateFiles(t)
+	for _, name := range []string{"../../x.apk", "/tmp/base.apk", "splits/../../icon.png", `..\x.png`} {
+		if _, err := NewApkSet([]*MergeEntry{{"base.apk", apk}, {name, []byte("x")}}); err == nil {
+			t.Errorf("entry %q accepted", name)
+		}
+	}
+	// .apks 中的恶意文件名在读取时被拒绝
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for _, e := range []*MergeEntry{{"base.apk", apk}, {"../evil.txt", []byte("x")}} {
+		f, err := w.Create(e.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(e.Data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadApkSet(buf.Bytes()); err == nil {
+		t.Error("zip-slip entry accepted")
+	}
+}
@@ -0,0 +1,166 @@
+package editor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+)
+
+// screenOrientations 是 android:screenOrientation 的枚举值, softInputModes 是 android:windowSoftInputMode 的标志,
+// state 和 adjust 各选一个
+var (
+	screenOrientations = androidSymbols("screenOrientation")
+	softInputModes     = androidSymbols("windowSoftInputMode")
+)
+
+func androidSymbols(name string) map[string]uint32 {
+	def, _ := res.AndroidAttrDef(name)
+	return def.Symbols
+}
+
+const (
+	softInputStateMask  = 0x0f
+	softInputAdjustMask = 0xf0
+)
+
+// parseSoftInputMode 解析 adjustResize|stateHidden 形式的 windowSoftInputMode
+func parseSoftInputMode(s string) (uint32, error) {
+	var mode uint32
+	var state, adjust bool
+	for _, f := range strings.Split(s, "|") {
+		f = strings.TrimSpace(f)
+		v, ok := softInputModes[f]
+		if !ok {
+			return 0, fmt.Errorf("windowSoftInputMode %q: unknown flag %q", s, f)
+		}
+		kind, seen := "state", &state
+		if strings.HasPrefix(f, "adjust") {
+			kind, seen = "adjust", &adjust
+		}
+		if *seen {
+			return 0, fmt.Errorf("windowSoftInputMode %q: more than one %s flag", s, kind)
+		}
+		*seen = true
+		mode |= v
+	}
+	return mode, nil
+}
+
+// softInputModeString 把 windowSoftInputMode 的值写为 adjustResize|stateHidden 形式
+func softInputModeString(mode uint32) string {
+	var flags []string
+	for name, v := range softInputModes {
+		mask := uint32(softInputStateMask)
+		if strings.HasPrefix(name, "adjust") {
+			mask = softInputAdjustMask
+		}
+		if v != 0 && mode&mask == v {
+			flags = append(flags, name)
+		}
+	}
+	if len(flags) == 0 {
+		return "stateUnspecified"
+	}
+	sort.Strings(flags)
+	return strings.Join(flags, "|")
+}
+
+func screenOrientationString(v uint32) string {
+	for name, o := range screenOrientations {
+		if o == v {
+			return name
+		}
+	}
+	return strconv.Itoa(int(int32(v)))
+}
+
+// checkFlags 检查 screenOrientation 和 windowSoftInputMode 的取值, 返回修改后不会生效的设置
+func (m *Manifest) checkFlags(x *res.XML) ([]string, error) {
+	if _, ok := screenOrientations[m.ScreenOrientation]; m.ScreenOrientation != "" && !ok {
+		return nil, fmt.Errorf("screenOrientation %q: unknown value", m.ScreenOrientation)
+	}
+	if m.WindowSoftInputMode != "" {
+		if _, err := parseSoftInputMode(m.WindowSoftInputMode); err != nil {
+			return nil, err
+		}
+	}
+	var warnings []string
+	app := x.Root.Child("application")
+	if m.UsesCleartextTraffic != nil && app != nil && app.AndroidAttr(res.AttrNetworkSecurityConfig) != nil {
+		warnings = append(warnings, "usesCleartextTraffic is ignored on API 24+ because the template sets android:networkSecurityConfig")
+	}
+	if m.ScreenOrientation != "" && m.ScreenOrientation != "unspecified" && m.ResizeableActivity != nil && *m.ResizeableActivity {
+		warnings = append(warnings, "screenOrientation is ignored in multi-window mode when resizeableActivity is true")
+	}
+	return warnings, nil
+}
+
+// applyFlags 修改 <application> 的布尔属性, 以及所有 <activity> 的 screenOrientation 和 windowSoftInputMode.
+// debuggable=false, screenOrientation=unspecified 和 windowSoftInputMode=stateUnspecified 等于默认值, 删除属性
+func (m *Manifest) applyFlags(root *res.Element) []ManifestChange {
+	var changes []ManifestChange
+	app := root.Child("application")
+	if app == nil {
+		return nil
+	}
+	for _, f := range []struct {
+		name   string
+		id     uint32
+		value  *bool
+		remove bool
+	}{
+		{"debuggable", res.AttrDebuggable, m.Debuggable, m.Debuggable != nil && !*m.Debuggable},
+		{"allowBackup", res.AttrAllowBackup, m.AllowBackup, false},
+		{"usesCleartextTraffic", res.AttrUsesCleartextTraffic, m.UsesCleartextTraffic, false},
+		{"resizeableActivity", res.AttrResizeableActivity, m.ResizeableActivity, false},
+	} {
+		if f.value == nil {
+			continue
+		}
+		changes = append(changes, setAttr(app, f.name, f.name, f.id, res.Bool(*f.value), strconv.FormatBool(*f.value), f.remove)...)
+	}
+	for _, e := range app.ChildrenNamed("activity") {
+		name := attrString(e.AndroidAttr(res.AttrName))
+		if m.ScreenOrientation != "" {
+			v := screenOrientations[m.ScreenOrientation]
+			changes = append(changes, setAttr(e, name+" screenOrientation", "screenOrientation", res.AttrScreenOrientation,
+				res.Value{Type: res.TypeIntDec, Data: v}, m.ScreenOrientation, m.ScreenOrientation == "unspecified")...)
+		}
+		if m.WindowSoftInputMode != "" {
+			v, _ := parseSoftInputMode(m.WindowSoftInputMode)
+			changes = append(changes, setAttr(e, name+" windowSoftInputMode", "windowSoftInputMode", res.AttrWindowSoftInputMode,
+				res.Value{Type: res.TypeIntHex, Data: v}, softInputModeString(v), v == 0)...)
+		}
+	}
+	return changes
+}
+
+// setAttr 把 e 的属性 android:name 设为 v, remove 为true时删除属性. field 和 s 用于记录修改
+func setAttr(e *res.Element, field, name string, id uint32, v res.Value, s string, remove bool) []ManifestChange {
+	old := e.AndroidAttr(id)
+	var oldString string
+	if old != nil {
+		oldString = old.String()
+		switch id {
+		case res.AttrScreenOrientation:
+			oldString = screenOrientationString(old.Value.Data)
+		case res.AttrWindowSoftInputMode:
+			oldString = softInputModeString(old.Value.Data)
+		}
+	}
+	if remove {
+		if old == nil {
+			return nil
+		}
+		e.RemoveAttr(res.AndroidNS, name)
+		return []ManifestChange{{field, oldString, ""}}
+	}
+	if old != nil && old.Value == v {
+		return nil
+	}
+	e.SetAttr(res.ValueAttr(res.AndroidNS, name, id, v))
+	return []ManifestChange{{field, oldString, s}}
+}
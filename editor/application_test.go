@@ -0,0 +1,71 @@
+package editor
+
+import (
+	"testing"
+)
+
+func TestParseSoftInputMode(t *testing.T) {
+	mode, err := parseSoftInputMode("adjustResize|stateHidden")
+	if err != nil || mode != 0x12 {
+		t.Errorf("got %#x %v", mode, err)
+	}
+	if s := softInputModeString(mode); s != "adjustResize|stateHidden" {
+		t.Errorf("string %q", s)
+	}
+	for _, s := range []string{"adjustResize|adjustPan", "stateHidden|stateVisible", "resize"} {
+		if _, err := parseSoftInputMode(s); err == nil {
+			t.Errorf("%s accepted", s)
+		}
+	}
+}
+
+func TestEditFlags(t *testing.T) {
+	yes, no := true, false
+	a := newTestEditor(t)
+	a.Url = "https://example.com"
+	a.Manifest = &Manifest{ScreenOrientation: "sideways"}
+	if _, err := a.Edit(); err == nil {
+		t.Error("unknown screenOrientation accepted")
+	}
+	a.Manifest = &Manifest{
+		Debuggable:           &yes,
+		AllowBackup:          &no,
+		UsesCleartextTraffic: &no,
+		ResizeableActivity:   &no,
+		ScreenOrientation:    "portrait",
+		WindowSoftInputMode:  "adjustResize",
+	}
+	out := mustEdit(t, a)
+	// allowBackup, usesCleartextTraffic 修改, debuggable, resizeableActivity 和 activity 的两个属性添加
+	if len(a.ManifestChanges) != 6 {
+		t.Errorf("changes %v", a.ManifestChanges)
+	}
+	if len(a.Warnings) != 1 {
+		t.Errorf("warnings %v", a.Warnings)
+	}
+	m := manifestOf(t, out)
+	app := m.Application
+	if !app.Debuggable || app.AllowBackup || app.UsesCleartextTraffic || app.Attributes["resizeableActivity"] != "false" {
+		t.Errorf("application %+v", app)
+	}
+	activity := app.Activities[0].Attributes
+	if activity["screenOrientation"] != "1" || activity["windowSoftInputMode"] != "0x10" {
+		t.Errorf("activity %v", activity)
+	}
+
+	// debuggable=false 和 unspecified 删除属性
+	a = NewApkEditor(out, a.keyBytes, a.certBytes)
+	a.Url = "https://example.com"
+	a.Manifest = &Manifest{Debuggable: &no, ScreenOrientation: "unspecified", WindowSoftInputMode: "stateUnspecified"}
+	out = mustEdit(t, a)
+	if len(a.ManifestChanges) != 3 {
+		t.Errorf("changes %v", a.ManifestChanges)
+	}
+	m = manifestOf(t, out)
+	if _, ok := m.Application.Attributes["debuggable"]; ok {
+		t.Error("debuggable not removed")
+	}
+	if _, ok := m.Application.Activities[0].Attributes["screenOrientation"]; ok {
+		t.Error("screenOrientation not removed")
+	}
+}
@@ -0,0 +1,31 @@
+package editor
+
+import (
+	"runtime"
+	"sync"
+)
+
+// EditAll 以最多 workers 个并发构建所有 editors, 每个构建完成后调用 done.
+// done 可能被并发调用. editors 通常由同一个 Template.NewEditor 创建
+func EditAll(editors []*ApkEditor, workers int, done func(i int, apk []byte, err error)) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(editors); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				apk, err := editors[i].Edit()
+				done(i, apk, err)
+			}
+		}()
+	}
+	for i := range editors {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
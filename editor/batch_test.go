@@ -0,0 +1,43 @@
+package editor
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/pzx521521/apk-editor/editor/signv2"
+)
+
+func TestEditAll(t *testing.T) {
+	tpl, err := NewTemplate(templateFiles(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	editors := make([]*ApkEditor, 6)
+	for i := range editors {
+		editors[i] = tpl.NewEditor()
+		editors[i].Url = fmt.Sprintf("https://example.com/%d", i)
+	}
+	var mu sync.Mutex
+	built := map[int]bool{}
+	EditAll(editors, 3, func(i int, apk []byte, err error) {
+		if err != nil {
+			t.Errorf("variant %d: %v", i, err)
+			return
+		}
+		z, err := signv2.NewApkSign(apk)
+		if err != nil {
+			t.Errorf("variant %d: %v", i, err)
+			return
+		}
+		if err := z.VerifyV2(); err != nil {
+			t.Errorf("variant %d: %v", i, err)
+		}
+		mu.Lock()
+		built[i] = true
+		mu.Unlock()
+	})
+	if len(built) != len(editors) {
+		t.Errorf("built %d of %d variants", len(built), len(editors))
+	}
+}
@@ -0,0 +1,148 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+	"github.com/pzx521521/apk-editor/editor/signv2"
+	"github.com/pzx521521/apk-editor/editor/zip"
+)
+
+// aab 中的文件, apk 的内容都放在 base 模块下
+const (
+	BUNDLE_CONFIG   = "BundleConfig.pb"
+	BUNDLE_MANIFEST = "base/manifest/" + zip.ANDROIDMANIFEST
+	BUNDLE_TABLE    = "base/resources.pb"
+)
+
+// BundletoolVersion 写入 BundleConfig.pb 的 bundletool 版本, Google Play 按这个版本的规则从aab生成apk
+const BundletoolVersion = "1.17.2"
+
+// EditBundle 与 Edit 做相同的修改, 输出上传 Google Play 用的 Android App Bundle(.aab).
+// apk 转换为 base 模块: manifest 和 xml 资源转为 aapt2 的 protobuf 格式, resources.arsc 转为 resources.pb,
+// 然后与 jarsigner 一样用 JAR 签名(v1)签名
+func (a *ApkEditor) EditBundle() ([]byte, error) {
+	unsigned, t, start, err := a.build()
+	if err != nil {
+		return nil, err
+	}
+	aab, err := bundle(unsigned, t.keys)
+	if err != nil {
+		return nil, &StageError{StageBundle, err}
+	}
+	a.stageDone(StageBundle, start)
+	return aab, nil
+}
+
+// bundle 把 apk 转换为只有 base 模块的aab, 并用 keys 签名
+func bundle(apk []byte, keys []*signv2.SigningCert) ([]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(apk), int64(len(apk)))
+	if err != nil {
+		return nil, err
+	}
+	_, table, err := readTable(r)
+	if err != nil {
+		return nil, err
+	}
+	xmlFiles := map[string]bool{}
+	if table != nil {
+		for _, f := range table.XMLFiles() {
+			xmlFiles[f] = true
+		}
+	}
+	entries := []signv2.JarEntry{{Name: BUNDLE_CONFIG, Data: bundleConfig()}}
+	for _, f := range r.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		name, data, err := bundleEntry(f.Name, data, table, xmlFiles)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		if name != "" {
+			entries = append(entries, signv2.JarEntry{Name: name, Data: data})
+		}
+	}
+	signature, err := signv2.SignJar(entries, keys)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	// 与 jarsigner 相同, MANIFEST.MF 和签名文件在最前面
+	for _, e := range append(signature, entries...) {
+		header := &zip.FileHeader{Name: e.Name, Method: zip.Deflate}
+		header.SetMode(0o666)
+		f, err := w.CreateHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = f.Write(e.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// bundleEntry 返回 apk 中的文件在aab中的路径和内容, 路径为空表示不放入aab(原来的签名文件)
+func bundleEntry(name string, data []byte, table *res.Table, xmlFiles map[string]bool) (string, []byte, error) {
+	switch {
+	case name == zip.ANDROIDMANIFEST || xmlFiles[name]:
+		x, err := res.ParseXML(data)
+		if err != nil {
+			return "", nil, err
+		}
+		if name == zip.ANDROIDMANIFEST {
+			return BUNDLE_MANIFEST, x.MarshalProto(), nil
+		}
+		return "base/" + name, x.MarshalProto(), nil
+	case name == RESOURCES_ARSC:
+		if table == nil {
+			return "", nil, fmt.Errorf("invalid %s", RESOURCES_ARSC)
+		}
+		return BUNDLE_TABLE, table.MarshalProto(), nil
+	case path.Dir(name) == "." && path.Ext(name) == ".dex":
+		return "base/dex/" + name, data, nil
+	case strings.HasPrefix(name, "res/"), strings.HasPrefix(name, "assets/"), strings.HasPrefix(name, "lib/"):
+		return "base/" + name, data, nil
+	case isSignatureFile(name):
+		return "", nil, nil
+	}
+	// 其他文件(META-INF/services 等java资源)放在 root 中, 生成apk时回到根目录
+	return "base/root/" + name, data, nil
+}
+
+// isSignatureFile 判断是否为 v1 签名的 META-INF/MANIFEST.MF, .SF, .RSA, .DSA 或 .EC 文件
+func isSignatureFile(name string) bool {
+	dir, file := path.Split(name)
+	if dir != "META-INF/" {
+		return false
+	}
+	switch strings.ToUpper(path.Ext(file)) {
+	case ".SF", ".RSA", ".DSA", ".EC":
+		return true
+	}
+	return strings.EqualFold(name, signv2.JarManifest)
+}
+
+// bundleConfig 返回 BundleConfig.pb: 只有 bundletool.version 字段的 BundleConfig
+func bundleConfig() []byte {
+	tool := append([]byte{0x12, byte(len(BundletoolVersion))}, BundletoolVersion...) // Bundletool.version = 2
+	return append([]byte{0x0a, byte(len(tool))}, tool...)                            // BundleConfig.bundletool = 1
+}
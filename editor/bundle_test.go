@@ -0,0 +1,115 @@
+package editor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+	"github.com/pzx521521/apk-editor/editor/signv2"
+	"github.com/pzx521521/apk-editor/editor/zip"
+)
+
+func TestEditBundle(t *testing.T) {
+	a := newTestEditor(t)
+	a.Url = "https://example.com"
+	a.Manifest = &Manifest{VersionCode: 7}
+	var stages []string
+	a.OnStage = func(stage string, _ time.Duration) { stages = append(stages, stage) }
+	aab, err := a.EditBundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := stages[len(stages)-1]; last != StageBundle {
+		t.Errorf("last stage %s", last)
+	}
+	r, err := zip.NewReader(bytes.NewReader(aab), int64(len(aab)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+	for _, name := range []string{BUNDLE_CONFIG, BUNDLE_MANIFEST, BUNDLE_TABLE, "base/dex/classes.dex", "base/assets/url.txt", signv2.JarManifest, "META-INF/CERT.SF", "META-INF/CERT.RSA"} {
+		if files[name] == nil {
+			t.Errorf("%s missing", name)
+		}
+	}
+	for name := range files {
+		if !strings.HasPrefix(name, "base/") && !strings.HasPrefix(name, "META-INF/") && name != BUNDLE_CONFIG {
+			t.Errorf("%s outside the base module", name)
+		}
+	}
+	if string(files["base/assets/url.txt"]) != a.Url {
+		t.Errorf("url.txt = %q", files["base/assets/url.txt"])
+	}
+	// manifest 和 xml 资源是 protobuf, 不再是二进制xml
+	if _, err := res.ParseXML(files[BUNDLE_MANIFEST]); err == nil {
+		t.Error("manifest is still binary xml")
+	}
+	if !bytes.Contains(files[BUNDLE_MANIFEST], []byte("com.parap.webview")) {
+		t.Error("manifest lost the package")
+	}
+	table, err := res.ParseTable(templateEntry(t, RESOURCES_ARSC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range table.XMLFiles() {
+		if files["base/"+f] == nil {
+			t.Fatalf("%s missing", f)
+		}
+		if _, err := res.ParseXML(files["base/"+f]); err == nil {
+			t.Errorf("%s is still binary xml", f)
+		}
+	}
+	// MANIFEST.MF 覆盖除签名文件外的所有文件
+	mf := strings.ReplaceAll(string(files[signv2.JarManifest]), "\r\n ", "")
+	covered := 0
+	for _, section := range strings.Split(mf, "\r\n\r\n")[1:] {
+		if section == "" {
+			continue
+		}
+		name, digest, _ := strings.Cut(strings.TrimPrefix(section, "Name: "), "\r\nSHA-256-Digest: ")
+		sum := sha256.Sum256(files[name])
+		if digest != base64.StdEncoding.EncodeToString(sum[:]) {
+			t.Errorf("%s: digest mismatch", name)
+		}
+		covered++
+	}
+	if covered != len(files)-3 {
+		t.Errorf("manifest covers %d of %d files", covered, len(files)-3)
+	}
+	sum := sha256.Sum256(files[signv2.JarManifest])
+	if !strings.Contains(string(files["META-INF/CERT.SF"]), "SHA-256-Digest-Manifest: "+base64.StdEncoding.EncodeToString(sum[:])+"\r\n") {
+		t.Error("CERT.SF does not match MANIFEST.MF")
+	}
+}
+
+func TestBundleEntry(t *testing.T) {
+	for _, c := range []struct{ name, want string }{
+		{"classes2.dex", "base/dex/classes2.dex"},
+		{"assets/index.html", "base/assets/index.html"},
+		{"lib/arm64-v8a/libx.so", "base/lib/arm64-v8a/libx.so"},
+		{"res/a.png", "base/res/a.png"},
+		{"META-INF/services/x", "base/root/META-INF/services/x"},
+		{"kotlin/x.kotlin_builtins", "base/root/kotlin/x.kotlin_builtins"},
+		{"META-INF/MANIFEST.MF", ""},
+		{"META-INF/CERT.RSA", ""},
+		{"META-INF/KEY0.SF", ""},
+	} {
+		got, _, err := bundleEntry(c.name, nil, nil, nil)
+		if err != nil || got != c.want {
+			t.Errorf("bundleEntry(%s) = %q, %v, want %q", c.name, got, err, c.want)
+		}
+	}
+}
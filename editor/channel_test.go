@@ -0,0 +1,81 @@
+package editor
+
+import (
+	"bytes"
+	"maps"
+	"testing"
+
+	"github.com/pzx521521/apk-editor/editor/signv2"
+)
+
+func TestChannel(t *testing.T) {
+	a := newTestEditor(t)
+	a.Url = "https://example.com"
+	apk := mustEdit(t, a)
+	z, err := signv2.NewApkSign(apk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload, err := signv2.ReadChannel(bytes.NewReader(apk), int64(len(apk))); err != nil || payload != nil {
+		t.Fatalf("channel of a new apk %v, %v", payload, err)
+	}
+	payload := map[string]string{signv2.ChannelKey: "huawei", "campaign": "spring"}
+	withChannel, err := z.SetChannel(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 不重新签名, v2签名仍然有效
+	if z, err = signv2.NewApkSign(withChannel); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.VerifyV2(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := z.Channel(); err != nil || !maps.Equal(got, payload) {
+		t.Errorf("Channel() = %v, %v", got, err)
+	}
+	if got, err := signv2.ReadChannel(bytes.NewReader(withChannel), int64(len(withChannel))); err != nil || !maps.Equal(got, payload) {
+		t.Errorf("ReadChannel() = %v, %v", got, err)
+	}
+
+	// 替换渠道时只有一个渠道信息, WriteChannel 与 SetChannel 相同
+	other := map[string]string{signv2.ChannelKey: "xiaomi"}
+	buf := new(bytes.Buffer)
+	if err := z.WriteChannel(buf, other); err != nil {
+		t.Fatal(err)
+	}
+	replaced, err := z.SetChannel(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), replaced) {
+		t.Error("WriteChannel differs from SetChannel")
+	}
+	if z, err = signv2.NewApkSign(replaced); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.VerifyV2(); err != nil {
+		t.Fatal(err)
+	}
+	channels := 0
+	for _, p := range z.SigningBlock() {
+		if p.ID == signv2.ChannelBlockID {
+			channels++
+		}
+	}
+	if got, _ := z.Channel(); channels != 1 || !maps.Equal(got, other) {
+		t.Errorf("%d channel pairs, channel %v", channels, got)
+	}
+
+	// 没有v2签名的apk不能写入渠道
+	unsigned, t2, _, err := a.build()
+	if err != nil || t2 == nil {
+		t.Fatal(err)
+	}
+	if z, err = signv2.NewApkSign(unsigned); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := z.SetChannel(payload); err == nil {
+		t.Error("channel written to an unsigned apk")
+	}
+}
@@ -0,0 +1,87 @@
+package editor
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pzx521521/apk-editor/editor/signv2"
+	"github.com/pzx521521/apk-editor/editor/zip"
+)
+
+func TestEditComment(t *testing.T) {
+	a := newTestEditor(t)
+	a.Url = "https://example.com"
+	a.Comment = "build 20261018.1"
+	apk := mustEdit(t, a)
+	r, err := zip.NewReader(bytes.NewReader(apk), int64(len(apk)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Comment != a.Comment {
+		t.Errorf("comment %q", r.Comment)
+	}
+	// 有注释的apk签名和校验时 EOCD 包含注释
+	z, err := signv2.NewApkSign(apk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := z.VerifyV2(); err != nil {
+		t.Fatal(err)
+	}
+	// 重新签名保留注释
+	_, key, crt := templateFiles(t)
+	keys, err := signingKeys(key, crt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resigned, err := z.SignV2(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(resigned, []byte(a.Comment)) {
+		t.Error("re-signing dropped the comment")
+	}
+	if z, err = signv2.NewApkSign(resigned); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.VerifyV2(); err != nil {
+		t.Fatal(err)
+	}
+	// 修改注释后v2签名失效
+	tampered := bytes.Clone(resigned)
+	tampered[len(tampered)-1] ^= 1
+	if z, err = signv2.NewApkSign(tampered); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.VerifyV2(); err == nil {
+		t.Error("tampered comment verified")
+	}
+}
+
+func TestNewApkSignSmall(t *testing.T) {
+	// 小于65535字节的文件查找 EOCD 时不能越界
+	empty := []byte{0x50, 0x4b, 0x05, 0x06, 21: 0}
+	for _, b := range [][]byte{empty, append([]byte("junk"), empty...), make([]byte, 30)} {
+		if _, err := signv2.NewApkSign(b); err == nil {
+			t.Errorf("%x parsed as a zip", b)
+		}
+	}
+	// 注释中指向真实目录的假 EOCD 不影响查找
+	zipWithComment := func(comment string) []byte {
+		buf := new(bytes.Buffer)
+		w := zip.NewWriter(buf)
+		f, _ := w.Create("a.txt")
+		f.Write([]byte("a"))
+		w.SetComment(comment)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	plain := zipWithComment("")
+	fake := bytes.Clone(plain[len(plain)-22:])
+	b := zipWithComment(string(fake))
+	if _, err := signv2.NewApkSign(b); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,97 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+)
+
+// templateManifestText 是模板 AndroidManifest.xml 的文本形式
+const templateManifestText = `<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android"
+    android:versionCode="111"
+    android:versionName="111.111.111"
+    android:compileSdkVersion="31"
+    android:compileSdkVersionCodename="12"
+    package="com.parap.webview"
+    platformBuildVersionCode="31"
+    platformBuildVersionName="12">
+    <uses-sdk android:minSdkVersion="24" android:targetSdkVersion="31" />
+    <uses-permission android:name="android.permission.INTERNET" />
+    <uses-permission android:name="android.permission.ACCESS_NETWORK_STATE" />
+    <application
+        android:theme="@%s"
+        android:label="WebViewDemo"
+        android:icon="@mipmap/ic_launcher"
+        android:allowBackup="true"
+        android:supportsRtl="true"
+        android:extractNativeLibs="false"
+        android:usesCleartextTraffic="true"
+        android:networkSecurityConfig="@xml/network_security_config"
+        android:roundIcon="@mipmap/ic_launcher_round"
+        android:appComponentFactory="androidx.core.app.CoreComponentFactory">
+        <activity android:name="com.parap.webview.MainActivity" android:exported="true">
+            <intent-filter>
+                <action android:name="android.intent.action.MAIN" />
+                <category android:name="android.intent.category.LAUNCHER" />
+            </intent-filter>
+        </activity>
+    </application>
+</manifest>`
+
+func TestCompileManifest(t *testing.T) {
+	want, err := res.ParseXML(templateManifest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newTestEditor(t)
+	table, err := res.ParseTable(templateEntry(t, RESOURCES_ARSC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	theme, _ := table.Name(want.Root.Child("application").AndroidAttr(res.AttrTheme).Value.Data)
+	text := fmt.Sprintf(templateManifestText, theme)
+	got, err := res.CompileXML([]byte(text), table.Find)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 与aapt2编译的结果相比, 只有没有命名空间的 platformBuildVersion* 是字符串
+	var wantElems, gotElems []*res.Element
+	want.Walk(func(e *res.Element) { wantElems = append(wantElems, e) })
+	got.Walk(func(e *res.Element) { gotElems = append(gotElems, e) })
+	if len(gotElems) != len(wantElems) {
+		t.Fatalf("%d elements, want %d", len(gotElems), len(wantElems))
+	}
+	for i, e := range wantElems {
+		g := gotElems[i]
+		if g.Name != e.Name || len(g.Attrs) != len(e.Attrs) {
+			t.Errorf("<%s> %d attrs, want <%s> %d", g.Name, len(g.Attrs), e.Name, len(e.Attrs))
+			continue
+		}
+		for j, wa := range e.Attrs {
+			ga := g.Attrs[j]
+			if strings.HasPrefix(wa.Name, "platformBuildVersion") {
+				continue
+			}
+			if ga.Name != wa.Name || ga.ID != wa.ID || ga.Value.Type != wa.Value.Type || ga.String() != wa.String() {
+				t.Errorf("<%s> attr %d: %s %#x %s, want %s %#x %s", e.Name, j, ga.Name, ga.ID, ga.String(), wa.Name, wa.ID, wa.String())
+			}
+		}
+	}
+
+	a.Url = "https://example.com"
+	a.ManifestXML = []byte(strings.Replace(text, `android:exported="true">`, `android:exported="true" android:screenOrientation="landscape">`, 1))
+	a.Manifest = &Manifest{VersionCode: 2}
+	out := mustEdit(t, a)
+	m := manifestOf(t, out)
+	if m.VersionCode != 2 || m.Application.Label != "WebViewDemo" || m.Application.Activities[0].Attributes["screenOrientation"] != "0" {
+		t.Errorf("manifest %+v", m)
+	}
+
+	a.ManifestXML = []byte(`<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="a.b"><application android:icon="@mipmap/missing"/></manifest>`)
+	if _, err := a.Edit(); err == nil || !strings.Contains(err.Error(), "mipmap/missing") {
+		t.Errorf("missing resource: %v", err)
+	}
+}
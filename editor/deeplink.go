@@ -0,0 +1,284 @@
+package editor
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+)
+
+const (
+	actionView        = "android.intent.action.VIEW"
+	actionMain        = "android.intent.action.MAIN"
+	categoryDefault   = "android.intent.category.DEFAULT"
+	categoryBrowsable = "android.intent.category.BROWSABLE"
+	categoryLauncher  = "android.intent.category.LAUNCHER"
+)
+
+// DeepLink 是添加到 Activity 的 VIEW/BROWSABLE intent-filter, 每个 DeepLink 对应一个 intent-filter.
+// Path, PathPrefix, PathPattern 最多设置一个, 都为空时匹配 host 下的所有路径
+type DeepLink struct {
+	Scheme string
+	// Host 可以以 *. 开头匹配所有子域名
+	Host        string
+	Port        string
+	Path        string
+	PathPrefix  string
+	PathPattern string
+	// AutoVerify 为true时是 App Link, 需要在 https://Host/.well-known/assetlinks.json 放置 ApkEditor.AssetLinks
+	AutoVerify bool
+	// Activity 处理链接的Activity, 为空时为启动Activity
+	Activity string
+}
+
+// ParseDeepLink 解析 scheme://host[:port][/path], path 以 * 结尾时为 pathPrefix, 其他位置有 * 时为 pathPattern
+func ParseDeepLink(s string, autoVerify bool) (DeepLink, error) {
+	l := DeepLink{AutoVerify: autoVerify}
+	scheme, rest, ok := strings.Cut(strings.TrimSpace(s), "://")
+	if !ok {
+		scheme, rest, _ = strings.Cut(strings.TrimSpace(s), ":")
+	}
+	l.Scheme = strings.ToLower(scheme)
+	host, path, hasPath := strings.Cut(rest, "/")
+	if hasPath {
+		path = "/" + path
+	}
+	l.Host, l.Port, _ = strings.Cut(strings.ToLower(host), ":")
+	switch {
+	case path == "" || path == "/" || path == "/*":
+	case strings.Count(path, "*") == 1 && strings.HasSuffix(path, "*"):
+		l.PathPrefix = strings.TrimSuffix(path, "*")
+	case strings.Contains(path, "*"):
+		l.PathPattern = strings.ReplaceAll(path, "*", ".*")
+	default:
+		l.Path = path
+	}
+	return l, l.check()
+}
+
+func (l DeepLink) check() error {
+	if l.Scheme == "" {
+		return fmt.Errorf("deep link %s: empty scheme", l)
+	}
+	if l.Host == "" && (l.Port != "" || l.Path != "" || l.PathPrefix != "" || l.PathPattern != "") {
+		return fmt.Errorf("deep link %s: port and path need a host", l)
+	}
+	paths := 0
+	for _, p := range []string{l.Path, l.PathPrefix, l.PathPattern} {
+		if p != "" {
+			paths++
+			if !strings.HasPrefix(p, "/") && !strings.HasPrefix(p, ".*") {
+				return fmt.Errorf("deep link %s: path must start with /", l)
+			}
+		}
+	}
+	if paths > 1 {
+		return fmt.Errorf("deep link %s: only one of path, pathPrefix and pathPattern can be set", l)
+	}
+	if l.AutoVerify && (l.Scheme != "http" && l.Scheme != "https" || l.Host == "") {
+		return fmt.Errorf("app link %s: autoVerify needs an http or https scheme and a host", l)
+	}
+	return nil
+}
+
+// String 返回链接的url形式, pathPrefix 写为 prefix*
+func (l DeepLink) String() string {
+	s := l.Scheme + "://" + l.Host
+	if l.Port != "" {
+		s += ":" + l.Port
+	}
+	switch {
+	case l.Path != "":
+		s += l.Path
+	case l.PathPrefix != "":
+		s += l.PathPrefix + "*"
+	case l.PathPattern != "":
+		s += l.PathPattern
+	}
+	if l.AutoVerify {
+		s += " (autoVerify)"
+	}
+	return s
+}
+
+// checkDeepLinks 检查链接是否合法, 以及处理链接的Activity是否存在
+func (m *Manifest) checkDeepLinks(x *res.XML) error {
+	pkg := m.Package
+	if pkg == "" {
+		pkg = attrString(x.Root.Attr("", "package"))
+	}
+	for _, l := range m.DeepLinks {
+		if err := l.check(); err != nil {
+			return err
+		}
+		if linkActivity(x.Root, pkg, l.Activity) == nil {
+			if l.Activity == "" {
+				return fmt.Errorf("deep link %s: no launcher activity", l)
+			}
+			return fmt.Errorf("deep link %s: activity %s not found", l, l.Activity)
+		}
+	}
+	return nil
+}
+
+// linkActivity 返回名称为 name 的 activity 或 activity-alias, name 为空时返回启动Activity
+func linkActivity(root *res.Element, pkg, name string) *res.Element {
+	app := root.Child("application")
+	if app == nil {
+		return nil
+	}
+	name = qualifyClass(pkg, name)
+	for _, e := range app.Children {
+		if e.Name != "activity" && e.Name != "activity-alias" {
+			continue
+		}
+		if name != "" {
+			if qualifyClass(pkg, attrString(e.AndroidAttr(res.AttrName))) == name {
+				return e
+			}
+			continue
+		}
+		for _, f := range e.ChildrenNamed("intent-filter") {
+			if filterHas(f, "action", actionMain) && filterHas(f, "category", categoryLauncher) {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// qualifyClass 把 .Name 或不含点的类名补全为 pkg 下的完整类名
+func qualifyClass(pkg, name string) string {
+	if strings.HasPrefix(name, ".") {
+		return pkg + name
+	}
+	if name != "" && !strings.Contains(name, ".") {
+		return pkg + "." + name
+	}
+	return name
+}
+
+func filterHas(f *res.Element, child, name string) bool {
+	for _, c := range f.ChildrenNamed(child) {
+		if attrString(c.AndroidAttr(res.AttrName)) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// applyDeepLinks 为 m.DeepLinks 添加 intent-filter, 已有相同的 intent-filter 时不添加.
+// 处理链接的Activity必须能被其他应用启动, 没有 exported 时设为true
+func (m *Manifest) applyDeepLinks(root *res.Element) []ManifestChange {
+	var changes []ManifestChange
+	pkg := attrString(root.Attr("", "package"))
+	for _, l := range m.DeepLinks {
+		activity := linkActivity(root, pkg, l.Activity)
+		if activity == nil {
+			continue
+		}
+		if a := activity.AndroidAttr(res.AttrExported); a == nil || a.Value != res.Bool(true) {
+			activity.SetAttr(res.ValueAttr(res.AndroidNS, "exported", res.AttrExported, res.Bool(true)))
+			changes = append(changes, ManifestChange{attrString(activity.AndroidAttr(res.AttrName)) + " exported", attrString(a), "true"})
+		}
+		if hasDeepLink(activity, l) {
+			continue
+		}
+		activity.AppendChild("intent-filter", l.intentFilter())
+		changes = append(changes, ManifestChange{"deep link", "", l.String()})
+	}
+	return changes
+}
+
+func (l DeepLink) intentFilter() *res.Element {
+	var attrs []*res.Attr
+	if l.AutoVerify {
+		attrs = append(attrs, res.ValueAttr(res.AndroidNS, "autoVerify", res.AttrAutoVerify, res.Bool(true)))
+	}
+	f := res.NewElement("intent-filter", attrs...)
+	f.Children = append(f.Children,
+		res.NewElement("action", res.StringAttr(res.AndroidNS, "name", res.AttrName, actionView)),
+		res.NewElement("category", res.StringAttr(res.AndroidNS, "name", res.AttrName, categoryDefault)),
+		res.NewElement("category", res.StringAttr(res.AndroidNS, "name", res.AttrName, categoryBrowsable)))
+	data := res.NewElement("data")
+	for _, a := range []struct {
+		name  string
+		id    uint32
+		value string
+	}{
+		{"scheme", res.AttrScheme, l.Scheme},
+		{"host", res.AttrHost, l.Host},
+		{"port", res.AttrPort, l.Port},
+		{"path", res.AttrPath, l.Path},
+		{"pathPrefix", res.AttrPathPrefix, l.PathPrefix},
+		{"pathPattern", res.AttrPathPattern, l.PathPattern},
+	} {
+		if a.value != "" {
+			data.SetAttr(res.StringAttr(res.AndroidNS, a.name, a.id, a.value))
+		}
+	}
+	f.Children = append(f.Children, data)
+	return f
+}
+
+// hasDeepLink 报告 activity 中是否已有匹配 l 的 VIEW intent-filter
+func hasDeepLink(activity *res.Element, l DeepLink) bool {
+	v := &manifestValues{}
+	for _, f := range activity.ChildrenNamed("intent-filter") {
+		filter := v.intentFilter(f)
+		if filter.AutoVerify != l.AutoVerify || !filterHas(f, "action", actionView) {
+			continue
+		}
+		for _, d := range filter.Data {
+			if d == (IntentData{Scheme: l.Scheme, Host: l.Host, Port: l.Port, Path: l.Path, PathPrefix: l.PathPrefix, PathPattern: l.PathPattern}) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasAppLinks 报告是否有需要验证的 App Link
+func (m *Manifest) hasAppLinks() bool {
+	for _, l := range m.DeepLinks {
+		if l.AutoVerify {
+			return true
+		}
+	}
+	return false
+}
+
+// AssetLinks 返回 Digital Asset Links 文件 assetlinks.json 的内容, 声明包名为 pkg,
+// 签名证书为 certs 的应用可以处理网站的所有链接
+func AssetLinks(pkg string, certs ...*x509.Certificate) ([]byte, error) {
+	type target struct {
+		Namespace              string   `json:"namespace"`
+		PackageName            string   `json:"package_name"`
+		SHA256CertFingerprints []string `json:"sha256_cert_fingerprints"`
+	}
+	type statement struct {
+		Relation []string `json:"relation"`
+		Target   target   `json:"target"`
+	}
+	t := target{Namespace: "android_app", PackageName: pkg}
+	for _, c := range certs {
+		t.SHA256CertFingerprints = append(t.SHA256CertFingerprints, certFingerprint(c))
+	}
+	return json.MarshalIndent([]statement{{
+		Relation: []string{"delegate_permission/common.handle_all_urls"},
+		Target:   t,
+	}}, "", "  ")
+}
+
+// certFingerprint 返回证书的 SHA-256, 格式为 AB:CD:...
+func certFingerprint(c *x509.Certificate) string {
+	sum := sha256.Sum256(c.Raw)
+	hex := make([]string, len(sum))
+	for i, b := range sum {
+		hex[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(hex, ":")
+}
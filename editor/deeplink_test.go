@@ -0,0 +1,104 @@
+package editor
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseDeepLink(t *testing.T) {
+	for _, c := range []struct {
+		in         string
+		autoVerify bool
+		want       DeepLink
+		err        string // 为空时应当解析成功
+	}{
+		{in: "https://app.example.com", want: DeepLink{Scheme: "https", Host: "app.example.com"}},
+		{in: "https://app.example.com/*", want: DeepLink{Scheme: "https", Host: "app.example.com"}},
+		{in: "https://*.example.com:8443/docs/*", want: DeepLink{Scheme: "https", Host: "*.example.com", Port: "8443", PathPrefix: "/docs/"}},
+		{in: "https://app.example.com/u/*/posts", want: DeepLink{Scheme: "https", Host: "app.example.com", PathPattern: "/u/.*/posts"}},
+		{in: "https://app.example.com/a*b*", want: DeepLink{Scheme: "https", Host: "app.example.com", PathPattern: "/a.*b.*"}},
+		{in: "myapp://open/home", want: DeepLink{Scheme: "myapp", Host: "open", Path: "/home"}},
+		{in: "myapp://open/", want: DeepLink{Scheme: "myapp", Host: "open"}},
+		{in: "myapp:", want: DeepLink{Scheme: "myapp"}},
+		// scheme 和 host 不区分大小写, path 区分
+		{in: " HTTPS://App.Example.COM/Docs ", want: DeepLink{Scheme: "https", Host: "app.example.com", Path: "/Docs"}},
+		{in: "https://app.example.com/docs", autoVerify: true, want: DeepLink{Scheme: "https", Host: "app.example.com", Path: "/docs", AutoVerify: true}},
+		{in: "", err: "empty scheme"},
+		{in: "://open", err: "empty scheme"},
+		{in: "myapp:/home", err: "need a host"},
+		{in: "myapp://:8080", err: "need a host"},
+		{in: "myapp://open", autoVerify: true, err: "autoVerify"},
+		{in: "https://", autoVerify: true, err: "autoVerify"},
+	} {
+		got, err := ParseDeepLink(c.in, c.autoVerify)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%q: got %+v %v, want error %q", c.in, got, err, c.err)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("%q: got %+v %v, want %+v", c.in, got, err, c.want)
+		}
+	}
+}
+
+func TestDeepLinkCheck(t *testing.T) {
+	for _, c := range []struct {
+		link DeepLink
+		err  string
+	}{
+		{DeepLink{Scheme: "myapp", Host: "open", Path: "home"}, "must start with /"},
+		{DeepLink{Scheme: "myapp", Host: "open", Path: "/a", PathPrefix: "/b"}, "only one of"},
+		{DeepLink{Scheme: "myapp", Host: "open", PathPattern: ".*/x"}, ""},
+		{DeepLink{Scheme: "myapp", Activity: ".Other"}, ""},
+	} {
+		err := c.link.check()
+		if c.err == "" && err != nil || c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+			t.Errorf("%+v: %v, want %q", c.link, err, c.err)
+		}
+	}
+}
+
+func TestEditDeepLinks(t *testing.T) {
+	a := newTestEditor(t)
+	a.Url = "https://app.example.com"
+	appLink, _ := ParseDeepLink("https://app.example.com/docs/*", true)
+	deepLink, _ := ParseDeepLink("webviewdemo://open", false)
+	a.Manifest = &Manifest{Package: "com.example.docs", DeepLinks: []DeepLink{appLink, deepLink}}
+	out := mustEdit(t, a)
+	m := manifestOf(t, out)
+	filters := m.Application.Activities[0].IntentFilters
+	if len(filters) != 3 || !filters[1].AutoVerify || filters[1].Data[0].PathPrefix != "/docs/" || filters[2].Data[0].Scheme != "webviewdemo" {
+		t.Errorf("intent filters %+v", filters)
+	}
+	var links []struct {
+		Target struct {
+			PackageName  string   `json:"package_name"`
+			Fingerprints []string `json:"sha256_cert_fingerprints"`
+		} `json:"target"`
+	}
+	if err := json.Unmarshal(a.AssetLinks, &links); err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 1 || links[0].Target.PackageName != "com.example.docs" || len(links[0].Target.Fingerprints) != 1 || len(links[0].Target.Fingerprints[0]) != 95 {
+		t.Errorf("assetlinks %s", a.AssetLinks)
+	}
+
+	// 再次添加相同的链接不会重复
+	a = NewApkEditor(out, a.keyBytes, a.certBytes)
+	a.Url = "https://app.example.com"
+	a.Manifest = &Manifest{DeepLinks: []DeepLink{appLink}}
+	if _, err := a.Edit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.ManifestChanges) != 0 {
+		t.Errorf("changes %v", a.ManifestChanges)
+	}
+
+	a.Manifest = &Manifest{DeepLinks: []DeepLink{{Scheme: "myapp", Activity: ".Missing"}}}
+	if _, err := a.Edit(); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("missing activity: %v", err)
+	}
+}
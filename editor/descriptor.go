@@ -0,0 +1,116 @@
+package editor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DESCRIPTOR 模板apk中描述文件的位置, 也可以通过 ApkEditor.Descriptor 单独提供
+const DESCRIPTOR = ASSETS_DIR + "apk-editor.json"
+
+// Descriptor 描述一个模板apk的布局, 使 ApkEditor 可以编辑其他团队自己的壳apk,
+// 而不只是内置的 WebviewDemo. 为空的字段使用 DefaultDescriptor 中的值
+//
+//	{
+//	  "manifest": {"package": "com.example.shell", "label": "Shell", "version_code": 1, "version_name": "1.0"},
+//	  "asset_root": "assets/www/",
+//	  "url_file": "config/url.txt",
+//	  "index_file": "index.html",
+//	  "webview_file": "config/webview.json",
+//	  "icons": ["mipmap/ic_launcher"]
+//	}
+type Descriptor struct {
+	// Manifest 模板中 AndroidManifest.xml 的原始值
+	Manifest *DescriptorManifest `json:"manifest,omitempty"`
+	// AssetRoot 网页文件(目录/zip)写入的位置, 以 / 结尾
+	AssetRoot string `json:"asset_root,omitempty"`
+	// UrlFile 网址写入的文件, 相对于 AssetRoot
+	UrlFile string `json:"url_file,omitempty"`
+	// IndexFile html文件写入的位置, 相对于 AssetRoot
+	IndexFile string `json:"index_file,omitempty"`
+	// WebViewFile WebViewConfig 写入的文件, 相对于 AssetRoot
+	WebViewFile string `json:"webview_file,omitempty"`
+	// Icons 启动图标的资源名, 如 mipmap/ic_launcher
+	Icons []string `json:"icons,omitempty"`
+}
+
+// DescriptorManifest 与 Manifest 相同, 使用json格式的字段名
+type DescriptorManifest struct {
+	VersionCode uint32 `json:"version_code,omitempty"`
+	VersionName string `json:"version_name,omitempty"`
+	Label       string `json:"label,omitempty"`
+	Package     string `json:"package,omitempty"`
+}
+
+// DefaultDescriptor 内置模板 release/app-release.apk 的布局
+var DefaultDescriptor = &Descriptor{
+	Manifest: &DescriptorManifest{
+		VersionCode: DefaultManifest.VersionCode,
+		VersionName: DefaultManifest.VersionName,
+		Label:       DefaultManifest.Label,
+		Package:     DefaultManifest.Package,
+	},
+	AssetRoot:   ASSETS_DIR,
+	UrlFile:     "url.txt",
+	IndexFile:   "index.html",
+	WebViewFile: "webview.json",
+	Icons:       DefaultIcons,
+}
+
+// ParseDescriptor 解析json格式的描述文件, 不认识的字段视为错误
+func ParseDescriptor(b []byte) (*Descriptor, error) {
+	d := &Descriptor{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(d); err != nil {
+		return nil, fmt.Errorf("descriptor: %w", err)
+	}
+	if d.AssetRoot != "" && !strings.HasSuffix(d.AssetRoot, "/") {
+		d.AssetRoot += "/"
+	}
+	if strings.HasPrefix(d.AssetRoot, "/") {
+		return nil, fmt.Errorf("descriptor: asset_root %q must be relative", d.AssetRoot)
+	}
+	return d, nil
+}
+
+// withDefaults 返回用 DefaultDescriptor 补全空字段后的副本
+func (d *Descriptor) withDefaults() *Descriptor {
+	if d == nil {
+		return DefaultDescriptor
+	}
+	out := *d
+	def := DefaultDescriptor
+	if out.Manifest == nil {
+		out.Manifest = def.Manifest
+	}
+	if out.AssetRoot == "" {
+		out.AssetRoot = def.AssetRoot
+	}
+	if out.UrlFile == "" {
+		out.UrlFile = def.UrlFile
+	}
+	if out.IndexFile == "" {
+		out.IndexFile = def.IndexFile
+	}
+	if out.WebViewFile == "" {
+		out.WebViewFile = def.WebViewFile
+	}
+	if len(out.Icons) == 0 {
+		out.Icons = def.Icons
+	}
+	return &out
+}
+
+// original 返回模板中manifest的原始值
+func (d *Descriptor) original() *Manifest {
+	m := d.Manifest
+	return &Manifest{
+		VersionCode: m.VersionCode,
+		VersionName: m.VersionName,
+		Label:       m.Label,
+		Package:     m.Package,
+	}
+}
@@ -0,0 +1,71 @@
+package editor
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+)
+
+func TestParseDescriptor(t *testing.T) {
+	d, err := ParseDescriptor([]byte(`{"asset_root":"assets/www","icons":["mipmap/icon"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = d.withDefaults()
+	if d.AssetRoot != "assets/www/" || d.UrlFile != "url.txt" || d.Icons[0] != "mipmap/icon" || d.Manifest.Package != DefaultManifest.Package {
+		t.Errorf("descriptor %+v", d)
+	}
+	if _, err := ParseDescriptor([]byte(`{"assets":"x"}`)); err == nil {
+		t.Error("unknown field accepted")
+	}
+}
+
+func TestEditDescriptor(t *testing.T) {
+	// 先生成一个在 assets/apk-editor.json 中带描述文件的模板
+	desc := `{"asset_root":"assets/www/","url_file":"cfg/url.txt","webview_file":"cfg/webview.json"}`
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	f, _ := zw.Create("apk-editor.json")
+	f.Write([]byte(desc))
+	zw.Close()
+	a := newTestEditor(t)
+	a.HtmlZip = buf.Bytes()
+	shell := mustEdit(t, a)
+	_, key, crt := templateFiles(t)
+	tpl, err := NewTemplate(shell, key, crt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	embedded := tpl.NewEditor()
+	embedded.Url = "https://example.com"
+	embedded.WebView = &WebViewConfig{UserAgent: "test"}
+	alongside := tpl.NewEditor()
+	alongside.Url = "https://example.com"
+	alongside.Descriptor = &Descriptor{UrlFile: "link.txt"}
+	for _, c := range []struct {
+		editor *ApkEditor
+		want   []string
+	}{
+		{embedded, []string{"assets/www/cfg/url.txt", "assets/www/cfg/webview.json"}},
+		{alongside, []string{"assets/link.txt"}},
+	} {
+		out, err := c.editor.Edit()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := map[string]bool{}
+		for _, f := range r.File {
+			names[f.Name] = true
+		}
+		for _, name := range c.want {
+			if !names[name] {
+				t.Errorf("%s not written", name)
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package editor
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+	"github.com/pzx521521/apk-editor/editor/zip"
+)
+
+// DumpXML 把apk中的二进制xml(AndroidManifest.xml, res/layout/*.xml 等)解码为文本,
+// 资源引用通过 resources.arsc 解析为 @type/name
+func DumpXML(apk io.ReaderAt, size int64, entry string) ([]byte, error) {
+	r, err := zip.NewReader(apk, size)
+	if err != nil {
+		return nil, err
+	}
+	b, err := readEntry(r, entry)
+	if err != nil {
+		return nil, err
+	}
+	x, err := res.ParseXML(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", entry, err)
+	}
+	var table *res.Table
+	if arsc, err := readEntry(r, RESOURCES_ARSC); err == nil {
+		if table, err = res.ParseTable(arsc); err != nil {
+			return nil, err
+		}
+	}
+	return x.Text(table), nil
+}
@@ -0,0 +1,21 @@
+package editor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDumpXML(t *testing.T) {
+	apk, _, _ := templateFiles(t)
+	text, err := DumpXML(bytes.NewReader(apk), int64(len(apk)), "res/4u.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(text), "<network-security-config") {
+		t.Errorf("network security config:\n%s", text)
+	}
+	if _, err := DumpXML(bytes.NewReader(apk), int64(len(apk)), "classes.dex"); err == nil {
+		t.Error("dex decoded as xml")
+	}
+}
@@ -3,11 +3,15 @@ package editor
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/pzx521521/apk-editor/editor/res"
 	"github.com/pzx521521/apk-editor/editor/signv2"
 	"github.com/pzx521521/apk-editor/editor/zip"
 )
@@ -19,6 +23,38 @@ type Manifest struct {
 	VersionName string
 	Label       string
 	Package     string
+	// AddPermissions 添加的 uses-permission, 需要硬件的权限会同时添加 required=false 的 uses-feature
+	AddPermissions []Permission
+	// RemovePermissions 删除的 uses-permission, 没有包名时为 android.permission.NAME
+	RemovePermissions []string
+	// MinSdkVersion TargetSdkVersion 修改 <uses-sdk>, 0表示不修改.
+	// minSdkVersion 不能低于模板dex需要的版本, 提高 targetSdkVersion 改变的运行时行为记录在 ApkEditor.Warnings
+	MinSdkVersion    int
+	TargetSdkVersion int
+	// DeepLinks 添加的 VIEW/BROWSABLE intent-filter, 有 AutoVerify 时 Edit 生成 ApkEditor.AssetLinks
+	DeepLinks []DeepLink
+	// Debuggable AllowBackup UsesCleartextTraffic ResizeableActivity 修改 <application> 的属性, nil表示不修改.
+	// Debuggable 为true时 WebView 可以通过 chrome://inspect 调试
+	Debuggable           *bool
+	AllowBackup          *bool
+	UsesCleartextTraffic *bool
+	ResizeableActivity   *bool
+	// ScreenOrientation 修改所有 activity 的屏幕方向, 如 portrait, landscape, sensorLandscape, unspecified 删除属性
+	ScreenOrientation string
+	// WindowSoftInputMode 修改所有 activity 的软键盘模式, 如 adjustResize|stateHidden
+	WindowSoftInputMode string
+	// MetaData 添加或修改 <application> 下的 <meta-data>, 值为字符串. SDK的key, 功能开关等
+	MetaData map[string]string
+	// MetaDataInt MetaDataBool 值为整数和布尔的 meta-data
+	MetaDataInt  map[string]int32
+	MetaDataBool map[string]bool
+	// MetaDataResource 值为资源引用(android:resource)的 meta-data, 如 @xml/config 或 0x7f110000
+	MetaDataResource map[string]string
+	// RemoveMetaData 删除的 meta-data
+	RemoveMetaData []string
+	// Labels 各语言的应用名, key 为 zh, zh-CN 或 zh-rCN 形式的语言. 写入 resources.arsc 的 string/app_name,
+	// android:label 改为引用它, 默认语言为 Label 或原来的应用名
+	Labels map[string]string
 }
 
 var DefaultManifest = &Manifest{
@@ -29,24 +65,29 @@ var DefaultManifest = &Manifest{
 }
 
 func (m *Manifest) Modify(manifest []byte) ([]byte, error) {
+	return m.ModifyFrom(DefaultManifest, manifest)
+}
+
+// ModifyFrom 把 manifest 中 old 的值替换为 m 中的值, old 中为空的字段不修改
+func (m *Manifest) ModifyFrom(old *Manifest, manifest []byte) ([]byte, error) {
 	var modifications []any
 
 	// 收集所有修改
-	if m.Label != "" && m.Label != DefaultManifest.Label {
+	if m.Label != "" && old.Label != "" && m.Label != old.Label {
 		modifications = append(modifications,
-			ModifyInfo[string]{Old: DefaultManifest.Label, New: m.Label})
+			ModifyInfo[string]{Old: old.Label, New: m.Label})
 	}
-	if m.Package != "" && m.Package != DefaultManifest.Package {
+	if m.Package != "" && old.Package != "" && m.Package != old.Package {
 		modifications = append(modifications,
-			ModifyInfo[string]{Old: DefaultManifest.Package, New: m.Package})
+			ModifyInfo[string]{Old: old.Package, New: m.Package})
 	}
-	if m.VersionName != "" && m.VersionName != DefaultManifest.VersionName {
+	if m.VersionName != "" && old.VersionName != "" && m.VersionName != old.VersionName {
 		modifications = append(modifications,
-			ModifyInfo[string]{Old: DefaultManifest.VersionName, New: m.VersionName})
+			ModifyInfo[string]{Old: old.VersionName, New: m.VersionName})
 	}
-	if m.VersionCode != 0 && m.VersionCode != DefaultManifest.VersionCode {
+	if m.VersionCode != 0 && old.VersionCode != 0 && m.VersionCode != old.VersionCode {
 		modifications = append(modifications,
-			ModifyInfo[uint32]{Old: DefaultManifest.VersionCode, New: m.VersionCode})
+			ModifyInfo[uint32]{Old: old.VersionCode, New: m.VersionCode})
 	}
 
 	// 一次性处理所有修改
@@ -71,9 +112,44 @@ type ApkEditor struct {
 	IndexHtml []byte    `json:"index_html,omitempty"`
 	HtmlZip   []byte    `json:"html_zip,omitempty"`
 	Manifest  *Manifest `json:"manifest,omitempty"`
+	// ManifestXML 文本格式的 AndroidManifest.xml, 编译为二进制后替换模板的manifest, Manifest 中的修改在其之上进行.
+	// @type/name 引用按模板的 resources.arsc 解析
+	ManifestXML []byte         `json:"manifest_xml,omitempty"`
+	Icon        []byte         `json:"icon,omitempty"` // png/jpeg, 替换启动图标
+	WebView     *WebViewConfig `json:"webview,omitempty"`
+	// Theme 修改主题的主色, 状态栏和窗口背景颜色
+	Theme *ThemeColors `json:"theme,omitempty"`
+	// Splash 网页加载前显示的启动画面
+	Splash *Splash `json:"splash,omitempty"`
+	// NetworkSecurity 不为nil时重新生成 network_security_config.xml, 限制明文http和固定证书
+	NetworkSecurity *NetworkSecurityConfig `json:"network_security,omitempty"`
+	// Store 为true时合并的文件不压缩
+	Store bool `json:"store,omitempty"`
+	// Align 不压缩文件的对齐字节数, 0表示与zipalign相同的4
+	Align int `json:"align,omitempty"`
+	// Comment zip的注释, 如构建号, 为空时保留模板的注释
+	Comment string `json:"comment,omitempty"`
+	// Remove 从模板中删除的文件, 以/结尾时删除整个目录, 如 assets/default.txt, lib/x86/.
+	// 不能删除 AndroidManifest.xml, resources.arsc, dex 和网页等 Edit 写入的文件. 模板中旧的v1签名文件总是被删除
+	Remove []string `json:"remove,omitempty"`
+	// V4 为true时 Edit 同时生成 APK Signature Scheme v4 签名, 用于 adb install --incremental
+	V4 bool `json:"v4,omitempty"`
+	// ManifestChanges Edit 后记录对 AndroidManifest.xml 的实际修改
+	ManifestChanges []ManifestChange `json:"-"`
+	// Warnings Edit 后记录不影响生成但需要注意的问题
+	Warnings []string `json:"-"`
+	// AssetLinks Edit 后为 App Link 需要放在网站 /.well-known/assetlinks.json 的内容, 没有 App Link 时为nil
+	AssetLinks []byte `json:"-"`
+	// IDSig V4 为true时 Edit 后为v4签名, 需要与apk放在同一目录, 文件名为 <apk文件名>.idsig
+	IDSig []byte `json:"-"`
+	// Descriptor 模板的布局, 为空时使用模板中的 assets/apk-editor.json 或 DefaultDescriptor
+	Descriptor *Descriptor `json:"descriptor,omitempty"`
+	// OnStage 在Edit的每个阶段完成后被调用, 用于统计耗时
+	OnStage   func(stage string, elapsed time.Duration) `json:"-"`
 	apkRaw    []byte
 	keyBytes  []byte
 	certBytes []byte
+	template  *Template
 }
 
 func NewApkEditor(apk, keyBytes, certBytes []byte) *ApkEditor {
@@ -83,80 +159,261 @@ func (a *ApkEditor) Init(apk, keyBytes, certBytes []byte) {
 	a.apkRaw = apk
 	a.keyBytes = keyBytes
 	a.certBytes = certBytes
+	a.template = nil
 }
 
 func (a *ApkEditor) Edit() ([]byte, error) {
-	modifyContent, err := a.modifyContent()
+	unsigned, t, start, err := a.build()
 	if err != nil {
 		return nil, err
 	}
-	if len(modifyContent) == 0 {
-		return nil, errors.New("no content to modify")
+	signed, err := sign(unsigned, t.keys)
+	if err != nil {
+		return nil, &StageError{StageSign, err}
+	}
+	if a.V4 {
+		if a.IDSig, err = signV4(signed, t.keys); err != nil {
+			return nil, &StageError{StageSign, err}
+		}
+	}
+	a.stageDone(StageSign, start)
+	return signed, nil
+}
+
+// build 完成签名前的所有阶段, 返回未签名的apk, 使用的模板和最后一个阶段的结束时间
+func (a *ApkEditor) build() ([]byte, *Template, time.Time, error) {
+	start := time.Now()
+	a.ManifestChanges, a.Warnings, a.AssetLinks, a.IDSig = nil, nil, nil, nil
+	t := a.template
+	if t == nil {
+		var err error
+		if t, err = NewTemplate(a.apkRaw, a.keyBytes, a.certBytes); err != nil {
+			return nil, nil, start, &StageError{StageMerge, err}
+		}
 	}
-	r, err := zip.NewReader(bytes.NewReader(a.apkRaw), int64(len(a.apkRaw)))
+	d := a.Descriptor
+	if d == nil {
+		d = t.descriptor
+	}
+	d = d.withDefaults()
+	modifyContent, err := a.modifyContent(d)
 	if err != nil {
-		return nil, err
+		return nil, nil, start, &StageError{StageMerge, err}
 	}
+	if len(modifyContent) == 0 {
+		return nil, nil, start, &StageError{StageMerge, invalid(errors.New("no content to modify"))}
+	}
+	r := t.reader
 	aBuf := new(bytes.Buffer)
-	aBuf.Write(a.apkRaw[:r.AppendOffset()])
-	w := r.Append(aBuf, a.Manifest != nil)
-	err = merge(w, modifyContent...)
+	aBuf.Write(t.apkRaw[:r.AppendOffset()])
+	w := r.Append(aBuf, a.Manifest != nil || a.ManifestXML != nil)
+	align := a.Align
+	if align == 0 {
+		align = 4
+	}
+	w.SetAlignment(align)
+	if a.Comment != "" {
+		if err := w.SetComment(a.Comment); err != nil {
+			return nil, nil, start, &StageError{StageMerge, err}
+		}
+	}
+	if err = a.remove(w, r, modifyContent); err != nil {
+		return nil, nil, start, &StageError{StageMerge, err}
+	}
+	err = a.merge(w, modifyContent...)
 	if err != nil {
-		return nil, err
+		return nil, nil, start, &StageError{StageMerge, err}
+	}
+	start = a.stageDone(StageMerge, start)
+	// resources.arsc 只解析一次, manifest 和资源的修改都在同一个 table 上, 最后一起写回
+	var arsc []byte
+	var table *res.Table
+	if a.Manifest != nil || a.ManifestXML != nil || len(a.Icon) > 0 || a.NetworkSecurity != nil || a.Theme != nil || a.Splash != nil {
+		if arsc, table, err = readTable(r); err != nil {
+			return nil, nil, start, &StageError{StageManifest, err}
+		}
 	}
-	err = a.manifest(r, w)
+	x, err := a.manifest(t, w, d, table)
 	if err != nil {
-		return nil, err
+		return nil, nil, start, &StageError{StageManifest, err}
+	}
+	start = a.stageDone(StageManifest, start)
+	iconContent, err := a.iconContent(table, d.Icons)
+	if err != nil {
+		return nil, nil, start, &StageError{StageResources, err}
+	}
+	err = a.merge(w, iconContent...)
+	if err != nil {
+		return nil, nil, start, &StageError{StageResources, err}
+	}
+	if err = a.applyTheme(r, x, table); err != nil {
+		return nil, nil, start, &StageError{StageResources, err}
+	}
+	splash, err := a.splashContent(r, x, table)
+	if err != nil {
+		return nil, nil, start, &StageError{StageResources, err}
+	}
+	err = a.merge(w, splash...)
+	if err != nil {
+		return nil, nil, start, &StageError{StageResources, err}
 	}
+	networkSecurity, err := a.networkSecurityContent(r, x, table)
+	if err != nil {
+		return nil, nil, start, &StageError{StageResources, err}
+	}
+	err = a.merge(w, networkSecurity...)
+	if err != nil {
+		return nil, nil, start, &StageError{StageResources, err}
+	}
+	if table != nil {
+		if b := table.Marshal(); !bytes.Equal(b, arsc) {
+			if err = a.merge(w, &MergeEntry{RESOURCES_ARSC, b}); err != nil {
+				return nil, nil, start, &StageError{StageResources, err}
+			}
+		}
+	}
+	start = a.stageDone(StageResources, start)
 	err = w.Close()
 	if err != nil {
-		return nil, err
+		return nil, nil, start, &StageError{StageFinalize, err}
 	}
-	return sign(aBuf.Bytes(), a.keyBytes, a.certBytes)
+	start = a.stageDone(StageFinalize, start)
+	unsigned, err := realign(aBuf.Bytes(), align)
+	if err != nil {
+		return nil, nil, start, &StageError{StageAlign, err}
+	}
+	start = a.stageDone(StageAlign, start)
+	return unsigned, t, start, nil
 }
-func (a *ApkEditor) modifyContent() ([]*MergeEntry, error) {
+
+// stageDone 报告一个阶段的耗时, 并返回下一个阶段的开始时间
+func (a *ApkEditor) stageDone(stage string, start time.Time) time.Time {
+	now := time.Now()
+	if a.OnStage != nil {
+		a.OnStage(stage, now.Sub(start))
+	}
+	return now
+}
+func (a *ApkEditor) modifyContent(d *Descriptor) ([]*MergeEntry, error) {
 	var mergeEntries []*MergeEntry
 	if a.Url != "" {
 		if strings.HasPrefix(a.Url, "http") {
-			mergeEntries = append(mergeEntries, &MergeEntry{ASSETS_DIR + "url.txt", []byte(a.Url)})
+			mergeEntries = append(mergeEntries, &MergeEntry{d.AssetRoot + d.UrlFile, []byte(a.Url)})
 		} else {
-			c, err := dirContent(filepath.Clean(a.Url))
+			c, err := dirContent(filepath.Clean(a.Url), d.AssetRoot)
 			if err != nil {
 				return nil, err
 			}
 			mergeEntries = c
 		}
 	} else if a.IndexHtml != nil && len(a.IndexHtml) > 0 {
-		mergeEntries = append(mergeEntries, &MergeEntry{ASSETS_DIR + "index.html", []byte(a.IndexHtml)})
+		mergeEntries = append(mergeEntries, &MergeEntry{d.AssetRoot + d.IndexFile, []byte(a.IndexHtml)})
 	} else if a.HtmlZip != nil && len(a.HtmlZip) > 0 {
-		content, err := zipContent(a.HtmlZip)
+		content, err := zipContent(a.HtmlZip, d.AssetRoot)
 		if err != nil {
 			return nil, err
 		}
 		mergeEntries = append(mergeEntries, content...)
 	}
+	webView := a.WebView
+	if a.Splash != nil && a.Splash.MinDuration > 0 {
+		c := WebViewConfig{}
+		if webView != nil {
+			c = *webView
+		}
+		c.SplashMinDuration = a.Splash.MinDuration
+		webView = &c
+	}
+	if webView != nil {
+		c, err := webView.content(d.AssetRoot + d.WebViewFile)
+		if err != nil {
+			return nil, err
+		}
+		mergeEntries = append(mergeEntries, c)
+	}
 	return mergeEntries, nil
 }
 
-func (a *ApkEditor) manifest(r *zip.Reader, w *zip.Writer) error {
-	if a.Manifest == nil {
-		return nil
+// manifest 修改 AndroidManifest.xml 并返回修改后的文档, 没有修改或manifest无法解析时返回nil
+func (a *ApkEditor) manifest(t *Template, w *zip.Writer, d *Descriptor, table *res.Table) (*res.XML, error) {
+	if a.Manifest == nil && a.ManifestXML == nil {
+		return nil, nil
 	}
-	manifest, err := readManifest(r)
-	if err != nil {
-		return err
+	m := a.Manifest
+	if m == nil {
+		m = &Manifest{}
 	}
-	manifest, err = a.Manifest.Modify(manifest)
-	if err != nil {
-		return err
+	r := t.reader
+	var x *res.XML
+	var err error
+	if a.ManifestXML != nil {
+		x, err = res.CompileXML(a.ManifestXML, func(name string) (uint32, bool) {
+			if table == nil {
+				return 0, false
+			}
+			return table.Find(name)
+		})
+		if err != nil {
+			return nil, invalid(fmt.Errorf("%s: %w", zip.ANDROIDMANIFEST, err))
+		}
+		if x.Root.Name != "manifest" || x.Root.Attr("", "package") == nil {
+			return nil, invalid(fmt.Errorf("%s: root element must be <manifest package=...>", zip.ANDROIDMANIFEST))
+		}
+	} else {
+		manifest, err := readManifest(r)
+		if err != nil {
+			return nil, err
+		}
+		if x, err = res.ParseXML(manifest); err != nil {
+			if len(m.DeepLinks) > 0 {
+				return nil, fmt.Errorf("deep links: %w", err)
+			}
+			// 无法解析的manifest按描述文件中的原始值直接替换
+			if manifest, err = m.ModifyFrom(d.original(), manifest); err != nil {
+				return nil, err
+			}
+			return nil, a.merge(w, &MergeEntry{zip.ANDROIDMANIFEST, manifest})
+		}
+	}
+	// 修改前的manifest, 用于在所有修改之后检查 targetSdkVersion 改变的运行时行为
+	var before *AndroidManifest
+	if m.MinSdkVersion != 0 || m.TargetSdkVersion != 0 {
+		warnings, err := m.checkSdk(x, r, table)
+		if err != nil {
+			return nil, invalid(err)
+		}
+		a.Warnings = append(a.Warnings, warnings...)
+		before = newAndroidManifest(x, table)
+	}
+	if err := m.checkDeepLinks(x); err != nil {
+		return nil, invalid(err)
 	}
-	err = merge(w, &MergeEntry{zip.ANDROIDMANIFEST, manifest})
+	warnings, err := m.checkFlags(x)
 	if err != nil {
-		return err
+		return nil, invalid(err)
 	}
-	return nil
+	a.Warnings = append(a.Warnings, warnings...)
+	if err := m.checkMetaData(table); err != nil {
+		return nil, invalid(err)
+	}
+	if err := m.checkLabels(x, table); err != nil {
+		return nil, invalid(err)
+	}
+	if a.ManifestChanges, err = m.Apply(x, table); err != nil {
+		return nil, err
+	}
+	if before != nil {
+		a.Warnings = append(a.Warnings, sdkBehaviorWarnings(before, newAndroidManifest(x, table))...)
+	}
+	if m.hasAppLinks() {
+		if a.AssetLinks, err = t.AssetLinks(attrString(x.Root.Attr("", "package"))); err != nil {
+			return nil, err
+		}
+	}
+	return x, a.merge(w, &MergeEntry{zip.ANDROIDMANIFEST, x.Marshal()})
 }
-func zipContent(zipData []byte) ([]*MergeEntry, error) {
+
+func zipContent(zipData []byte, root string) ([]*MergeEntry, error) {
 	var mergeEntries []*MergeEntry
 	reader, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
 	if err != nil {
@@ -175,11 +432,11 @@ func zipContent(zipData []byte) ([]*MergeEntry, error) {
 		if err != nil {
 			return nil, err
 		}
-		mergeEntries = append(mergeEntries, &MergeEntry{ASSETS_DIR + f.Name, b})
+		mergeEntries = append(mergeEntries, &MergeEntry{root + f.Name, b})
 	}
 	return mergeEntries, nil
 }
-func dirContent(dir string) ([]*MergeEntry, error) {
+func dirContent(dir, root string) ([]*MergeEntry, error) {
 	mergeEntrys := []*MergeEntry{}
 	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -194,34 +451,85 @@ func dirContent(dir string) ([]*MergeEntry, error) {
 			if err != nil {
 				return err
 			}
-			mergeEntrys = append(mergeEntrys, &MergeEntry{ASSETS_DIR + path, file})
+			mergeEntrys = append(mergeEntrys, &MergeEntry{root + filepath.ToSlash(path), file})
 		}
 		return nil
 	})
 	return mergeEntrys, nil
 }
-func sign(apk, keyBytes, certBytes []byte) ([]byte, error) {
-	var keys = []*signv2.SigningCert{
-		{SigningKey: signv2.SigningKey{
-			KeyBytes: keyBytes,
-			Type:     signv2.RSA,
-			Hash:     signv2.SHA256,
-		},
-			CertBytes: certBytes,
-		},
-	}
+func sign(apk []byte, keys []*signv2.SigningCert) ([]byte, error) {
 	z, err := signv2.NewApkSign(apk)
 	if err != nil {
 		return nil, err
 	}
 	return z.SignV2(keys)
 }
-func merge(w *zip.Writer, mf ...*MergeEntry) error {
+
+// signV4 返回已v2签名的apk的v4签名(.idsig)
+func signV4(apk []byte, keys []*signv2.SigningCert) ([]byte, error) {
+	z, err := signv2.NewApkSign(apk)
+	if err != nil {
+		return nil, err
+	}
+	return z.SignV4(keys)
+}
+
+// remove 删除模板中的v1签名文件(重新签名后已失效)和 Remove 中的文件, 没有匹配的文件时记录警告.
+// Remove 不能包含apk必需的文件和 written 中由 Edit 写入的文件
+func (a *ApkEditor) remove(w *zip.Writer, r *zip.Reader, written []*MergeEntry) error {
+	for _, name := range a.Remove {
+		for _, f := range r.File {
+			if matchRemove(name, f.Name) && requiredEntry(f.Name) {
+				return invalid(fmt.Errorf("remove %s: %s is required", name, f.Name))
+			}
+		}
+		for _, e := range written {
+			if matchRemove(name, e.Name) {
+				return invalid(fmt.Errorf("remove %s: %s is written by the editor", name, e.Name))
+			}
+		}
+	}
+	for _, f := range r.File {
+		if isSignatureFile(f.Name) {
+			w.Delete(f.Name)
+		}
+	}
+	for _, name := range a.Remove {
+		n := 0
+		if strings.HasSuffix(name, "/") {
+			n = w.DeletePrefix(name)
+		} else if w.Delete(name) {
+			n = 1
+		}
+		if n == 0 {
+			a.Warnings = append(a.Warnings, "remove: no entry "+name+" in the template")
+		}
+	}
+	return nil
+}
+
+// matchRemove 判断 Remove 中的 pattern 是否删除文件 name, 以/结尾时删除整个目录
+func matchRemove(pattern, name string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(name, pattern)
+	}
+	return pattern == name
+}
+
+// requiredEntry 判断是否为apk必需的文件: AndroidManifest.xml, resources.arsc 和根目录的dex
+func requiredEntry(name string) bool {
+	return name == zip.ANDROIDMANIFEST || name == RESOURCES_ARSC || path.Dir(name) == "." && path.Ext(name) == ".dex"
+}
+func (a *ApkEditor) merge(w *zip.Writer, mf ...*MergeEntry) error {
 	for _, file := range mf {
 		header := &zip.FileHeader{
 			Name:   file.Name,
 			Method: zip.Deflate,
 		}
+		// Android 11+ 要求 resources.arsc 不压缩且4字节对齐
+		if a.Store || file.Name == RESOURCES_ARSC {
+			header.Method = zip.Store
+		}
 		header.SetMode(0o666)
 		f, err := w.CreateHeader(header)
 		if err != nil {
@@ -235,9 +543,38 @@ func merge(w *zip.Writer, mf ...*MergeEntry) error {
 	return nil
 }
 func readManifest(r *zip.Reader) ([]byte, error) {
+	return readEntry(r, zip.ANDROIDMANIFEST)
+}
+
+// finalManifest 返回 manifest 阶段修改后的 x, x 为nil(没有修改)时解析模板的manifest
+func finalManifest(r *zip.Reader, x *res.XML) (*res.XML, error) {
+	if x != nil {
+		return x, nil
+	}
+	manifest, err := readManifest(r)
+	if err != nil {
+		return nil, err
+	}
+	return res.ParseXML(manifest)
+}
+
+// readTable 读取并解析 resources.arsc, 没有时返回nil
+func readTable(r *zip.Reader) ([]byte, *res.Table, error) {
+	arsc, err := readEntry(r, RESOURCES_ARSC)
+	if err != nil {
+		return nil, nil, nil
+	}
+	table, err := res.ParseTable(arsc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return arsc, table, nil
+}
+
+func readEntry(r *zip.Reader, name string) ([]byte, error) {
 	//读取源数据
 	for _, f := range r.File {
-		if f.Name == zip.ANDROIDMANIFEST {
+		if f.Name == name {
 			rc, err := f.Open()
 			if err != nil {
 				return nil, err
@@ -253,5 +590,5 @@ func readManifest(r *zip.Reader) ([]byte, error) {
 			return b, nil
 		}
 	}
-	return nil, errors.New("no manifest found")
+	return nil, errors.New("no " + name + " found")
 }
@@ -0,0 +1,115 @@
+package editor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"image/png"
+	"path"
+	"strings"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+)
+
+const RESOURCES_ARSC = "resources.arsc"
+
+// DefaultIcons 模板中启动图标的资源名
+var DefaultIcons = []string{"mipmap/ic_launcher", "mipmap/ic_launcher_round"}
+
+// iconContent 把 a.Icon 缩放后写入 icons 中每个启动图标资源的每个配置, 返回新的图片, table 中的路径随之修改.
+// 自适应图标(anydpi-v26的xml)会被改为指向新的png
+func (a *ApkEditor) iconContent(table *res.Table, icons []string) ([]*MergeEntry, error) {
+	if len(a.Icon) == 0 {
+		return nil, nil
+	}
+	icon, _, err := image.Decode(bytes.NewReader(a.Icon))
+	if err != nil {
+		return nil, invalid(fmt.Errorf("icon: %w", err))
+	}
+	if table == nil {
+		return nil, fmt.Errorf("icon: template has no %s", RESOURCES_ARSC)
+	}
+	var mergeEntries []*MergeEntry
+	for _, name := range icons {
+		id, ok := table.Find(name)
+		if !ok {
+			continue
+		}
+		for _, ce := range table.Entries(id) {
+			if ce.Entry.IsComplex() {
+				continue
+			}
+			old, ok := table.String(ce.Entry.Value)
+			if !ok {
+				continue
+			}
+			// res/mipmap-anydpi-v26/ic_launcher.png
+			dir := path.Dir(name)
+			if !ce.Config.IsDefault() {
+				dir += "-" + ce.Config.String()
+			}
+			file := "res/" + dir + "/" + path.Base(name) + ".png"
+			if strings.HasSuffix(old, ".png") {
+				file = old
+			}
+			buf := new(bytes.Buffer)
+			if err := png.Encode(buf, scaleImage(icon, iconSize(ce.Config.Density()), iconSize(ce.Config.Density()))); err != nil {
+				return nil, err
+			}
+			ce.Entry.Value.Data = table.Strings.Add(file)
+			mergeEntries = append(mergeEntries, &MergeEntry{file, buf.Bytes()})
+		}
+	}
+	if len(mergeEntries) == 0 {
+		return nil, errors.New("icon: no launcher icon resource found in template")
+	}
+	return mergeEntries, nil
+}
+
+// iconSize 返回启动图标在该密度下的像素大小, mdpi为48
+func iconSize(density uint16) int {
+	switch density {
+	case res.DensityDefault, res.DensityAny, res.DensityNone:
+		return 48 * res.DensityXXXHigh / res.DensityMedium
+	}
+	return 48 * int(density) / res.DensityMedium
+}
+
+// scaleImage 以区域平均的方式把图片缩放为 width*height
+func scaleImage(src image.Image, width, height int) image.Image {
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := max(b.Min.Y+(y+1)*b.Dy()/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := max(b.Min.X+(x+1)*b.Dx()/width, x0+1)
+			var r, g, bl, al, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBAModel.Convert(src.At(sx, sy)).(color.NRGBA)
+					// 按alpha加权, 避免透明像素的颜色混入边缘
+					r += uint64(c.R) * uint64(c.A)
+					g += uint64(c.G) * uint64(c.A)
+					bl += uint64(c.B) * uint64(c.A)
+					al += uint64(c.A)
+					n++
+				}
+			}
+			if al == 0 {
+				continue
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r / al),
+				G: uint8(g / al),
+				B: uint8(bl / al),
+				A: uint8(al / n),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,109 @@
+package editor
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"testing"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+	"github.com/pzx521521/apk-editor/editor/zip"
+)
+
+// templateFiles 读取仓库中的模板apk, 私钥和证书
+func templateFiles(t *testing.T) (apk, key, crt []byte) {
+	t.Helper()
+	var files [3][]byte
+	for i, name := range []string{"../release/app-release.apk", "../release/signing.key", "../release/signing.crt"} {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[i] = b
+	}
+	return files[0], files[1], files[2]
+}
+
+func newTestEditor(t *testing.T) *ApkEditor {
+	t.Helper()
+	return NewApkEditor(templateFiles(t))
+}
+
+// mustEdit 生成apk, 失败时结束测试
+func mustEdit(t *testing.T, a *ApkEditor) []byte {
+	t.Helper()
+	out, err := a.Edit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestScaleImage(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 100, 50))
+	for y := 0; y < 50; y++ {
+		for x := 0; x < 100; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	dst := scaleImage(src, 48, 48)
+	if dst.Bounds().Dx() != 48 || dst.Bounds().Dy() != 48 {
+		t.Fatalf("size %v", dst.Bounds())
+	}
+	if c := color.NRGBAModel.Convert(dst.At(47, 47)).(color.NRGBA); c != (color.NRGBA{R: 255, A: 255}) {
+		t.Errorf("color %v", c)
+	}
+	if iconSize(res.DensityXXHigh) != 144 || iconSize(res.DensityAny) != 192 {
+		t.Errorf("iconSize")
+	}
+}
+
+func TestEditIcon(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, image.NewNRGBA(image.Rect(0, 0, 512, 512))); err != nil {
+		t.Fatal(err)
+	}
+	a := newTestEditor(t)
+	a.Url = "https://example.com"
+	a.Icon = buf.Bytes()
+	out := mustEdit(t, a)
+	r, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range r.File {
+		if f.Name != RESOURCES_ARSC {
+			continue
+		}
+		off, err := f.DataOffset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Method != zip.Store || off%4 != 0 {
+			t.Errorf("resources.arsc method %d offset %d", f.Method, off)
+		}
+	}
+	arsc, err := readEntry(r, RESOURCES_ARSC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := res.ParseTable(arsc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range DefaultIcons {
+		id, _ := table.Find(name)
+		for _, ce := range table.Entries(id) {
+			file, _ := table.String(ce.Entry.Value)
+			b, err := readEntry(r, file)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if _, err := png.Decode(bytes.NewReader(b)); err != nil {
+				t.Errorf("%s: %s is not a png: %v", name, file, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+)
+
+// LabelResource 多语言应用名写入的字符串资源
+const LabelResource = "app_name"
+
+// parseLocale 解析 zh, zh-CN, zh_CN 或 zh-rCN 形式的语言, 返回语言和地区
+func parseLocale(s string) (language, region string, err error) {
+	language, region, hasRegion := strings.Cut(strings.ReplaceAll(s, "_", "-"), "-")
+	if len(region) == 3 && region[0] == 'r' {
+		region = region[1:]
+	}
+	isLetters := func(s string) bool {
+		for _, c := range s {
+			if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+				return false
+			}
+		}
+		return true
+	}
+	isDigits := func(s string) bool {
+		for _, c := range s {
+			if c < '0' || c > '9' {
+				return false
+			}
+		}
+		return true
+	}
+	if len(language) < 2 || len(language) > 3 || !isLetters(language) {
+		return "", "", fmt.Errorf("locale %q: want a language such as zh or zh-CN", s)
+	}
+	if hasRegion && !(len(region) == 2 && isLetters(region) || len(region) == 3 && isDigits(region)) {
+		return "", "", fmt.Errorf("locale %q: region must be two letters or three digits", s)
+	}
+	return strings.ToLower(language), strings.ToUpper(region), nil
+}
+
+// checkLabels 检查 Labels 的语言和应用名, 同一个语言不能出现两次, 模板需要有默认的应用名和 string 资源
+func (m *Manifest) checkLabels(x *res.XML, table *res.Table) error {
+	if len(m.Labels) == 0 {
+		return nil
+	}
+	if table == nil {
+		return fmt.Errorf("labels: template has no %s", RESOURCES_ARSC)
+	}
+	if p := table.Package(0x7f); p == nil || p.TypeStrings.Index("string") < 0 {
+		return errors.New("labels: template has no string resources")
+	}
+	if app := x.Root.Child("application"); m.Label == "" && (app == nil || labelString(app.AndroidAttr(res.AttrLabel), table) == "") {
+		return errors.New("labels: no default label, set Label as well")
+	}
+	seen := map[string]string{}
+	for _, locale := range sortedKeys(m.Labels) {
+		language, region, err := parseLocale(locale)
+		if err != nil {
+			return fmt.Errorf("labels: %w", err)
+		}
+		if m.Labels[locale] == "" {
+			return fmt.Errorf("labels: %s: empty label", locale)
+		}
+		key := language + "-" + region
+		if other, ok := seen[key]; ok {
+			return fmt.Errorf("labels: %s and %s are the same locale", other, locale)
+		}
+		seen[key] = locale
+	}
+	return nil
+}
+
+// applyLabels 把 Labels 按语言写入 string/app_name, 默认语言为 Label 或原来的应用名,
+// 并把 <application> 的 android:label 改为 @string/app_name. 需要先通过 checkLabels
+func (m *Manifest) applyLabels(root *res.Element, table *res.Table) ([]ManifestChange, error) {
+	app := root.Child("application")
+	if app == nil {
+		return nil, nil
+	}
+	a := app.AndroidAttr(res.AttrLabel)
+	old := labelString(a, table)
+	label := m.Label
+	if label == "" {
+		label = old
+	}
+	id, err := table.AddEntry(table.Package(0x7f), "string", LabelResource, res.Value{Type: res.TypeString, Data: table.Strings.Add(label)})
+	if err != nil {
+		return nil, fmt.Errorf("labels: %w", err)
+	}
+	changes := []ManifestChange{{"label", old, label}}
+	if old == label {
+		changes = nil
+	}
+	for _, locale := range sortedKeys(m.Labels) {
+		language, region, _ := parseLocale(locale)
+		var c res.Config
+		c.SetLocale(language, region)
+		before := ""
+		for _, ce := range table.Entries(id) {
+			if ce.Config.Equal(c) {
+				before, _ = table.String(ce.Entry.Value)
+			}
+		}
+		if before == m.Labels[locale] {
+			continue
+		}
+		table.SetValue(id, c, res.Value{Type: res.TypeString, Data: table.Strings.Add(m.Labels[locale])})
+		changes = append(changes, ManifestChange{"label " + c.String(), before, m.Labels[locale]})
+	}
+	if a == nil || a.Value.Type != res.TypeReference || a.Value.Data != id {
+		app.SetAttr(res.ValueAttr(res.AndroidNS, "label", res.AttrLabel, res.Value{Type: res.TypeReference, Data: id}))
+	}
+	return changes, nil
+}
+
+// labelString 返回 android:label 的字符串, 引用时返回默认语言的值
+func labelString(a *res.Attr, table *res.Table) string {
+	if a == nil {
+		return ""
+	}
+	if a.Value.Type == res.TypeReference && table != nil {
+		if s, ok := table.String(table.Resolve(a.Value)); ok {
+			return s
+		}
+	}
+	return a.String()
+}
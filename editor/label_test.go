@@ -0,0 +1,87 @@
+package editor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+	"github.com/pzx521521/apk-editor/editor/zip"
+)
+
+func TestParseLocale(t *testing.T) {
+	for _, c := range []struct {
+		in       string
+		language string
+		region   string
+		err      string // 为空时应当解析成功
+	}{
+		{in: "zh", language: "zh"},
+		{in: "fil", language: "fil"},
+		{in: "zh-CN", language: "zh", region: "CN"},
+		{in: "zh_cn", language: "zh", region: "CN"},
+		{in: "ZH-cn", language: "zh", region: "CN"},
+		{in: "zh-rTW", language: "zh", region: "TW"},
+		{in: "es-419", language: "es", region: "419"},
+		{in: "", err: "want a language"},
+		{in: "z", err: "want a language"},
+		{in: "chinese", err: "want a language"},
+		{in: "12", err: "want a language"},
+		{in: "b+zh+Hans", err: "want a language"},
+		{in: "-CN", err: "want a language"},
+		{in: "zh-", err: "region"},
+		{in: "zh-C", err: "region"},
+		{in: "zh-12", err: "region"},
+		{in: "zh-r419", err: "region"},
+		{in: "zh-Hans-CN", err: "region"},
+	} {
+		language, region, err := parseLocale(c.in)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%q: %s-%s %v, want error %q", c.in, language, region, err, c.err)
+			}
+			continue
+		}
+		if err != nil || language != c.language || region != c.region {
+			t.Errorf("%q: %s-%s %v, want %s-%s", c.in, language, region, err, c.language, c.region)
+		}
+	}
+}
+
+func TestEditLabels(t *testing.T) {
+	a := newTestEditor(t)
+	a.Url = "https://example.com"
+	a.Manifest = &Manifest{Labels: map[string]string{"zh-CN": "演示", "zh-rCN": "演示"}}
+	if _, err := a.Edit(); err == nil {
+		t.Error("duplicate locale accepted")
+	}
+	a.Manifest = &Manifest{Label: "Demo", Labels: map[string]string{"zh-CN": "演示", "ja": "デモ"}}
+	out := mustEdit(t, a)
+	// 默认语言的 label 和两个语言
+	if len(a.ManifestChanges) != 3 {
+		t.Errorf("changes %v", a.ManifestChanges)
+	}
+	r, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, table, err := readTable(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, ok := table.Find("string/" + LabelResource)
+	if !ok {
+		t.Fatal("string/app_name not added")
+	}
+	labels := map[string]string{}
+	for _, ce := range table.Entries(id) {
+		labels[ce.Config.Locale()], _ = table.String(ce.Entry.Value)
+	}
+	if len(labels) != 3 || labels[""] != "Demo" || labels["zh-CN"] != "演示" || labels["ja"] != "デモ" {
+		t.Errorf("labels %v", labels)
+	}
+	x := apkManifest(t, out)
+	if a := x.Root.Child("application").AndroidAttr(res.AttrLabel); a == nil || a.Value != (res.Value{Type: res.TypeReference, Data: id}) {
+		t.Errorf("android:label %v", a)
+	}
+}
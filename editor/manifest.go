@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"strings"
+
+	"github.com/pzx521521/apk-editor/editor/res"
 )
 
 type Modifier interface {
@@ -72,3 +75,122 @@ func changeUInt32(i uint32) []byte {
 	binary.LittleEndian.PutUint32(buf, i)
 	return buf
 }
+
+// ManifestChange 记录 Edit 对 AndroidManifest.xml 的一项实际修改
+type ManifestChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+func (c ManifestChange) String() string {
+	switch {
+	case c.Old == "":
+		return c.Field + ": +" + c.New
+	case c.New == "":
+		return c.Field + ": -" + c.Old
+	}
+	return c.Field + ": " + c.Old + " -> " + c.New
+}
+
+// 包名修改后, 这些元素的 android:name 仍然指向原来包中的类, 相对类名要补全为完整类名
+var componentElements = map[string]bool{
+	"application":     true,
+	"activity":        true,
+	"activity-alias":  true,
+	"service":         true,
+	"receiver":        true,
+	"provider":        true,
+	"instrumentation": true,
+}
+
+// 包名修改后, 这些元素的 android:name 若以原包名开头也要改为新包名, 否则与原apk冲突
+var permissionElements = map[string]bool{
+	"permission":       true,
+	"permission-group": true,
+	"permission-tree":  true,
+	"uses-permission":  true,
+}
+
+// Apply 按 m 修改解析后的 AndroidManifest.xml, 旧值从文档中读取, 返回实际修改的字段.
+// table 用于解析 @string 引用的应用名和 meta-data 的资源名, 可以为nil
+func (m *Manifest) Apply(x *res.XML, table *res.Table) ([]ManifestChange, error) {
+	var changes []ManifestChange
+	root := x.Root
+	if m.Package != "" {
+		if a := root.Attr("", "package"); a != nil && a.Raw != m.Package {
+			renamePackage(x, a.Raw, m.Package)
+			changes = append(changes, ManifestChange{"package", a.Raw, m.Package})
+			a.Raw = m.Package
+		}
+	}
+	if m.VersionCode != 0 {
+		a := root.AndroidAttr(res.AttrVersionCode)
+		if a == nil || a.Value.Type == res.TypeString || a.Value.Data != m.VersionCode {
+			root.SetAttr(res.ValueAttr(res.AndroidNS, "versionCode", res.AttrVersionCode, res.Int(int32(m.VersionCode))))
+			changes = append(changes, ManifestChange{"versionCode", attrString(a), fmt.Sprint(m.VersionCode)})
+		}
+	}
+	if m.VersionName != "" {
+		a := root.AndroidAttr(res.AttrVersionName)
+		if a == nil || a.String() != m.VersionName {
+			root.SetAttr(res.StringAttr(res.AndroidNS, "versionName", res.AttrVersionName, m.VersionName))
+			changes = append(changes, ManifestChange{"versionName", attrString(a), m.VersionName})
+		}
+	}
+	if app := root.Child("application"); len(m.Labels) > 0 && table != nil {
+		labels, err := m.applyLabels(root, table)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, labels...)
+	} else if m.Label != "" && app != nil {
+		a := app.AndroidAttr(res.AttrLabel)
+		old := labelString(a, table)
+		if a == nil || old != m.Label {
+			app.SetAttr(res.StringAttr(res.AndroidNS, "label", res.AttrLabel, m.Label))
+			changes = append(changes, ManifestChange{"label", old, m.Label})
+		}
+	}
+	changes = append(changes, m.applyPermissions(root)...)
+	changes = append(changes, m.applySdk(root)...)
+	changes = append(changes, m.applyDeepLinks(root)...)
+	changes = append(changes, m.applyFlags(root)...)
+	changes = append(changes, m.applyMetaData(root, table)...)
+	return changes, nil
+}
+
+// renamePackage 补全相对类名, 并把以原包名开头的 authorities 和权限名改为新包名
+func renamePackage(x *res.XML, old, new string) {
+	rename := func(s string) string {
+		if s == old || strings.HasPrefix(s, old+".") {
+			return new + s[len(old):]
+		}
+		return s
+	}
+	x.Walk(func(e *res.Element) {
+		for _, a := range e.Attrs {
+			if a.Value.Type != res.TypeString {
+				continue
+			}
+			switch {
+			case componentElements[e.Name] && (a.ID == res.AttrName || a.ID == res.AttrBackupAgent),
+				a.ID == res.AttrTargetActivity:
+				if strings.HasPrefix(a.Raw, ".") {
+					a.Raw = old + a.Raw
+				} else if !strings.Contains(a.Raw, ".") {
+					a.Raw = old + "." + a.Raw
+				}
+			case a.ID == res.AttrAuthorities:
+				parts := strings.Split(a.Raw, ";")
+				for i, p := range parts {
+					parts[i] = rename(p)
+				}
+				a.Raw = strings.Join(parts, ";")
+			case permissionElements[e.Name] && a.ID == res.AttrName,
+				a.ID == res.AttrPermission, a.ID == res.AttrReadPermission, a.ID == res.AttrWritePermission:
+				a.Raw = rename(a.Raw)
+			}
+		}
+	})
+}
@@ -1,11 +1,15 @@
 package editor
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"testing"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+	"github.com/pzx521521/apk-editor/editor/zip"
 )
 
 func TestDecompressXML(t *testing.T) {
@@ -32,3 +36,105 @@ func TestAdjustStringLength(t *testing.T) {
 		fmt.Printf("file not exist\n")
 	}
 }
+
+func TestManifestApply(t *testing.T) {
+	// 第一次修改后的apk再作为模板修改, 旧值应从manifest中读取而不是 DefaultManifest
+	a := newTestEditor(t)
+	a.Url = "https://example.com"
+	a.Manifest = &Manifest{VersionCode: 2, VersionName: "2.0", Label: "First", Package: "com.example.first"}
+	first := mustEdit(t, a)
+	if len(a.ManifestChanges) != 4 {
+		t.Errorf("changes %v", a.ManifestChanges)
+	}
+	_, key, crt := templateFiles(t)
+	b := NewApkEditor(first, key, crt)
+	b.Url = "https://example.com"
+	b.Manifest = &Manifest{VersionCode: 3, Label: "First", Package: "com.example.second"}
+	second := mustEdit(t, b)
+	want := []ManifestChange{{"package", "com.example.first", "com.example.second"}, {"versionCode", "2", "3"}}
+	if fmt.Sprint(b.ManifestChanges) != fmt.Sprint(want) {
+		t.Errorf("changes %v, want %v", b.ManifestChanges, want)
+	}
+	x := apkManifest(t, second)
+	if p := x.Root.Attr("", "package").Raw; p != "com.example.second" {
+		t.Errorf("package %s", p)
+	}
+	if v := x.Root.AndroidAttr(res.AttrVersionName).Raw; v != "2.0" {
+		t.Errorf("versionName %s", v)
+	}
+	// 类名不随包名改变
+	activity := x.Root.Child("application").Child("activity")
+	if name := activity.AndroidAttr(res.AttrName).Raw; name != "com.parap.webview.MainActivity" {
+		t.Errorf("activity %s", name)
+	}
+}
+
+// apkManifest 返回apk中解析后的 AndroidManifest.xml
+func apkManifest(t *testing.T, apk []byte) *res.XML {
+	t.Helper()
+	r, err := zip.NewReader(bytes.NewReader(apk), int64(len(apk)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := readManifest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := res.ParseXML(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return x
+}
+
+func TestManifestPermissions(t *testing.T) {
+	x, err := res.ParseXML(templateManifest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	camera, _ := ParsePermission("CAMERA")
+	storage, err := ParsePermission("WRITE_EXTERNAL_STORAGE:28")
+	if err != nil || storage.MaxSdkVersion != 28 {
+		t.Fatalf("%+v %v", storage, err)
+	}
+	m := &Manifest{AddPermissions: []Permission{camera, storage}, RemovePermissions: []string{"ACCESS_NETWORK_STATE"}}
+	changes, err := m.Apply(x, nil)
+	if err != nil || len(changes) != 5 {
+		t.Errorf("changes %v", changes)
+	}
+	x, err = res.ParseXML(x.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := newAndroidManifest(x, nil)
+	want := []UsesPermission{{"android.permission.INTERNET", 0}, {"android.permission.CAMERA", 0}, {"android.permission.WRITE_EXTERNAL_STORAGE", 28}}
+	if fmt.Sprint(got.Permissions) != fmt.Sprint(want) {
+		t.Errorf("permissions %v, want %v", got.Permissions, want)
+	}
+	if len(got.Features) != 1 || got.Features[0].Name != "android.hardware.camera" || got.Features[0].Required {
+		t.Errorf("features %+v", got.Features)
+	}
+	// 再次添加相同的权限不做修改
+	if changes, err := m.Apply(x, nil); err != nil || len(changes) != 0 {
+		t.Errorf("changes %v", changes)
+	}
+}
+
+func templateManifest(t *testing.T) []byte {
+	t.Helper()
+	return templateEntry(t, zip.ANDROIDMANIFEST)
+}
+
+func templateEntry(t *testing.T, name string) []byte {
+	t.Helper()
+	apk, _, _ := templateFiles(t)
+	r, err := zip.NewReader(bytes.NewReader(apk), int64(len(apk)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := readEntry(r, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
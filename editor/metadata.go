@@ -0,0 +1,133 @@
+package editor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+)
+
+// resourceID 解析 @type/name 形式的资源名或 0x7f110000 形式的资源id
+func resourceID(table *res.Table, s string) (uint32, error) {
+	if strings.HasPrefix(s, "0x") {
+		id, err := strconv.ParseUint(s[2:], 16, 32)
+		if err != nil {
+			return 0, fmt.Errorf("resource %q: %w", s, err)
+		}
+		return uint32(id), nil
+	}
+	if table == nil {
+		return 0, fmt.Errorf("resource %q: no resources.arsc", s)
+	}
+	id, ok := table.Find(s)
+	if !ok {
+		return 0, fmt.Errorf("resource %q not found", s)
+	}
+	return id, nil
+}
+
+// checkMetaData 检查 meta-data 的名称不为空且不重复, 资源名都能找到
+func (m *Manifest) checkMetaData(table *res.Table) error {
+	seen := map[string]bool{}
+	for _, names := range [][]string{sortedKeys(m.MetaData), sortedKeys(m.MetaDataInt), sortedKeys(m.MetaDataBool), sortedKeys(m.MetaDataResource)} {
+		for _, name := range names {
+			if name == "" {
+				return fmt.Errorf("meta-data: empty name")
+			}
+			if seen[name] {
+				return fmt.Errorf("meta-data %s: set more than once", name)
+			}
+			seen[name] = true
+		}
+	}
+	for name, s := range m.MetaDataResource {
+		if _, err := resourceID(table, s); err != nil {
+			return fmt.Errorf("meta-data %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// applyMetaData 删除 m.RemoveMetaData, 添加或修改 <application> 下的 meta-data. 名称相同的 meta-data 只修改值,
+// android:value 和 android:resource 只保留一个
+func (m *Manifest) applyMetaData(root *res.Element, table *res.Table) []ManifestChange {
+	app := root.Child("application")
+	if app == nil {
+		return nil
+	}
+	var changes []ManifestChange
+	for _, name := range m.RemoveMetaData {
+		if e := metaDataElement(app, name); e != nil {
+			app.RemoveChild(e)
+			changes = append(changes, ManifestChange{"meta-data " + name, metaDataString(e), ""})
+		}
+	}
+	set := func(name string, a *res.Attr, s string) {
+		e := metaDataElement(app, name)
+		if e == nil {
+			e = res.NewElement("meta-data", res.StringAttr(res.AndroidNS, "name", res.AttrName, name))
+			app.AppendChild("meta-data", e)
+		}
+		old := e.AndroidAttr(a.ID)
+		other := e.AndroidAttr(res.AttrValue)
+		if a.ID == res.AttrValue {
+			other = e.AndroidAttr(res.AttrResource)
+		}
+		if old != nil && other == nil && old.Value.Type == a.Value.Type && old.String() == a.String() {
+			return
+		}
+		oldString := metaDataString(e)
+		if other != nil {
+			e.RemoveAttr(res.AndroidNS, other.Name)
+		}
+		e.SetAttr(a)
+		changes = append(changes, ManifestChange{"meta-data " + name, oldString, s})
+	}
+	for _, name := range sortedKeys(m.MetaData) {
+		set(name, res.StringAttr(res.AndroidNS, "value", res.AttrValue, m.MetaData[name]), m.MetaData[name])
+	}
+	for _, name := range sortedKeys(m.MetaDataInt) {
+		v := m.MetaDataInt[name]
+		set(name, res.ValueAttr(res.AndroidNS, "value", res.AttrValue, res.Int(v)), strconv.Itoa(int(v)))
+	}
+	for _, name := range sortedKeys(m.MetaDataBool) {
+		v := m.MetaDataBool[name]
+		set(name, res.ValueAttr(res.AndroidNS, "value", res.AttrValue, res.Bool(v)), strconv.FormatBool(v))
+	}
+	for _, name := range sortedKeys(m.MetaDataResource) {
+		id, err := resourceID(table, m.MetaDataResource[name])
+		if err != nil {
+			continue
+		}
+		set(name, res.ValueAttr(res.AndroidNS, "resource", res.AttrResource, res.Reference(id)), m.MetaDataResource[name])
+	}
+	return changes
+}
+
+func metaDataElement(app *res.Element, name string) *res.Element {
+	for _, e := range app.ChildrenNamed("meta-data") {
+		if attrString(e.AndroidAttr(res.AttrName)) == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// metaDataString 返回 meta-data 的值, 资源引用写为 @0x7f110000
+func metaDataString(e *res.Element) string {
+	if a := e.AndroidAttr(res.AttrValue); a != nil {
+		return a.String()
+	}
+	return attrString(e.AndroidAttr(res.AttrResource))
+}
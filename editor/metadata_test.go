@@ -0,0 +1,61 @@
+package editor
+
+import (
+	"testing"
+)
+
+func TestEditMetaData(t *testing.T) {
+	a := newTestEditor(t)
+	a.Url = "https://example.com"
+	a.Manifest = &Manifest{MetaDataResource: map[string]string{"config": "@xml/missing"}}
+	if _, err := a.Edit(); err == nil {
+		t.Error("missing resource accepted")
+	}
+	a.Manifest = &Manifest{MetaData: map[string]string{"key": "1"}, MetaDataInt: map[string]int32{"key": 1}}
+	if _, err := a.Edit(); err == nil {
+		t.Error("duplicate meta-data accepted")
+	}
+
+	a.Manifest = &Manifest{
+		MetaData:         map[string]string{"com.example.API_KEY": "abc"},
+		MetaDataInt:      map[string]int32{"com.example.LEVEL": 3},
+		MetaDataBool:     map[string]bool{"com.example.ENABLED": true},
+		MetaDataResource: map[string]string{"com.example.NETWORK": "@xml/network_security_config"},
+	}
+	out := mustEdit(t, a)
+	if len(a.ManifestChanges) != 4 {
+		t.Errorf("changes %v", a.ManifestChanges)
+	}
+	m := manifestOf(t, out)
+	got := map[string]MetaData{}
+	for _, md := range m.Application.MetaData {
+		got[md.Name] = md
+	}
+	if got["com.example.API_KEY"].Value != "abc" || got["com.example.LEVEL"].Value != "3" ||
+		got["com.example.ENABLED"].Value != "true" || got["com.example.NETWORK"].Resource != "@xml/network_security_config" {
+		t.Errorf("meta-data %+v", m.Application.MetaData)
+	}
+
+	// 修改值, 值改为资源, 删除
+	a = NewApkEditor(out, a.keyBytes, a.certBytes)
+	a.Url = "https://example.com"
+	a.Manifest = &Manifest{
+		MetaData:         map[string]string{"com.example.API_KEY": "abc"},
+		MetaDataInt:      map[string]int32{"com.example.LEVEL": 4},
+		MetaDataResource: map[string]string{"com.example.ENABLED": "0x7f110000"},
+		RemoveMetaData:   []string{"com.example.NETWORK"},
+	}
+	out = mustEdit(t, a)
+	if len(a.ManifestChanges) != 3 {
+		t.Errorf("changes %v", a.ManifestChanges)
+	}
+	m = manifestOf(t, out)
+	if len(m.Application.MetaData) != 3 {
+		t.Errorf("meta-data %+v", m.Application.MetaData)
+	}
+	for _, md := range m.Application.MetaData {
+		if md.Name == "com.example.ENABLED" && (md.Value != "" || md.Resource != "@xml/network_security_config") {
+			t.Errorf("meta-data %+v", md)
+		}
+	}
+}
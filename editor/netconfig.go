@@ -0,0 +1,196 @@
+package editor
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+	"github.com/pzx521521/apk-editor/editor/zip"
+)
+
+// NetworkSecurityConfig 重新生成 android:networkSecurityConfig 指向的xml(模板中为 res/xml/network_security_config.xml).
+// 生成的配置默认禁止明文http, 只信任系统CA. WebView 遵守明文限制, 证书固定只对应用自身的连接生效
+type NetworkSecurityConfig struct {
+	// CleartextDomains 允许明文http的域名, 包含子域名
+	CleartextDomains []string `json:"cleartext_domains,omitempty"`
+	// Pins 固定证书公钥的域名
+	Pins []CertificatePin `json:"pins,omitempty"`
+	// DebugUserCAs 为true时只在 debuggable 的apk中信任用户安装的CA, 用于抓包调试
+	DebugUserCAs bool `json:"debug_user_cas,omitempty"`
+}
+
+// CertificatePin 是一个域名(包含子域名)的证书公钥固定
+type CertificatePin struct {
+	Domain string `json:"domain"`
+	// SHA256 证书 SubjectPublicKeyInfo 的SHA-256的base64, 应至少包含一个备用公钥
+	SHA256 []string `json:"sha256"`
+	// Expiration 过期日期, 如 2027-01-01, 过期后不再校验固定的公钥. 为空表示不过期
+	Expiration string `json:"expiration,omitempty"`
+}
+
+// ParseCertificatePin 解析 domain=sha256,sha256[@2027-01-01] 形式的证书固定
+func ParseCertificatePin(s string) (CertificatePin, error) {
+	domain, pins, ok := strings.Cut(s, "=")
+	if !ok {
+		return CertificatePin{}, fmt.Errorf("pin %q: want domain=sha256[,sha256][@expiration]", s)
+	}
+	p := CertificatePin{Domain: domain}
+	pins, p.Expiration, _ = strings.Cut(pins, "@")
+	p.SHA256 = strings.Split(pins, ",")
+	return p, p.check(time.Now())
+}
+
+// check 检查公钥和过期日期的格式, 已过期的固定返回错误
+func (p *CertificatePin) check(now time.Time) error {
+	if err := checkDomain(p.Domain); err != nil {
+		return err
+	}
+	if len(p.SHA256) == 0 {
+		return fmt.Errorf("pin %s: no sha256", p.Domain)
+	}
+	for _, s := range p.SHA256 {
+		if b, err := base64.StdEncoding.DecodeString(s); err != nil || len(b) != 32 {
+			return fmt.Errorf("pin %s: %q is not a base64 SHA-256 digest", p.Domain, s)
+		}
+	}
+	if p.Expiration != "" {
+		t, err := time.Parse("2006-01-02", p.Expiration)
+		if err != nil {
+			return fmt.Errorf("pin %s: expiration %q: want yyyy-mm-dd", p.Domain, p.Expiration)
+		}
+		if !t.After(now) {
+			return fmt.Errorf("pin %s: expired on %s", p.Domain, p.Expiration)
+		}
+	}
+	return nil
+}
+
+func checkDomain(d string) error {
+	if d == "" || strings.ContainsAny(d, "/:*@ ") {
+		return fmt.Errorf("domain %q: want a host name such as example.com", d)
+	}
+	return nil
+}
+
+// check 检查域名和证书固定, 返回需要注意的问题
+func (c *NetworkSecurityConfig) check(now time.Time) ([]string, error) {
+	domains := map[string]bool{}
+	for _, d := range c.CleartextDomains {
+		if err := checkDomain(d); err != nil {
+			return nil, fmt.Errorf("cleartext %w", err)
+		}
+		domains[strings.ToLower(d)] = true
+	}
+	var warnings []string
+	for _, p := range c.Pins {
+		if err := p.check(now); err != nil {
+			return nil, err
+		}
+		// 同一个域名只能出现在一个 domain-config 中
+		if domains[strings.ToLower(p.Domain)] {
+			return nil, fmt.Errorf("pin %s: domain is also allowed cleartext", p.Domain)
+		}
+		domains[strings.ToLower(p.Domain)] = true
+		if len(p.SHA256) == 1 {
+			warnings = append(warnings, fmt.Sprintf("pin %s has no backup key, the app stops connecting when the certificate key changes", p.Domain))
+		}
+	}
+	return warnings, nil
+}
+
+// xml 生成配置的二进制xml. 与aapt2相同, 布尔属性编译为布尔值, 其他属性为字符串
+func (c *NetworkSecurityConfig) xml() *res.XML {
+	boolAttr := func(name string, b bool) *res.Attr {
+		return res.ValueAttr("", name, 0, res.Bool(b))
+	}
+	text := func(s string) *res.Element {
+		return &res.Element{Text: s}
+	}
+	anchors := func(src ...string) *res.Element {
+		e := res.NewElement("trust-anchors")
+		for _, s := range src {
+			e.Children = append(e.Children, res.NewElement("certificates", res.StringAttr("", "src", 0, s)))
+		}
+		return e
+	}
+	domain := func(name string) *res.Element {
+		e := res.NewElement("domain", boolAttr("includeSubdomains", true))
+		e.Children = append(e.Children, text(name))
+		return e
+	}
+	root := res.NewElement("network-security-config")
+	base := res.NewElement("base-config", boolAttr("cleartextTrafficPermitted", false))
+	base.Children = append(base.Children, anchors("system"))
+	root.Children = append(root.Children, base)
+	if len(c.CleartextDomains) > 0 {
+		e := res.NewElement("domain-config", boolAttr("cleartextTrafficPermitted", true))
+		for _, d := range c.CleartextDomains {
+			e.Children = append(e.Children, domain(d))
+		}
+		root.Children = append(root.Children, e)
+	}
+	for _, p := range c.Pins {
+		e := res.NewElement("domain-config")
+		set := res.NewElement("pin-set")
+		if p.Expiration != "" {
+			set.SetAttr(res.StringAttr("", "expiration", 0, p.Expiration))
+		}
+		for _, s := range p.SHA256 {
+			pin := res.NewElement("pin", res.StringAttr("", "digest", 0, "SHA-256"))
+			pin.Children = append(pin.Children, text(s))
+			set.Children = append(set.Children, pin)
+		}
+		e.Children = append(e.Children, domain(p.Domain), set)
+		root.Children = append(root.Children, e)
+	}
+	if c.DebugUserCAs {
+		e := res.NewElement("debug-overrides")
+		e.Children = append(e.Children, anchors("user"))
+		root.Children = append(root.Children, e)
+	}
+	return &res.XML{Root: root}
+}
+
+// networkSecurityContent 把 a.NetworkSecurity 编译后写入 x 的 android:networkSecurityConfig 指向的每个xml文件.
+// x 为nil时使用模板的manifest
+func (a *ApkEditor) networkSecurityContent(r *zip.Reader, x *res.XML, table *res.Table) ([]*MergeEntry, error) {
+	c := a.NetworkSecurity
+	if c == nil {
+		return nil, nil
+	}
+	warnings, err := c.check(time.Now())
+	if err != nil {
+		return nil, invalid(fmt.Errorf("network security config: %w", err))
+	}
+	if a.WebView != nil && a.WebView.IgnoreSslErrors != nil && *a.WebView.IgnoreSslErrors {
+		warnings = append(warnings, "webview ignore_ssl_errors accepts any certificate in the WebView regardless of the network security config")
+	}
+	if x, err = finalManifest(r, x); err != nil {
+		return nil, fmt.Errorf("network security config: %w", err)
+	}
+	var attr *res.Attr
+	if app := x.Root.Child("application"); app != nil {
+		attr = app.AndroidAttr(res.AttrNetworkSecurityConfig)
+	}
+	if attr == nil || attr.Value.Type != res.TypeReference {
+		return nil, errors.New("network security config: manifest has no android:networkSecurityConfig")
+	}
+	if table == nil {
+		return nil, fmt.Errorf("network security config: template has no %s", RESOURCES_ARSC)
+	}
+	b := c.xml().Marshal()
+	var mergeEntries []*MergeEntry
+	for _, ce := range table.Entries(attr.Value.Data) {
+		if file, ok := table.String(ce.Entry.Value); ok && !ce.Entry.IsComplex() {
+			mergeEntries = append(mergeEntries, &MergeEntry{file, b})
+		}
+	}
+	if len(mergeEntries) == 0 {
+		return nil, fmt.Errorf("network security config: resource 0x%08x has no xml file", attr.Value.Data)
+	}
+	a.Warnings = append(a.Warnings, warnings...)
+	return mergeEntries, nil
+}
@@ -0,0 +1,76 @@
+package editor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPin = "7HIpactkIAq2Y49orFOOQKurWxmmSFZhBCoQYcRhJ3Y="
+
+func TestParseCertificatePin(t *testing.T) {
+	p, err := ParseCertificatePin("api.example.com=" + testPin + "," + testPin + "@2099-01-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Domain != "api.example.com" || len(p.SHA256) != 2 || p.Expiration != "2099-01-01" {
+		t.Errorf("%+v", p)
+	}
+	for _, s := range []string{"api.example.com", "api.example.com=abc", "https://example.com=" + testPin, "a.com=" + testPin + "@2000-01-01", "a.com=" + testPin + "@soon"} {
+		if _, err := ParseCertificatePin(s); err == nil {
+			t.Errorf("%s accepted", s)
+		}
+	}
+}
+
+func TestNetworkSecurityCheck(t *testing.T) {
+	c := &NetworkSecurityConfig{
+		CleartextDomains: []string{"example.com"},
+		Pins:             []CertificatePin{{Domain: "Example.com", SHA256: []string{testPin}}},
+	}
+	if _, err := c.check(time.Now()); err == nil {
+		t.Error("domain both cleartext and pinned accepted")
+	}
+	c.Pins[0].Domain = "api.example.com"
+	warnings, err := c.check(time.Now())
+	if err != nil || len(warnings) != 1 {
+		t.Errorf("warnings %v %v", warnings, err)
+	}
+}
+
+func TestEditNetworkSecurity(t *testing.T) {
+	a := newTestEditor(t)
+	a.Url = "https://example.com"
+	a.NetworkSecurity = &NetworkSecurityConfig{
+		CleartextDomains: []string{"10.0.2.2", "intranet.example.com"},
+		Pins:             []CertificatePin{{Domain: "api.example.com", SHA256: []string{testPin, testPin}, Expiration: "2099-01-01"}},
+		DebugUserCAs:     true,
+	}
+	out := mustEdit(t, a)
+	if len(a.Warnings) != 0 {
+		t.Errorf("warnings %v", a.Warnings)
+	}
+	text, err := DumpXML(bytes.NewReader(out), int64(len(out)), "res/4u.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<base-config cleartextTrafficPermitted="false">`,
+		`<certificates src="system" />`,
+		`<domain-config cleartextTrafficPermitted="true">`,
+		"intranet.example.com",
+		`<pin-set expiration="2099-01-01">`,
+		testPin,
+		"<debug-overrides>",
+		`<certificates src="user" />`,
+	} {
+		if !strings.Contains(string(text), want) {
+			t.Errorf("missing %s in\n%s", want, text)
+		}
+	}
+	// 用户CA只在 debug-overrides 中
+	if strings.Count(string(text), `src="user"`) != 1 {
+		t.Errorf("user CAs trusted outside debug-overrides\n%s", text)
+	}
+}
@@ -0,0 +1,145 @@
+package editor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+)
+
+// Permission 是要添加的 <uses-permission>
+type Permission struct {
+	Name string
+	// MaxSdkVersion 大于0时只在该版本及以下申请权限, 如 WRITE_EXTERNAL_STORAGE:28
+	MaxSdkVersion int
+}
+
+// ParsePermission 解析 name 或 name:maxSdkVersion, 没有包名的权限补全为 android.permission.NAME
+func ParsePermission(s string) (Permission, error) {
+	name, max, hasMax := strings.Cut(strings.TrimSpace(s), ":")
+	p := Permission{Name: permissionName(name)}
+	if p.Name == "" {
+		return p, fmt.Errorf("permission %q: empty name", s)
+	}
+	if hasMax {
+		n, err := strconv.Atoi(strings.TrimSpace(max))
+		if err != nil || n <= 0 {
+			return p, fmt.Errorf("permission %q: bad maxSdkVersion", s)
+		}
+		p.MaxSdkVersion = n
+	}
+	return p, nil
+}
+
+func permissionName(name string) string {
+	name = strings.TrimSpace(name)
+	if name != "" && !strings.Contains(name, ".") {
+		return "android.permission." + name
+	}
+	return name
+}
+
+// permissionFeatures 这些权限会让应用商店认为需要对应的硬件, 添加权限时同时以
+// required=false 声明 feature, 使没有该硬件的设备也能安装
+var permissionFeatures = map[string][]string{
+	"android.permission.CAMERA":                 {"android.hardware.camera"},
+	"android.permission.RECORD_AUDIO":           {"android.hardware.microphone"},
+	"android.permission.ACCESS_FINE_LOCATION":   {"android.hardware.location", "android.hardware.location.gps"},
+	"android.permission.ACCESS_COARSE_LOCATION": {"android.hardware.location", "android.hardware.location.network"},
+	"android.permission.BLUETOOTH":              {"android.hardware.bluetooth"},
+	"android.permission.BLUETOOTH_CONNECT":      {"android.hardware.bluetooth"},
+	"android.permission.NFC":                    {"android.hardware.nfc"},
+	"android.permission.CALL_PHONE":             {"android.hardware.telephony"},
+	"android.permission.READ_SMS":               {"android.hardware.telephony"},
+	"android.permission.SEND_SMS":               {"android.hardware.telephony"},
+}
+
+// manifestOrder 是 manifest 顶层元素的顺序, 新元素插入到同类元素之后
+var manifestOrder = []string{"uses-sdk", "uses-permission", "uses-permission-sdk-23", "permission", "uses-feature"}
+
+// insertTopLevel 把 e 插入到 root 中同类元素之后, 没有时插入到顺序在它之前的元素之后
+func insertTopLevel(root, e *res.Element) {
+	idx := -1
+	for i, name := range manifestOrder {
+		if name == e.Name {
+			idx = i
+		}
+	}
+	for i := idx; i >= 0; i-- {
+		for j := len(root.Children) - 1; j >= 0; j-- {
+			if root.Children[j].Name == manifestOrder[i] {
+				root.InsertChild(j+1, e)
+				return
+			}
+		}
+	}
+	root.InsertChild(0, e)
+}
+
+// applyPermissions 删除 m.RemovePermissions 中的权限, 添加 m.AddPermissions 中的权限和对应的feature
+func (m *Manifest) applyPermissions(root *res.Element) []ManifestChange {
+	var changes []ManifestChange
+	remove := map[string]bool{}
+	for _, name := range m.RemovePermissions {
+		remove[permissionName(name)] = true
+	}
+	for _, e := range append([]*res.Element{}, root.Children...) {
+		if e.Name != "uses-permission" && e.Name != "uses-permission-sdk-23" {
+			continue
+		}
+		if a := e.AndroidAttr(res.AttrName); a != nil && remove[a.Raw] {
+			root.RemoveChild(e)
+			changes = append(changes, ManifestChange{"permission", a.Raw, ""})
+		}
+	}
+	for _, p := range m.AddPermissions {
+		p.Name = permissionName(p.Name)
+		var e *res.Element
+		for _, c := range root.ChildrenNamed("uses-permission") {
+			if a := c.AndroidAttr(res.AttrName); a != nil && a.Raw == p.Name {
+				e = c
+			}
+		}
+		if e == nil {
+			e = res.NewElement("uses-permission", res.StringAttr(res.AndroidNS, "name", res.AttrName, p.Name))
+			insertTopLevel(root, e)
+			changes = append(changes, ManifestChange{"permission", "", p.Name})
+		}
+		old := e.AndroidAttr(res.AttrMaxSdkVersion)
+		if p.MaxSdkVersion > 0 && (old == nil || old.Value != res.Int(int32(p.MaxSdkVersion))) {
+			e.SetAttr(res.ValueAttr(res.AndroidNS, "maxSdkVersion", res.AttrMaxSdkVersion, res.Int(int32(p.MaxSdkVersion))))
+			changes = append(changes, ManifestChange{p.Name + " maxSdkVersion", attrString(old), strconv.Itoa(p.MaxSdkVersion)})
+		} else if p.MaxSdkVersion == 0 && old != nil {
+			e.RemoveAttr(res.AndroidNS, "maxSdkVersion")
+			changes = append(changes, ManifestChange{p.Name + " maxSdkVersion", old.String(), ""})
+		}
+		for _, feature := range permissionFeatures[p.Name] {
+			if hasFeature(root, feature) {
+				continue
+			}
+			insertTopLevel(root, res.NewElement("uses-feature",
+				res.StringAttr(res.AndroidNS, "name", res.AttrName, feature),
+				res.ValueAttr(res.AndroidNS, "required", res.AttrRequired, res.Bool(false))))
+			changes = append(changes, ManifestChange{"feature", "", feature + " (required=false)"})
+		}
+	}
+	return changes
+}
+
+func hasFeature(root *res.Element, name string) bool {
+	for _, e := range root.ChildrenNamed("uses-feature") {
+		if a := e.AndroidAttr(res.AttrName); a != nil && a.Raw == name {
+			return true
+		}
+	}
+	return false
+}
+
+// attrString 返回属性的文本值, 属性不存在时为空
+func attrString(a *res.Attr) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
@@ -0,0 +1,36 @@
+package editor
+
+import "testing"
+
+func TestParsePermission(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    Permission
+		wantErr bool
+	}{
+		{in: "CAMERA", want: Permission{Name: "android.permission.CAMERA"}},
+		{in: " CAMERA ", want: Permission{Name: "android.permission.CAMERA"}},
+		{in: "com.example.permission.C2D", want: Permission{Name: "com.example.permission.C2D"}},
+		{in: "WRITE_EXTERNAL_STORAGE:28", want: Permission{Name: "android.permission.WRITE_EXTERNAL_STORAGE", MaxSdkVersion: 28}},
+		{in: "WRITE_EXTERNAL_STORAGE : 28", want: Permission{Name: "android.permission.WRITE_EXTERNAL_STORAGE", MaxSdkVersion: 28}},
+		{in: "", wantErr: true},
+		{in: "  ", wantErr: true},
+		{in: ":28", wantErr: true},
+		{in: "CAMERA:", wantErr: true},
+		{in: "CAMERA:abc", wantErr: true},
+		{in: "CAMERA:0", wantErr: true},
+		{in: "CAMERA:-1", wantErr: true},
+		{in: "CAMERA:28:1", wantErr: true},
+	} {
+		got, err := ParsePermission(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParsePermission(%q) = %+v, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParsePermission(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package editor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pzx521521/apk-editor/editor/signv2"
+	"github.com/pzx521521/apk-editor/editor/zip"
+)
+
+func TestEditRemove(t *testing.T) {
+	a := newTestEditor(t)
+	a.IndexHtml = []byte("<html></html>")
+	a.Remove = []string{"assets/default.txt", "META-INF/com/", "lib/x86/"}
+	apk := mustEdit(t, a)
+	r, err := zip.NewReader(bytes.NewReader(apk), int64(len(apk)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := false
+	for _, f := range r.File {
+		if f.Name == "assets/default.txt" || strings.HasPrefix(f.Name, "META-INF/com/") {
+			t.Errorf("%s not removed", f.Name)
+		}
+		if strings.HasSuffix(f.Name, "index.html") {
+			index = true
+		}
+	}
+	if !index {
+		t.Error("merged index.html missing")
+	}
+	// 模板中没有的文件给出警告
+	if len(a.Warnings) != 1 || !strings.Contains(a.Warnings[0], "lib/x86/") {
+		t.Errorf("warnings %v", a.Warnings)
+	}
+	z, err := signv2.NewApkSign(apk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := z.VerifyV2(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEditRemoveProtected(t *testing.T) {
+	a := newTestEditor(t)
+	a.Url = "https://example.com"
+	for _, remove := range []string{RESOURCES_ARSC, zip.ANDROIDMANIFEST, "classes.dex", "assets/url.txt", "assets/"} {
+		a.Remove = []string{remove}
+		if _, err := a.Edit(); err == nil {
+			t.Errorf("removed %s", remove)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package res
+
+// AttrFormat is the format mask of an attribute (ResTable_map::TYPE_*), saying which kinds of
+// values the attribute accepts.
+type AttrFormat uint32
+
+const (
+	FormatReference AttrFormat = 1 << 0
+	FormatString    AttrFormat = 1 << 1
+	FormatInteger   AttrFormat = 1 << 2
+	FormatBoolean   AttrFormat = 1 << 3
+	FormatColor     AttrFormat = 1 << 4
+	FormatFloat     AttrFormat = 1 << 5
+	FormatDimension AttrFormat = 1 << 6
+	FormatFraction  AttrFormat = 1 << 7
+	FormatEnum      AttrFormat = 1 << 16
+	FormatFlags     AttrFormat = 1 << 17
+
+	FormatAny = FormatReference | FormatString | FormatInteger | FormatBoolean | FormatColor |
+		FormatFloat | FormatDimension | FormatFraction
+)
+
+// AttrDef is the definition of a framework attribute: its resource id, the formats it accepts
+// and, for enums and flags, the symbol values.
+type AttrDef struct {
+	ID      uint32
+	Format  AttrFormat
+	Symbols map[string]uint32
+}
+
+var launchModes = map[string]uint32{
+	"standard":              0,
+	"singleTop":             1,
+	"singleTask":            2,
+	"singleInstance":        3,
+	"singleInstancePerTask": 4,
+}
+
+var screenOrientations = map[string]uint32{
+	"unspecified":      0xFFFFFFFF,
+	"landscape":        0,
+	"portrait":         1,
+	"user":             2,
+	"behind":           3,
+	"sensor":           4,
+	"nosensor":         5,
+	"sensorLandscape":  6,
+	"sensorPortrait":   7,
+	"reverseLandscape": 8,
+	"reversePortrait":  9,
+	"fullSensor":       10,
+	"userLandscape":    11,
+	"userPortrait":     12,
+	"fullUser":         13,
+	"locked":           14,
+}
+
+var configChanges = map[string]uint32{
+	"mcc":                0x0001,
+	"mnc":                0x0002,
+	"locale":             0x0004,
+	"touchscreen":        0x0008,
+	"keyboard":           0x0010,
+	"keyboardHidden":     0x0020,
+	"navigation":         0x0040,
+	"orientation":        0x0080,
+	"screenLayout":       0x0100,
+	"uiMode":             0x0200,
+	"screenSize":         0x0400,
+	"smallestScreenSize": 0x0800,
+	"density":            0x1000,
+	"layoutDirection":    0x2000,
+	"colorMode":          0x4000,
+	"fontScale":          0x40000000,
+}
+
+var softInputModes = map[string]uint32{
+	"stateUnspecified":   0x00,
+	"stateUnchanged":     0x01,
+	"stateHidden":        0x02,
+	"stateAlwaysHidden":  0x03,
+	"stateVisible":       0x04,
+	"stateAlwaysVisible": 0x05,
+	"adjustUnspecified":  0x00,
+	"adjustResize":       0x10,
+	"adjustPan":          0x20,
+	"adjustNothing":      0x30,
+}
+
+var protectionLevels = map[string]uint32{
+	"normal":            0x00,
+	"dangerous":         0x01,
+	"signature":         0x02,
+	"signatureOrSystem": 0x03,
+	"privileged":        0x10,
+	"system":            0x10,
+	"development":       0x20,
+	"appop":             0x40,
+	"pre23":             0x80,
+	"installer":         0x100,
+	"verifier":          0x200,
+	"preinstalled":      0x400,
+}
+
+var installLocations = map[string]uint32{
+	"auto":           0,
+	"internalOnly":   1,
+	"preferExternal": 2,
+}
+
+// androidAttrs are the android: attributes that can be compiled from text, by name. It covers
+// the attributes used in manifests; ids are from frameworks/base/core/res/res/values/public.xml
+// and formats from attrs_manifest.xml.
+var androidAttrs = map[string]AttrDef{
+	"theme":                     {AttrTheme, FormatReference, nil},
+	"label":                     {AttrLabel, FormatReference | FormatString, nil},
+	"icon":                      {AttrIcon, FormatReference, nil},
+	"name":                      {AttrName, FormatString, nil},
+	"manageSpaceActivity":       {0x01010004, FormatString, nil},
+	"allowClearUserData":        {0x01010005, FormatBoolean, nil},
+	"permission":                {AttrPermission, FormatString, nil},
+	"readPermission":            {AttrReadPermission, FormatString, nil},
+	"writePermission":           {AttrWritePermission, FormatString, nil},
+	"protectionLevel":           {0x01010009, FormatFlags, protectionLevels},
+	"permissionGroup":           {0x0101000a, FormatString, nil},
+	"sharedUserId":              {0x0101000b, FormatString, nil},
+	"hasCode":                   {0x0101000c, FormatBoolean, nil},
+	"persistent":                {0x0101000d, FormatBoolean, nil},
+	"enabled":                   {0x0101000e, FormatBoolean, nil},
+	"debuggable":                {AttrDebuggable, FormatBoolean, nil},
+	"exported":                  {AttrExported, FormatBoolean, nil},
+	"process":                   {0x01010011, FormatString, nil},
+	"taskAffinity":              {0x01010012, FormatString, nil},
+	"multiprocess":              {0x01010013, FormatBoolean, nil},
+	"finishOnTaskLaunch":        {0x01010014, FormatBoolean, nil},
+	"clearTaskOnLaunch":         {0x01010015, FormatBoolean, nil},
+	"stateNotNeeded":            {0x01010016, FormatBoolean, nil},
+	"excludeFromRecents":        {0x01010017, FormatBoolean, nil},
+	"authorities":               {AttrAuthorities, FormatString, nil},
+	"syncable":                  {0x01010019, FormatBoolean, nil},
+	"initOrder":                 {0x0101001a, FormatInteger, nil},
+	"grantUriPermissions":       {0x0101001b, FormatBoolean, nil},
+	"priority":                  {0x0101001c, FormatInteger, nil},
+	"launchMode":                {0x0101001d, FormatEnum, launchModes},
+	"screenOrientation":         {AttrScreenOrientation, FormatEnum, screenOrientations},
+	"configChanges":             {0x0101001f, FormatFlags, configChanges},
+	"description":               {0x01010020, FormatReference | FormatString, nil},
+	"targetPackage":             {AttrTargetPackage, FormatString, nil},
+	"handleProfiling":           {0x01010022, FormatBoolean, nil},
+	"functionalTest":            {0x01010023, FormatBoolean, nil},
+	"value":                     {AttrValue, FormatString | FormatInteger | FormatBoolean | FormatColor | FormatFloat, nil},
+	"resource":                  {AttrResource, FormatReference, nil},
+	"mimeType":                  {AttrMimeType, FormatString, nil},
+	"scheme":                    {AttrScheme, FormatString, nil},
+	"host":                      {AttrHost, FormatString, nil},
+	"port":                      {AttrPort, FormatString, nil},
+	"path":                      {AttrPath, FormatString, nil},
+	"pathPrefix":                {AttrPathPrefix, FormatString, nil},
+	"pathPattern":               {AttrPathPattern, FormatString, nil},
+	"action":                    {0x0101002d, FormatString, nil},
+	"data":                      {0x0101002e, FormatString, nil},
+	"targetClass":               {0x0101002f, FormatString, nil},
+	"targetActivity":            {AttrTargetActivity, FormatString, nil},
+	"alwaysRetainTaskState":     {0x01010203, FormatBoolean, nil},
+	"allowTaskReparenting":      {0x01010204, FormatBoolean, nil},
+	"minSdkVersion":             {AttrMinSdkVersion, FormatInteger | FormatString, nil},
+	"versionCode":               {AttrVersionCode, FormatInteger, nil},
+	"versionName":               {AttrVersionName, FormatString, nil},
+	"windowSoftInputMode":       {AttrWindowSoftInputMode, FormatFlags, softInputModes},
+	"targetSdkVersion":          {AttrTargetSdkVersion, FormatInteger | FormatString, nil},
+	"maxSdkVersion":             {AttrMaxSdkVersion, FormatInteger, nil},
+	"testOnly":                  {0x01010272, FormatBoolean, nil},
+	"backupAgent":               {AttrBackupAgent, FormatString, nil},
+	"allowBackup":               {AttrAllowBackup, FormatBoolean, nil},
+	"glEsVersion":               {AttrGlEsVersion, FormatInteger, nil},
+	"required":                  {AttrRequired, FormatBoolean, nil},
+	"installLocation":           {0x010102b7, FormatEnum, installLocations},
+	"hardwareAccelerated":       {0x010102d3, FormatBoolean, nil},
+	"largeHeap":                 {0x01010344, FormatBoolean, nil},
+	"parentActivityName":        {0x010103a7, FormatString, nil},
+	"isolatedProcess":           {0x010103a9, FormatBoolean, nil},
+	"supportsRtl":               {0x010103af, FormatBoolean, nil},
+	"extractNativeLibs":         {0x010104ea, FormatBoolean, nil},
+	"fullBackupContent":         {0x010104eb, FormatReference | FormatBoolean, nil},
+	"usesCleartextTraffic":      {AttrUsesCleartextTraffic, FormatBoolean, nil},
+	"autoVerify":                {AttrAutoVerify, FormatBoolean, nil},
+	"resizeableActivity":        {AttrResizeableActivity, FormatBoolean, nil},
+	"networkSecurityConfig":     {AttrNetworkSecurityConfig, FormatReference, nil},
+	"roundIcon":                 {AttrRoundIcon, FormatReference, nil},
+	"compileSdkVersion":         {AttrCompileSdkVersion, FormatInteger, nil},
+	"compileSdkVersionCodename": {0x01010573, FormatString, nil},
+	"appComponentFactory":       {0x0101057a, FormatString, nil},
+}
+
+// AndroidAttrDef returns the definition of the android: attribute name.
+func AndroidAttrDef(name string) (AttrDef, bool) {
+	d, ok := androidAttrs[name]
+	return d, ok
+}
@@ -0,0 +1,56 @@
+package res
+
+// Resource ids of the android: attributes used by the editor, from
+// frameworks/base/core/res/res/values/public.xml. Compiled XML refers to attributes by these
+// ids; the names are only kept for tools.
+const (
+	AttrTheme                 = 0x01010000
+	AttrLabel                 = 0x01010001
+	AttrIcon                  = 0x01010002
+	AttrName                  = 0x01010003
+	AttrPermission            = 0x01010006
+	AttrReadPermission        = 0x01010007
+	AttrWritePermission       = 0x01010008
+	AttrDebuggable            = 0x0101000f
+	AttrExported              = 0x01010010
+	AttrAuthorities           = 0x01010018
+	AttrScreenOrientation     = 0x0101001e
+	AttrColorBackground       = 0x01010031
+	AttrWindowBackground      = 0x01010054
+	AttrGravity               = 0x010100af
+	AttrDrawable              = 0x01010199
+	AttrTargetPackage         = 0x01010021
+	AttrValue                 = 0x01010024
+	AttrResource              = 0x01010025
+	AttrMimeType              = 0x01010026
+	AttrScheme                = 0x01010027
+	AttrHost                  = 0x01010028
+	AttrPort                  = 0x01010029
+	AttrPath                  = 0x0101002a
+	AttrPathPrefix            = 0x0101002b
+	AttrPathPattern           = 0x0101002c
+	AttrTargetActivity        = 0x01010202
+	AttrMinSdkVersion         = 0x0101020c
+	AttrVersionCode           = 0x0101021b
+	AttrVersionName           = 0x0101021c
+	AttrWindowSoftInputMode   = 0x0101022b
+	AttrTargetSdkVersion      = 0x01010270
+	AttrMaxSdkVersion         = 0x01010271
+	AttrBackupAgent           = 0x0101027f
+	AttrAllowBackup           = 0x01010280
+	AttrGlEsVersion           = 0x01010281
+	AttrRequired              = 0x0101028e
+	AttrColorPrimary          = 0x01010433
+	AttrColorPrimaryDark      = 0x01010434
+	AttrStatusBarColor        = 0x01010451
+	AttrUsesCleartextTraffic  = 0x010104ec
+	AttrAutoVerify            = 0x010104ee
+	AttrResizeableActivity    = 0x010104f6
+	AttrNetworkSecurityConfig = 0x01010527
+	AttrRoundIcon             = 0x0101052c
+	AttrCompileSdkVersion     = 0x01010572
+
+	// API 31
+	AttrWindowSplashScreenBackground   = 0x0101062c
+	AttrWindowSplashScreenAnimatedIcon = 0x0101062d
+)
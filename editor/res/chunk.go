@@ -0,0 +1,110 @@
+// Package res reads and writes the binary resource formats produced by aapt2: string pools,
+// the resource table (resources.arsc) and binary XML such as AndroidManifest.xml. Binary XML
+// can also be compiled from and decoded to text, and tables and XML encoded as the protobuf
+// messages used in Android App Bundles.
+//
+// See frameworks/base/libs/androidfw/include/androidfw/ResourceTypes.h
+package res
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// chunk types, ResChunk_header.type
+const (
+	chunkNull             = 0x0000
+	chunkStringPool       = 0x0001
+	chunkTable            = 0x0002
+	chunkXML              = 0x0003
+	chunkXMLStartNS       = 0x0100
+	chunkXMLEndNS         = 0x0101
+	chunkXMLStartElement  = 0x0102
+	chunkXMLEndElement    = 0x0103
+	chunkXMLCData         = 0x0104
+	chunkXMLResourceMap   = 0x0180
+	chunkTablePackage     = 0x0200
+	chunkTableType        = 0x0201
+	chunkTableTypeSpec    = 0x0202
+	chunkTableLibrary     = 0x0203
+	chunkTableOverlayable = 0x0204
+)
+
+const chunkHeaderLen = 8
+
+var errShort = errors.New("res: chunk is truncated")
+
+// chunkHeader is ResChunk_header
+type chunkHeader struct {
+	Type       uint16
+	HeaderSize uint16
+	Size       uint32
+}
+
+// readChunk returns the header of the chunk at the start of b, the whole chunk and the rest of b.
+func readChunk(b []byte) (chunkHeader, []byte, []byte, error) {
+	if len(b) < chunkHeaderLen {
+		return chunkHeader{}, nil, nil, errShort
+	}
+	h := chunkHeader{
+		Type:       binary.LittleEndian.Uint16(b),
+		HeaderSize: binary.LittleEndian.Uint16(b[2:]),
+		Size:       binary.LittleEndian.Uint32(b[4:]),
+	}
+	if h.Size < chunkHeaderLen || int(h.HeaderSize) > int(h.Size) || int64(h.Size) > int64(len(b)) {
+		return h, nil, nil, fmt.Errorf("res: bad chunk 0x%04x, size %d of %d bytes", h.Type, h.Size, len(b))
+	}
+	return h, b[:h.Size], b[h.Size:], nil
+}
+
+// chunkWriter builds a chunk, patching the size once all of it has been written.
+type chunkWriter struct {
+	buf []byte
+}
+
+func newChunk(typ uint16, headerSize int) *chunkWriter {
+	c := &chunkWriter{buf: make([]byte, headerSize)}
+	binary.LittleEndian.PutUint16(c.buf, typ)
+	binary.LittleEndian.PutUint16(c.buf[2:], uint16(headerSize))
+	return c
+}
+
+// header returns the part of the header after ResChunk_header, for the caller to fill in.
+func (c *chunkWriter) header() []byte {
+	return c.buf[chunkHeaderLen:binary.LittleEndian.Uint16(c.buf[2:])]
+}
+
+func (c *chunkWriter) write(b ...[]byte) {
+	for _, p := range b {
+		c.buf = append(c.buf, p...)
+	}
+}
+
+func (c *chunkWriter) u8(v uint8) {
+	c.buf = append(c.buf, v)
+}
+
+func (c *chunkWriter) u16(v uint16) {
+	c.buf = binary.LittleEndian.AppendUint16(c.buf, v)
+}
+
+func (c *chunkWriter) u32(v uint32) {
+	c.buf = binary.LittleEndian.AppendUint32(c.buf, v)
+}
+
+// align pads the chunk with zeros to a multiple of 4 bytes.
+func (c *chunkWriter) align() {
+	for len(c.buf)%4 != 0 {
+		c.buf = append(c.buf, 0)
+	}
+}
+
+func (c *chunkWriter) len() int {
+	return len(c.buf)
+}
+
+func (c *chunkWriter) bytes() []byte {
+	binary.LittleEndian.PutUint32(c.buf[4:], uint32(len(c.buf)))
+	return c.buf
+}
@@ -0,0 +1,308 @@
+package res
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ToolsNS is the namespace of the tools: attributes, which are dropped when compiling.
+const ToolsNS = "http://schemas.android.com/tools"
+
+// CompileXML compiles a text XML document such as an AndroidManifest.xml into the binary form.
+// android: attribute names are resolved with the embedded attribute table and their values are
+// encoded by the attribute's format, the way aapt2 does it. resolve looks up the id of a
+// "type/name" resource referenced as @type/name or ?type/name; it may be nil, in which case
+// only @0x7f010000 style references compile. Attributes of other namespaces and without a
+// namespace are kept as strings, tools: attributes are removed.
+func CompileXML(text []byte, resolve func(name string) (uint32, bool)) (*XML, error) {
+	d := xml.NewDecoder(bytes.NewReader(text))
+	x := &XML{}
+	var stack []*Element
+	for {
+		line, _ := d.InputPos()
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			e := &Element{Namespace: t.Name.Space, Name: t.Name.Local, Line: uint32(line)}
+			if len(stack) == 0 && x.Root != nil {
+				return nil, errors.New("res: xml has more than one root element")
+			}
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" {
+					if a.Value != ToolsNS {
+						e.Namespaces = append(e.Namespaces, Namespace{a.Name.Local, a.Value})
+					}
+					continue
+				}
+				if a.Name.Space == "" && a.Name.Local == "xmlns" || a.Name.Space == ToolsNS {
+					continue
+				}
+				attr, err := compileAttr(a, resolve)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: <%s %s>: %w", line, e.Name, a.Name.Local, err)
+				}
+				if e.Attr(attr.Namespace, attr.Name) != nil {
+					return nil, fmt.Errorf("line %d: <%s>: duplicate attribute %s", line, e.Name, a.Name.Local)
+				}
+				e.Attrs = append(e.Attrs, attr)
+			}
+			// 与aapt2相同, 带资源id的属性按id排序, 其他属性在后面
+			sort.SliceStable(e.Attrs, func(i, j int) bool {
+				a, b := e.Attrs[i], e.Attrs[j]
+				return a.ID != 0 && (b.ID == 0 || a.ID < b.ID)
+			})
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, e)
+			} else {
+				x.Root = e
+			}
+			stack = append(stack, e)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			text := strings.TrimSpace(string(t))
+			if text == "" || len(stack) == 0 {
+				continue
+			}
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, &Element{Text: text, Line: uint32(line)})
+		}
+	}
+	if x.Root == nil {
+		return nil, errors.New("res: xml has no root element")
+	}
+	return x, nil
+}
+
+func compileAttr(a xml.Attr, resolve func(string) (uint32, bool)) (*Attr, error) {
+	attr := &Attr{Namespace: a.Name.Space, Name: a.Name.Local}
+	if a.Name.Space != AndroidNS {
+		attr.Raw = unescapeString(a.Value)
+		attr.Value = Value{Type: TypeString}
+		return attr, nil
+	}
+	def, ok := AndroidAttrDef(a.Name.Local)
+	if !ok {
+		return nil, errors.New("unknown android attribute")
+	}
+	attr.ID = def.ID
+	v, err := def.Parse(a.Value, resolve)
+	if err != nil {
+		return nil, err
+	}
+	attr.Value = v
+	if v.Type == TypeString {
+		attr.Raw = unescapeString(a.Value)
+	}
+	return attr, nil
+}
+
+// unescapeString removes the backslash of \@ and \? at the start of a string value.
+func unescapeString(s string) string {
+	if strings.HasPrefix(s, `\@`) || strings.HasPrefix(s, `\?`) {
+		return s[1:]
+	}
+	return s
+}
+
+// Parse encodes s as a value of the attribute: a reference, an enum or flag symbol, or a typed
+// value accepted by its format, falling back to a string when the format allows it.
+func (d AttrDef) Parse(s string, resolve func(string) (uint32, bool)) (Value, error) {
+	if strings.HasPrefix(s, "@") || strings.HasPrefix(s, "?") {
+		return parseReference(s, resolve)
+	}
+	if unescapeString(s) != s {
+		if d.Format&FormatString == 0 {
+			return Value{}, fmt.Errorf("invalid value %q", s)
+		}
+		return Value{Type: TypeString}, nil
+	}
+	if d.Format&(FormatEnum|FormatFlags) != 0 {
+		if v, ok := d.parseSymbols(s); ok {
+			return v, nil
+		}
+	}
+	t := strings.TrimSpace(s)
+	if d.Format&FormatBoolean != 0 && (t == "true" || t == "false") {
+		return Bool(t == "true"), nil
+	}
+	if d.Format&FormatInteger != 0 {
+		if strings.HasPrefix(t, "0x") || strings.HasPrefix(t, "0X") {
+			if i, err := strconv.ParseUint(t[2:], 16, 32); err == nil {
+				return Value{TypeIntHex, uint32(i)}, nil
+			}
+		} else if i, err := strconv.ParseInt(t, 10, 32); err == nil {
+			return Int(int32(i)), nil
+		}
+	}
+	if d.Format&FormatColor != 0 && strings.HasPrefix(t, "#") {
+		if v, ok := ParseColor(t); ok {
+			return v, nil
+		}
+	}
+	if d.Format&(FormatDimension|FormatFraction) != 0 {
+		if v, ok := parseComplex(t, d.Format); ok {
+			return v, nil
+		}
+	}
+	if d.Format&FormatFloat != 0 {
+		if f, err := strconv.ParseFloat(t, 32); err == nil {
+			return Value{TypeFloat, math.Float32bits(float32(f))}, nil
+		}
+	}
+	if d.Format&FormatString != 0 {
+		return Value{Type: TypeString}, nil
+	}
+	return Value{}, fmt.Errorf("invalid value %q", s)
+}
+
+func (d AttrDef) parseSymbols(s string) (Value, bool) {
+	if d.Format&FormatEnum != 0 {
+		v, ok := d.Symbols[strings.TrimSpace(s)]
+		return Value{TypeIntDec, v}, ok
+	}
+	var flags uint32
+	for _, f := range strings.Split(s, "|") {
+		v, ok := d.Symbols[strings.TrimSpace(f)]
+		if !ok {
+			return Value{}, false
+		}
+		flags |= v
+	}
+	return Value{TypeIntHex, flags}, true
+}
+
+// parseReference parses @null, @empty, @0x7f010000, @type/name and ?type/name. Framework
+// references (@android:type/name) can only be written as ids.
+func parseReference(s string, resolve func(string) (uint32, bool)) (Value, error) {
+	typ := TypeReference
+	if s[0] == '?' {
+		typ = TypeAttribute
+	}
+	name := strings.TrimPrefix(s[1:], "+")
+	switch {
+	case s == "@null":
+		return Value{TypeReference, 0}, nil
+	case s == "@empty":
+		return Value{TypeNull, 1}, nil
+	case strings.HasPrefix(name, "0x"):
+		id, err := strconv.ParseUint(name[2:], 16, 32)
+		if err != nil {
+			return Value{}, fmt.Errorf("invalid reference %q", s)
+		}
+		return Value{typ, uint32(id)}, nil
+	case strings.HasPrefix(name, "android:"):
+		return Value{}, fmt.Errorf("framework reference %q: write it as @0x01xxxxxx", s)
+	}
+	if resolve != nil {
+		if id, ok := resolve(name); ok {
+			return Value{typ, id}, nil
+		}
+	}
+	return Value{}, fmt.Errorf("resource %q not found", s)
+}
+
+// ParseColor parses #rgb, #argb, #rrggbb and #aarrggbb.
+func ParseColor(s string) (Value, bool) {
+	if !strings.HasPrefix(s, "#") {
+		return Value{}, false
+	}
+	hex := s[1:]
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return Value{}, false
+	}
+	c := uint32(n)
+	expand := func(c uint32) uint32 {
+		var argb uint32
+		for i := 3; i >= 0; i-- {
+			nibble := (c >> (4 * i)) & 0xF
+			argb = argb<<8 | nibble<<4 | nibble
+		}
+		return argb
+	}
+	switch len(hex) {
+	case 3:
+		return Value{TypeIntColorRGB4, expand(c | 0xF000)}, true
+	case 4:
+		return Value{TypeIntColorARGB4, expand(c)}, true
+	case 6:
+		return Value{TypeIntColorRGB8, 0xFF000000 | c}, true
+	case 8:
+		return Value{TypeIntColorARGB8, c}, true
+	}
+	return Value{}, false
+}
+
+// parseComplex parses a dimension such as 16dp or a fraction such as 50%p.
+func parseComplex(s string, format AttrFormat) (Value, bool) {
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' && r != '-' && r != '+' })
+	if i <= 0 {
+		return Value{}, false
+	}
+	f, err := strconv.ParseFloat(s[:i], 32)
+	if err != nil {
+		return Value{}, false
+	}
+	unit := s[i:]
+	if unit == "dp" {
+		unit = "dip"
+	}
+	if format&FormatDimension != 0 {
+		for u, name := range dimensionUnits {
+			if unit == name {
+				return Value{TypeDimension, floatToComplex(f) | uint32(u)}, true
+			}
+		}
+	}
+	if format&FormatFraction != 0 {
+		for u, name := range fractionUnits {
+			if unit == name {
+				return Value{TypeFraction, floatToComplex(f/100) | uint32(u)}, true
+			}
+		}
+	}
+	return Value{}, false
+}
+
+// floatToComplex encodes f as the mantissa and radix of a complex value, leaving the unit bits
+// zero. It picks the radix with the most precision, like aapt's ResourceUtils.
+func floatToComplex(f float64) uint32 {
+	neg := f < 0
+	if neg {
+		f = -f
+	}
+	bits := uint64(f*(1<<23) + 0.5)
+	var radix, shift uint32
+	switch {
+	case bits&0x7FFFFF == 0:
+		radix, shift = 0, 23
+	case bits&^uint64(0x7FFFFF) == 0:
+		radix, shift = 3, 0
+	case bits&^uint64(0x7FFFFFFF) == 0:
+		radix, shift = 2, 8
+	case bits&^uint64(0x7FFFFFFFFF) == 0:
+		radix, shift = 1, 16
+	default:
+		radix, shift = 0, 23
+	}
+	mantissa := uint32(bits>>shift) & 0xFFFFFF
+	if neg {
+		mantissa = -mantissa & 0xFFFFFF
+	}
+	return radix<<4 | mantissa<<8
+}
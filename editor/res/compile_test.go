@@ -0,0 +1,124 @@
+package res
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompileXML(t *testing.T) {
+	text := `<?xml version="1.0" encoding="utf-8"?>
+<manifest xmlns:android="http://schemas.android.com/apk/res/android"
+    xmlns:tools="http://schemas.android.com/tools"
+    package="com.example.app" android:versionName="1.0" android:versionCode="7">
+    <uses-feature android:glEsVersion="0x00020000" android:required="false" />
+    <application android:label="@string/app_name" android:theme="@0x7f0f01d2"
+        android:allowBackup="false" tools:replace="android:label">
+        <activity android:name=".Main" android:screenOrientation="portrait"
+            android:configChanges="orientation|screenSize" android:windowSoftInputMode="adjustResize|stateHidden">
+            <meta-data android:name="level" android:value="3" />
+            <meta-data android:name="key" android:value="abc" />
+            <meta-data android:name="color" android:value="#f00" />
+        </activity>
+    </application>
+</manifest>`
+	resolve := func(name string) (uint32, bool) {
+		return 0x7f100001, name == "string/app_name"
+	}
+	x, err := CompileXML([]byte(text), resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err = ParseXML(x.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := x.Root
+	if len(root.Namespaces) != 1 || root.Attr("", "package").Raw != "com.example.app" {
+		t.Errorf("manifest %+v %+v", root.Namespaces, root.Attrs)
+	}
+	// 属性按资源id排序, package 在最后
+	if root.Attrs[0].ID != AttrVersionCode || root.Attrs[0].Value != Int(7) || root.Attrs[2].Name != "package" {
+		t.Errorf("manifest attrs %+v", root.Attrs)
+	}
+	if a := root.Child("uses-feature").AndroidAttr(AttrGlEsVersion); a.Value != (Value{TypeIntHex, 0x20000}) {
+		t.Errorf("glEsVersion %+v", a.Value)
+	}
+	app := root.Child("application")
+	if len(app.Attrs) != 3 || app.AndroidAttr(AttrLabel).Value != Reference(0x7f100001) || app.AndroidAttr(AttrAllowBackup).Value != Bool(false) {
+		t.Errorf("application %+v", app.Attrs)
+	}
+	activity := app.Child("activity")
+	for _, c := range []struct {
+		id   uint32
+		want Value
+	}{
+		{AttrScreenOrientation, Value{TypeIntDec, 1}},
+		{0x0101001f, Value{TypeIntHex, 0x480}},
+		{AttrWindowSoftInputMode, Value{TypeIntHex, 0x12}},
+	} {
+		if a := activity.AndroidAttr(c.id); a == nil || a.Value != c.want {
+			t.Errorf("attr %#x: %+v, want %+v", c.id, a, c.want)
+		}
+	}
+	meta := activity.ChildrenNamed("meta-data")
+	if meta[0].AndroidAttr(AttrValue).Value != Int(3) || meta[1].AndroidAttr(AttrValue).Raw != "abc" ||
+		meta[2].AndroidAttr(AttrValue).Value != (Value{TypeIntColorRGB4, 0xFFFF0000}) {
+		t.Errorf("meta-data %+v %+v %+v", meta[0].Attrs[1], meta[1].Attrs[1], meta[2].Attrs[1])
+	}
+
+	for _, bad := range []string{
+		`<manifest xmlns:android="http://schemas.android.com/apk/res/android" android:nope="1"/>`,
+		`<manifest xmlns:android="http://schemas.android.com/apk/res/android" android:versionCode="abc"/>`,
+		`<manifest xmlns:android="http://schemas.android.com/apk/res/android"><application android:icon="@mipmap/missing"/></manifest>`,
+	} {
+		if _, err := CompileXML([]byte(bad), resolve); err == nil {
+			t.Errorf("%s compiled", bad)
+		}
+	}
+}
+
+func TestFloatToComplex(t *testing.T) {
+	for _, f := range []float64{16, 0.5, -2.25, 1.0 / 3} {
+		if got := complexToFloat(floatToComplex(f)); got-f > 1e-6 || f-got > 1e-6 {
+			t.Errorf("%v -> %v", f, got)
+		}
+	}
+}
+
+func TestXMLText(t *testing.T) {
+	raw := readTemplateEntry(t, "AndroidManifest.xml")
+	x, err := ParseXML(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := ParseTable(readTemplateEntry(t, "resources.arsc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := x.Root.Child("application")
+	app.SetAttr(StringAttr(AndroidNS, "description", 0x01010020, "@not a reference"))
+	app.Child("activity").SetAttr(ValueAttr(AndroidNS, "windowSoftInputMode", AttrWindowSoftInputMode, Value{TypeIntHex, 0x13}))
+	text := string(x.Text(table))
+	for _, want := range []string{
+		`<manifest xmlns:android="http://schemas.android.com/apk/res/android"`,
+		`    android:icon="@mipmap/ic_launcher"`,
+		`    android:description="\@not a reference"`,
+		`android:windowSoftInputMode="stateAlwaysHidden|adjustResize"`,
+		`<action android:name="android.intent.action.MAIN" />`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing %s in\n%s", want, text)
+		}
+	}
+	// 文本重新编译后与原文档相同
+	y, err := CompileXML([]byte(text), table.Find)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(y.Text(table)); got != text {
+		t.Errorf("recompiled text differs:\n%s\nwant\n%s", got, text)
+	}
+	if y.Root.Child("application").AndroidAttr(0x01010020).Raw != "@not a reference" {
+		t.Error("escaped string not restored")
+	}
+}
@@ -0,0 +1,236 @@
+package res
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Density values of Config.Density
+const (
+	DensityDefault = 0
+	DensityLow     = 120
+	DensityMedium  = 160
+	DensityHigh    = 240
+	DensityXHigh   = 320
+	DensityXXHigh  = 480
+	DensityXXXHigh = 640
+	DensityAny     = 0xFFFE
+	DensityNone    = 0xFFFF
+)
+
+// UI modes of Config.NightMode
+const (
+	NightAny = 0x00
+	NightNo  = 0x10
+	NightYes = 0x20
+)
+
+const configLen = 64
+
+// Configuration change flags of TypeSpec.Flags (ResTable_config::CONFIG_*)
+const (
+	ConfigLocale  = 0x0004
+	ConfigDensity = 0x0100
+	ConfigVersion = 0x0400
+	ConfigUIMode  = 0x1000
+)
+
+// Config is ResTable_config, kept as its raw bytes so fields this package does not know about
+// survive a round trip.
+type Config struct {
+	raw []byte
+}
+
+func parseConfig(b []byte) (Config, error) {
+	if len(b) < 4 {
+		return Config{}, errShort
+	}
+	size := binary.LittleEndian.Uint32(b)
+	if size < 28 || int(size) > len(b) {
+		return Config{}, fmt.Errorf("res: bad config size %d", size)
+	}
+	raw := make([]byte, size)
+	copy(raw, b)
+	return Config{raw}, nil
+}
+
+// bytes returns the config padded to at least configLen bytes.
+func (c Config) bytes() []byte {
+	if len(c.raw) >= configLen {
+		return c.raw
+	}
+	raw := make([]byte, configLen)
+	copy(raw, c.raw)
+	binary.LittleEndian.PutUint32(raw, configLen)
+	return raw
+}
+
+func (c Config) field(off, n int) []byte {
+	if off+n > len(c.raw) {
+		return make([]byte, n)
+	}
+	return c.raw[off : off+n]
+}
+
+func (c *Config) setField(off int, v []byte) {
+	if off+len(v) > len(c.raw) {
+		*c = Config{c.bytes()}
+	}
+	copy(c.raw[off:], v)
+}
+
+// Equal reports whether c and o describe the same configuration.
+func (c Config) Equal(o Config) bool {
+	a, b := c.bytes()[4:], o.bytes()[4:]
+	n := max(len(a), len(b))
+	a = append(a[:len(a):len(a)], make([]byte, n-len(a))...)
+	b = append(b[:len(b):len(b)], make([]byte, n-len(b))...)
+	return bytes.Equal(a, b)
+}
+
+// changeFlags returns the ConfigXXX flags of the qualifiers c sets, as far as this package knows
+// them.
+func (c Config) changeFlags() uint32 {
+	var f uint32
+	if c.Language() != "" {
+		f |= ConfigLocale
+	}
+	if c.Density() != DensityDefault {
+		f |= ConfigDensity
+	}
+	if c.SDKVersion() != 0 {
+		f |= ConfigVersion
+	}
+	if c.NightMode() != NightAny {
+		f |= ConfigUIMode
+	}
+	return f
+}
+
+// IsDefault reports whether c is the default (unqualified) configuration.
+func (c Config) IsDefault() bool {
+	return c.Equal(Config{})
+}
+
+func unpackLocale(b []byte, base byte) string {
+	if b[0] == 0 {
+		return ""
+	}
+	if b[0]&0x80 == 0 {
+		return string(b)
+	}
+	// packed 3 letter code: 1 ttttt sssss fffff, first-second-third
+	first := b[1] & 0x1F
+	second := (b[1]&0xE0)>>5 | (b[0]&0x03)<<3
+	third := (b[0] & 0x7C) >> 2
+	return string([]byte{first + base, second + base, third + base})
+}
+
+func packLocale(s string, base byte) []byte {
+	switch len(s) {
+	case 0:
+		return []byte{0, 0}
+	case 2:
+		return []byte{s[0], s[1]}
+	}
+	first, second, third := s[0]-base, s[1]-base, s[2]-base
+	return []byte{0x80 | third<<2 | second>>3, second<<5 | first}
+}
+
+// Language returns the language code, e.g. "zh".
+func (c Config) Language() string {
+	return unpackLocale(c.field(8, 2), 'a')
+}
+
+// Region returns the region code, e.g. "CN".
+func (c Config) Region() string {
+	return unpackLocale(c.field(10, 2), '0')
+}
+
+// SetLocale sets the language and optional region.
+func (c *Config) SetLocale(language, region string) {
+	c.setField(8, packLocale(strings.ToLower(language), 'a'))
+	c.setField(10, packLocale(strings.ToUpper(region), '0'))
+}
+
+// Density returns the screen density qualifier.
+func (c Config) Density() uint16 {
+	return binary.LittleEndian.Uint16(c.field(14, 2))
+}
+
+// SetDensity sets the screen density qualifier.
+func (c *Config) SetDensity(density uint16) {
+	c.setField(14, binary.LittleEndian.AppendUint16(nil, density))
+}
+
+// SDKVersion returns the -vNN qualifier.
+func (c Config) SDKVersion() uint16 {
+	return binary.LittleEndian.Uint16(c.field(24, 2))
+}
+
+// NightMode returns the night qualifier, NightAny, NightNo or NightYes.
+func (c Config) NightMode() uint8 {
+	return c.field(29, 1)[0] & 0x30
+}
+
+// SetNightMode sets the night qualifier.
+func (c *Config) SetNightMode(mode uint8) {
+	ui := c.field(29, 1)[0]&^0x30 | mode
+	c.setField(29, []byte{ui})
+}
+
+// Locale returns the BCP-47 style locale of c, e.g. "zh-CN", or "" for none.
+func (c Config) Locale() string {
+	l := c.Language()
+	if r := c.Region(); r != "" {
+		l += "-" + r
+	}
+	return l
+}
+
+// String returns the qualifier string aapt would use for the directory name, e.g. "zh-rCN-night".
+func (c Config) String() string {
+	var q []string
+	if l := c.Language(); l != "" {
+		q = append(q, l)
+		if r := c.Region(); r != "" {
+			q = append(q, "r"+r)
+		}
+	}
+	switch c.NightMode() {
+	case NightNo:
+		q = append(q, "notnight")
+	case NightYes:
+		q = append(q, "night")
+	}
+	switch d := c.Density(); d {
+	case DensityDefault:
+	case DensityLow:
+		q = append(q, "ldpi")
+	case DensityMedium:
+		q = append(q, "mdpi")
+	case DensityHigh:
+		q = append(q, "hdpi")
+	case DensityXHigh:
+		q = append(q, "xhdpi")
+	case DensityXXHigh:
+		q = append(q, "xxhdpi")
+	case DensityXXXHigh:
+		q = append(q, "xxxhdpi")
+	case DensityAny:
+		q = append(q, "anydpi")
+	case DensityNone:
+		q = append(q, "nodpi")
+	default:
+		q = append(q, fmt.Sprintf("%ddpi", d))
+	}
+	if v := c.SDKVersion(); v != 0 {
+		q = append(q, fmt.Sprintf("v%d", v))
+	}
+	if len(q) == 0 {
+		return "default"
+	}
+	return strings.Join(q, "-")
+}
@@ -0,0 +1,70 @@
+package res
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed32 = 5
+	wireBytes   = 2
+)
+
+// pb is a minimal protocol buffers encoder, enough for the aapt2 messages this package writes.
+// Scalar fields skip zero values like proto3 does; the "always" variants are for oneof members,
+// whose presence matters even when they are zero.
+type pb []byte
+
+func (b *pb) tag(field, wire int) {
+	*b = binary.AppendUvarint(*b, uint64(field)<<3|uint64(wire))
+}
+
+// varintAlways writes v even when it is zero.
+func (b *pb) varintAlways(field int, v uint64) {
+	b.tag(field, wireVarint)
+	*b = binary.AppendUvarint(*b, v)
+}
+
+func (b *pb) uint32(field int, v uint32) {
+	if v != 0 {
+		b.varintAlways(field, uint64(v))
+	}
+}
+
+// int32 sign-extends negative values to 64 bits, as protobuf int32 fields require.
+func (b *pb) int32(field int, v int32) {
+	if v != 0 {
+		b.varintAlways(field, uint64(int64(v)))
+	}
+}
+
+func (b *pb) bool(field int, v bool) {
+	if v {
+		b.varintAlways(field, 1)
+	}
+}
+
+func (b *pb) float32Always(field int, v float32) {
+	b.tag(field, wireFixed32)
+	*b = binary.LittleEndian.AppendUint32(*b, math.Float32bits(v))
+}
+
+func (b *pb) string(field int, s string) {
+	if s != "" {
+		b.bytesAlways(field, []byte(s))
+	}
+}
+
+func (b *pb) bytesAlways(field int, v []byte) {
+	b.tag(field, wireBytes)
+	*b = binary.AppendUvarint(*b, uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+// message writes an embedded message, even an empty one: a present empty message such as
+// Item.id is different from an absent one.
+func (b *pb) message(field int, m pb) {
+	b.bytesAlways(field, m)
+}
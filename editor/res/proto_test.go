@@ -0,0 +1,154 @@
+package res
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// pbField 是解码出的一个protobuf字段, varint 在 Int 中, 长度分隔的字段在 Bytes 中
+type pbField struct {
+	Num   int
+	Int   uint64
+	Bytes []byte
+}
+
+func decodePB(t *testing.T, b []byte) []pbField {
+	t.Helper()
+	var fields []pbField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatal("bad protobuf key")
+		}
+		b = b[n:]
+		f := pbField{Num: int(key >> 3)}
+		switch key & 7 {
+		case wireVarint:
+			f.Int, n = binary.Uvarint(b)
+			b = b[n:]
+		case wireFixed32:
+			f.Int, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			f.Bytes, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// pbGet 返回编号为 num 的所有字段
+func pbGet(fields []pbField, num int) []pbField {
+	var ret []pbField
+	for _, f := range fields {
+		if f.Num == num {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+func TestXMLMarshalProto(t *testing.T) {
+	x, err := ParseXML(readTemplateEntry(t, "AndroidManifest.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := decodePB(t, x.MarshalProto())
+	el := decodePB(t, pbGet(node, 1)[0].Bytes)
+	if name := string(pbGet(el, 3)[0].Bytes); name != "manifest" {
+		t.Fatalf("root %q", name)
+	}
+	ns := decodePB(t, pbGet(el, 1)[0].Bytes)
+	if string(pbGet(ns, 1)[0].Bytes) != "android" || string(pbGet(ns, 2)[0].Bytes) != AndroidNS {
+		t.Errorf("namespace %v", ns)
+	}
+	attrs := map[string][]pbField{}
+	for _, f := range pbGet(el, 4) {
+		a := decodePB(t, f.Bytes)
+		attrs[string(pbGet(a, 2)[0].Bytes)] = a
+	}
+	// 字符串属性只有文本, 没有 compiled_item
+	if pkg := attrs["package"]; string(pbGet(pkg, 3)[0].Bytes) != "com.parap.webview" || len(pbGet(pkg, 6)) != 0 {
+		t.Errorf("package %v", pkg)
+	}
+	// versionCode 为 Item.prim.int_decimal_value
+	code := attrs["versionCode"]
+	if id := pbGet(code, 5); len(id) != 1 || id[0].Int != 0x0101021b {
+		t.Errorf("versionCode resource id %v", id)
+	}
+	prim := decodePB(t, pbGet(decodePB(t, pbGet(code, 6)[0].Bytes), 7)[0].Bytes)
+	if v := pbGet(prim, 6); len(v) != 1 || v[0].Int != 111 {
+		t.Errorf("versionCode %v", prim)
+	}
+	if len(pbGet(el, 5)) == 0 {
+		t.Error("no children")
+	}
+}
+
+func TestTableMarshalProto(t *testing.T) {
+	table, err := ParseTable(readTemplateEntry(t, "resources.arsc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs := pbGet(decodePB(t, table.MarshalProto()), 2)
+	if len(pkgs) != 1 {
+		t.Fatalf("%d packages", len(pkgs))
+	}
+	p := decodePB(t, pkgs[0].Bytes)
+	if id := decodePB(t, pbGet(p, 1)[0].Bytes); id[0].Int != 0x7f || string(pbGet(p, 2)[0].Bytes) != "com.parap.webview" {
+		t.Fatalf("package %v", p[:2])
+	}
+	xmlFiles := map[string]bool{}
+	for _, f := range table.XMLFiles() {
+		xmlFiles[f] = true
+	}
+	if len(xmlFiles) == 0 {
+		t.Fatal("no xml files")
+	}
+	// 所有文件引用: xml 为 PROTO_XML, 并且都在 XMLFiles 中
+	files := 0
+	for _, typ := range pbGet(p, 3) {
+		for _, entry := range pbGet(decodePB(t, typ.Bytes), 3) {
+			for _, cv := range pbGet(decodePB(t, entry.Bytes), 6) {
+				value := decodePB(t, pbGet(decodePB(t, cv.Bytes), 2)[0].Bytes)
+				for _, item := range pbGet(value, 4) {
+					for _, file := range pbGet(decodePB(t, item.Bytes), 5) {
+						ref := decodePB(t, file.Bytes)
+						path := string(pbGet(ref, 1)[0].Bytes)
+						isXML := len(pbGet(ref, 2)) == 1 && pbGet(ref, 2)[0].Int == fileProtoXML
+						if isXML != xmlFiles[path] {
+							t.Errorf("%s: proto xml %v", path, isXML)
+						}
+						files++
+					}
+				}
+			}
+		}
+	}
+	if files < len(xmlFiles) {
+		t.Errorf("%d file references, %d xml files", files, len(xmlFiles))
+	}
+}
+
+func TestConfigProto(t *testing.T) {
+	var c Config
+	c.SetLocale("zh", "CN")
+	c.SetNightMode(NightYes)
+	c.SetDensity(DensityXHigh)
+	fields := decodePB(t, c.proto())
+	if l := pbGet(fields, 3); len(l) != 1 || string(l[0].Bytes) != "zh-CN" {
+		t.Errorf("locale %v", l)
+	}
+	// UiModeNight.NIGHT = 1
+	if n := pbGet(fields, 17); len(n) != 1 || n[0].Int != 1 {
+		t.Errorf("night %v", n)
+	}
+	if d := pbGet(fields, 18); len(d) != 1 || d[0].Int != DensityXHigh {
+		t.Errorf("density %v", d)
+	}
+	if len(decodePB(t, Config{}.proto())) != 0 {
+		t.Error("default config is not empty")
+	}
+}
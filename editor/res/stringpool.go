@@ -0,0 +1,254 @@
+package res
+
+import (
+	"encoding/binary"
+	"errors"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	stringPoolSorted = 1 << 0
+	stringPoolUTF8   = 1 << 8
+	stringPoolHeader = 28
+	spanEnd          = 0xFFFFFFFF
+)
+
+// Span is ResStringPool_span: a style tag (by string index) applied to characters First..Last.
+type Span struct {
+	Name  uint32
+	First uint32
+	Last  uint32
+}
+
+// StringPool is ResStringPool. The first len(Styles) strings carry the style spans at the same index.
+type StringPool struct {
+	Strings []string
+	Styles  [][]Span
+	UTF8    bool
+}
+
+// ParseStringPool parses a RES_STRING_POOL_TYPE chunk.
+func ParseStringPool(b []byte) (*StringPool, error) {
+	h, b, _, err := readChunk(b)
+	if err != nil {
+		return nil, err
+	}
+	if h.Type != chunkStringPool || h.HeaderSize < stringPoolHeader {
+		return nil, errors.New("res: not a string pool")
+	}
+	le := binary.LittleEndian
+	stringCount := le.Uint32(b[8:])
+	styleCount := le.Uint32(b[12:])
+	flags := le.Uint32(b[16:])
+	stringsStart := le.Uint32(b[20:])
+	stylesStart := le.Uint32(b[24:])
+	offsets := b[h.HeaderSize:]
+	if uint64(len(offsets)) < 4*(uint64(stringCount)+uint64(styleCount)) {
+		return nil, errShort
+	}
+	p := &StringPool{
+		Strings: make([]string, stringCount),
+		UTF8:    flags&stringPoolUTF8 != 0,
+	}
+	if stringCount > 0 {
+		if int(stringsStart) > len(b) {
+			return nil, errShort
+		}
+		data := b[stringsStart:]
+		if styleCount > 0 && stylesStart > stringsStart && int(stylesStart) <= len(b) {
+			data = b[stringsStart:stylesStart]
+		}
+		for i := range p.Strings {
+			off := le.Uint32(offsets[4*i:])
+			if int(off) >= len(data) {
+				return nil, errShort
+			}
+			if p.UTF8 {
+				p.Strings[i], err = decodeUTF8(data[off:])
+			} else {
+				p.Strings[i], err = decodeUTF16(data[off:])
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if styleCount > 0 {
+		if int(stylesStart) > len(b) {
+			return nil, errShort
+		}
+		data := b[stylesStart:]
+		p.Styles = make([][]Span, styleCount)
+		for i := range p.Styles {
+			off := le.Uint32(offsets[4*(int(stringCount)+i):])
+			for ; int(off)+4 <= len(data); off += 12 {
+				name := le.Uint32(data[off:])
+				if name == spanEnd {
+					break
+				}
+				if int(off)+12 > len(data) {
+					return nil, errShort
+				}
+				p.Styles[i] = append(p.Styles[i], Span{name, le.Uint32(data[off+4:]), le.Uint32(data[off+8:])})
+			}
+		}
+	}
+	return p, nil
+}
+
+func decodeLength8(b []byte) (int, []byte, error) {
+	if len(b) < 1 {
+		return 0, nil, errShort
+	}
+	if b[0]&0x80 == 0 {
+		return int(b[0]), b[1:], nil
+	}
+	if len(b) < 2 {
+		return 0, nil, errShort
+	}
+	return int(b[0]&0x7F)<<8 | int(b[1]), b[2:], nil
+}
+
+func decodeUTF8(b []byte) (string, error) {
+	_, b, err := decodeLength8(b) // length in UTF-16 units
+	if err != nil {
+		return "", err
+	}
+	n, b, err := decodeLength8(b)
+	if err != nil {
+		return "", err
+	}
+	if n > len(b) {
+		return "", errShort
+	}
+	return string(b[:n]), nil
+}
+
+func decodeUTF16(b []byte) (string, error) {
+	if len(b) < 2 {
+		return "", errShort
+	}
+	n := int(binary.LittleEndian.Uint16(b))
+	b = b[2:]
+	if n&0x8000 != 0 {
+		if len(b) < 2 {
+			return "", errShort
+		}
+		n = (n&0x7FFF)<<16 | int(binary.LittleEndian.Uint16(b))
+		b = b[2:]
+	}
+	if 2*n > len(b) {
+		return "", errShort
+	}
+	u := make([]uint16, n)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u)), nil
+}
+
+// Index returns the index of s in the pool, or -1.
+func (p *StringPool) Index(s string) int {
+	for i, str := range p.Strings {
+		if str == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// Add returns the index of an unstyled s, appending it to the pool if needed. Styled strings are
+// never reused so that the new reference does not pick up their spans.
+func (p *StringPool) Add(s string) uint32 {
+	for i := len(p.Styles); i < len(p.Strings); i++ {
+		if p.Strings[i] == s {
+			return uint32(i)
+		}
+	}
+	p.Strings = append(p.Strings, s)
+	return uint32(len(p.Strings) - 1)
+}
+
+// Marshal encodes the pool as a RES_STRING_POOL_TYPE chunk.
+func (p *StringPool) Marshal() []byte {
+	var data []byte
+	offsets := make([]uint32, 0, len(p.Strings)+len(p.Styles))
+	for _, s := range p.Strings {
+		offsets = append(offsets, uint32(len(data)))
+		if p.UTF8 {
+			data = appendUTF8(data, s)
+		} else {
+			data = appendUTF16(data, s)
+		}
+	}
+	for len(data)%4 != 0 {
+		data = append(data, 0)
+	}
+	var styles []byte
+	for _, spans := range p.Styles {
+		offsets = append(offsets, uint32(len(styles)))
+		for _, s := range spans {
+			styles = binary.LittleEndian.AppendUint32(styles, s.Name)
+			styles = binary.LittleEndian.AppendUint32(styles, s.First)
+			styles = binary.LittleEndian.AppendUint32(styles, s.Last)
+		}
+		styles = binary.LittleEndian.AppendUint32(styles, spanEnd)
+	}
+	if len(p.Styles) > 0 {
+		styles = binary.LittleEndian.AppendUint32(styles, spanEnd)
+		styles = binary.LittleEndian.AppendUint32(styles, spanEnd)
+	}
+
+	c := newChunk(chunkStringPool, stringPoolHeader)
+	h := c.header()
+	var flags uint32
+	if p.UTF8 {
+		flags |= stringPoolUTF8
+	}
+	stringsStart := stringPoolHeader + 4*len(offsets)
+	le := binary.LittleEndian
+	le.PutUint32(h[0:], uint32(len(p.Strings)))
+	le.PutUint32(h[4:], uint32(len(p.Styles)))
+	le.PutUint32(h[8:], flags)
+	if len(p.Strings) > 0 {
+		le.PutUint32(h[12:], uint32(stringsStart))
+	}
+	if len(p.Styles) > 0 {
+		le.PutUint32(h[16:], uint32(stringsStart+len(data)))
+	}
+	for _, off := range offsets {
+		c.u32(off)
+	}
+	c.write(data, styles)
+	return c.bytes()
+}
+
+func appendLength8(b []byte, n int) []byte {
+	if n > 0x7F {
+		return append(b, byte(n>>8)|0x80, byte(n))
+	}
+	return append(b, byte(n))
+}
+
+func appendUTF8(b []byte, s string) []byte {
+	if !utf8.ValidString(s) {
+		s = string([]rune(s))
+	}
+	b = appendLength8(b, len(utf16.Encode([]rune(s))))
+	b = appendLength8(b, len(s))
+	b = append(b, s...)
+	return append(b, 0)
+}
+
+func appendUTF16(b []byte, s string) []byte {
+	u := utf16.Encode([]rune(s))
+	if len(u) > 0x7FFF {
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(u)>>16)|0x8000)
+	}
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(u)))
+	for _, c := range u {
+		b = binary.LittleEndian.AppendUint16(b, c)
+	}
+	return binary.LittleEndian.AppendUint16(b, 0)
+}
@@ -0,0 +1,31 @@
+package res
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStringPoolRoundTrip(t *testing.T) {
+	strs := []string{"", "label", "中文标签", strings.Repeat("x", 300), "emoji 🙂"}
+	for _, utf8 := range []bool{true, false} {
+		p := &StringPool{Strings: strs, UTF8: utf8, Styles: [][]Span{nil, {{Name: 0, First: 0, Last: 2}}}}
+		got, err := ParseStringPool(p.Marshal())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, p) {
+			t.Errorf("utf8=%v: got %+v, want %+v", utf8, got, p)
+		}
+	}
+}
+
+func TestStringPoolAdd(t *testing.T) {
+	p := &StringPool{Strings: []string{"styled", "plain"}, Styles: [][]Span{{{Name: 1}}}}
+	if i := p.Add("plain"); i != 1 {
+		t.Errorf("Add(plain) = %d, want 1", i)
+	}
+	if i := p.Add("styled"); i != 2 {
+		t.Errorf("Add(styled) = %d, want a new unstyled string at 2", i)
+	}
+}
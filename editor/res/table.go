@@ -0,0 +1,694 @@
+package res
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// ResTable_entry flags
+const (
+	FlagComplex = 0x0001
+	FlagPublic  = 0x0002
+	FlagWeak    = 0x0004
+	flagCompact = 0x0008
+)
+
+// ResTable_type flags
+const (
+	typeFlagSparse   = 0x01
+	typeFlagOffset16 = 0x02
+)
+
+const (
+	noEntry           = 0xFFFFFFFF
+	tableHeaderLen    = 12
+	packageHeaderLen  = 288
+	typeSpecHeaderLen = 16
+	typeHeaderLen     = 20 // without the config
+)
+
+// Table is a parsed resources.arsc.
+type Table struct {
+	Strings  *StringPool
+	Packages []*Package
+}
+
+// Package is ResTable_package with its type and key string pools and types.
+type Package struct {
+	ID          uint32
+	Name        string
+	TypeStrings *StringPool
+	KeyStrings  *StringPool
+	Specs       []*TypeSpec
+
+	lastPublicType uint32
+	lastPublicKey  uint32
+	typeIDOffset   uint32
+	chunks         []any // *TypeSpec or raw []byte chunks such as the library chunk, in file order
+}
+
+// TypeSpec is ResTable_typeSpec followed by all the ResTable_type chunks of that type.
+type TypeSpec struct {
+	ID    uint8
+	Flags []uint32 // configuration change flags of each entry
+	Types []*Type
+
+	countTypes bool // whether typesCount is filled in; older aapt2 leaves it zero
+}
+
+// Type holds the entries of one type for one configuration. Entries is indexed by entry id and
+// has nil for entries without a value in this configuration.
+type Type struct {
+	ID      uint8
+	Config  Config
+	Entries []*Entry
+}
+
+// Entry is a ResTable_entry: either a simple Value, or, when Flags has FlagComplex, a bag
+// (style, array, plurals...) with a Parent and Map.
+type Entry struct {
+	Key    uint32 // index into Package.KeyStrings
+	Flags  uint16
+	Value  Value
+	Parent uint32
+	Map    []MapEntry
+}
+
+// MapEntry is ResTable_map: the value of attribute (or bag key) Name.
+type MapEntry struct {
+	Name  uint32
+	Value Value
+}
+
+// ConfigEntry is the value of a resource in one configuration.
+type ConfigEntry struct {
+	Config Config
+	Entry  *Entry
+}
+
+// IsComplex reports whether e is a bag.
+func (e *Entry) IsComplex() bool {
+	return e.Flags&FlagComplex != 0
+}
+
+// ParseTable parses resources.arsc.
+func ParseTable(b []byte) (*Table, error) {
+	h, b, _, err := readChunk(b)
+	if err != nil {
+		return nil, err
+	}
+	if h.Type != chunkTable || h.HeaderSize < tableHeaderLen {
+		return nil, errors.New("res: not a resource table")
+	}
+	t := &Table{}
+	rest := b[h.HeaderSize:]
+	for len(rest) > 0 {
+		var ch chunkHeader
+		var c []byte
+		ch, c, rest, err = readChunk(rest)
+		if err != nil {
+			return nil, err
+		}
+		switch ch.Type {
+		case chunkStringPool:
+			if t.Strings, err = ParseStringPool(c); err != nil {
+				return nil, err
+			}
+		case chunkTablePackage:
+			p, err := parsePackage(ch, c)
+			if err != nil {
+				return nil, err
+			}
+			t.Packages = append(t.Packages, p)
+		default:
+			return nil, fmt.Errorf("res: unexpected chunk 0x%04x in table", ch.Type)
+		}
+	}
+	if t.Strings == nil {
+		return nil, errors.New("res: table has no string pool")
+	}
+	return t, nil
+}
+
+func parsePackage(h chunkHeader, b []byte) (*Package, error) {
+	if h.HeaderSize < packageHeaderLen-4 {
+		return nil, errors.New("res: package header too short")
+	}
+	le := binary.LittleEndian
+	p := &Package{
+		ID:             le.Uint32(b[8:]),
+		lastPublicType: le.Uint32(b[272:]),
+		lastPublicKey:  le.Uint32(b[280:]),
+	}
+	if h.HeaderSize >= packageHeaderLen {
+		p.typeIDOffset = le.Uint32(b[284:])
+	}
+	name := make([]uint16, 0, 128)
+	for i := 12; i < 268; i += 2 {
+		c := le.Uint16(b[i:])
+		if c == 0 {
+			break
+		}
+		name = append(name, c)
+	}
+	p.Name = string(utf16.Decode(name))
+
+	typeStrings, keyStrings := le.Uint32(b[268:]), le.Uint32(b[276:])
+	if int64(typeStrings) > int64(len(b)) || int64(keyStrings) > int64(len(b)) {
+		return nil, errShort
+	}
+	var err error
+	if p.TypeStrings, err = ParseStringPool(b[typeStrings:]); err != nil {
+		return nil, fmt.Errorf("res: type strings: %w", err)
+	}
+	if p.KeyStrings, err = ParseStringPool(b[keyStrings:]); err != nil {
+		return nil, fmt.Errorf("res: key strings: %w", err)
+	}
+	rest := b[h.HeaderSize:]
+	for len(rest) > 0 {
+		var ch chunkHeader
+		var c []byte
+		ch, c, rest, err = readChunk(rest)
+		if err != nil {
+			return nil, err
+		}
+		switch ch.Type {
+		case chunkStringPool:
+			// the type and key pools, already parsed through their offsets
+		case chunkTableTypeSpec:
+			s, err := parseTypeSpec(ch, c)
+			if err != nil {
+				return nil, err
+			}
+			p.Specs = append(p.Specs, s)
+			p.chunks = append(p.chunks, s)
+		case chunkTableType:
+			t, err := parseType(ch, c)
+			if err != nil {
+				return nil, err
+			}
+			s := p.Spec(t.ID)
+			if s == nil {
+				return nil, fmt.Errorf("res: type %d has no type spec", t.ID)
+			}
+			// sparse types only list entries up to the last one present
+			for len(t.Entries) < len(s.Flags) {
+				t.Entries = append(t.Entries, nil)
+			}
+			s.Types = append(s.Types, t)
+		default:
+			p.chunks = append(p.chunks, c)
+		}
+	}
+	return p, nil
+}
+
+func parseTypeSpec(h chunkHeader, b []byte) (*TypeSpec, error) {
+	if h.HeaderSize < typeSpecHeaderLen {
+		return nil, errShort
+	}
+	n := binary.LittleEndian.Uint32(b[12:])
+	if uint64(h.HeaderSize)+4*uint64(n) > uint64(len(b)) {
+		return nil, errShort
+	}
+	s := &TypeSpec{ID: b[8], Flags: make([]uint32, n), countTypes: binary.LittleEndian.Uint16(b[10:]) != 0}
+	for i := range s.Flags {
+		s.Flags[i] = binary.LittleEndian.Uint32(b[int(h.HeaderSize)+4*i:])
+	}
+	return s, nil
+}
+
+func parseType(h chunkHeader, b []byte) (*Type, error) {
+	if h.HeaderSize < typeHeaderLen+28 {
+		return nil, errShort
+	}
+	le := binary.LittleEndian
+	t := &Type{ID: b[8]}
+	flags := b[9]
+	count := le.Uint32(b[12:])
+	entriesStart := le.Uint32(b[16:])
+	var err error
+	if t.Config, err = parseConfig(b[typeHeaderLen:h.HeaderSize]); err != nil {
+		return nil, err
+	}
+	if int(entriesStart) > len(b) {
+		return nil, errShort
+	}
+	offsets := b[h.HeaderSize:]
+	entries := b[entriesStart:]
+	entryAt := func(off uint32) (*Entry, error) {
+		if int(off) >= len(entries) {
+			return nil, errShort
+		}
+		return parseEntry(entries[off:])
+	}
+	switch {
+	case flags&typeFlagSparse != 0:
+		if uint64(len(offsets)) < 4*uint64(count) {
+			return nil, errShort
+		}
+		for i := 0; i < int(count); i++ {
+			idx := int(le.Uint16(offsets[4*i:]))
+			e, err := entryAt(uint32(le.Uint16(offsets[4*i+2:])) * 4)
+			if err != nil {
+				return nil, err
+			}
+			for len(t.Entries) <= idx {
+				t.Entries = append(t.Entries, nil)
+			}
+			t.Entries[idx] = e
+		}
+	case flags&typeFlagOffset16 != 0:
+		if uint64(len(offsets)) < 2*uint64(count) {
+			return nil, errShort
+		}
+		t.Entries = make([]*Entry, count)
+		for i := range t.Entries {
+			off := le.Uint16(offsets[2*i:])
+			if off == 0xFFFF {
+				continue
+			}
+			if t.Entries[i], err = entryAt(uint32(off) * 4); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		if uint64(len(offsets)) < 4*uint64(count) {
+			return nil, errShort
+		}
+		t.Entries = make([]*Entry, count)
+		for i := range t.Entries {
+			off := le.Uint32(offsets[4*i:])
+			if off == noEntry {
+				continue
+			}
+			if t.Entries[i], err = entryAt(off); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return t, nil
+}
+
+func parseEntry(b []byte) (*Entry, error) {
+	if len(b) < 8 {
+		return nil, errShort
+	}
+	le := binary.LittleEndian
+	size := le.Uint16(b)
+	flags := le.Uint16(b[2:])
+	if flags&flagCompact != 0 {
+		// compact entries pack the key into size and the data type into the high byte of flags
+		return &Entry{
+			Key:   uint32(size),
+			Flags: flags & 0xFF,
+			Value: Value{DataType(flags >> 8), le.Uint32(b[4:])},
+		}, nil
+	}
+	e := &Entry{Key: le.Uint32(b[4:]), Flags: flags}
+	if int(size) > len(b) {
+		return nil, errShort
+	}
+	if !e.IsComplex() {
+		v, err := parseValue(b[size:])
+		if err != nil {
+			return nil, err
+		}
+		e.Value = v
+		return e, nil
+	}
+	if size < 16 {
+		return nil, errShort
+	}
+	e.Parent = le.Uint32(b[8:])
+	count := le.Uint32(b[12:])
+	maps := b[size:]
+	if uint64(len(maps)) < 12*uint64(count) {
+		return nil, errShort
+	}
+	e.Map = make([]MapEntry, count)
+	for i := range e.Map {
+		m := maps[12*i:]
+		v, err := parseValue(m[4:])
+		if err != nil {
+			return nil, err
+		}
+		e.Map[i] = MapEntry{le.Uint32(m), v}
+	}
+	return e, nil
+}
+
+// Marshal encodes the table as resources.arsc. Types are always written densely.
+func (t *Table) Marshal() []byte {
+	c := newChunk(chunkTable, tableHeaderLen)
+	binary.LittleEndian.PutUint32(c.header(), uint32(len(t.Packages)))
+	c.write(t.Strings.Marshal())
+	for _, p := range t.Packages {
+		c.write(p.marshal())
+	}
+	return c.bytes()
+}
+
+func (p *Package) marshal() []byte {
+	c := newChunk(chunkTablePackage, packageHeaderLen)
+	typeStrings := p.TypeStrings.Marshal()
+	keyStrings := p.KeyStrings.Marshal()
+	h := c.header()
+	le := binary.LittleEndian
+	le.PutUint32(h[0:], p.ID)
+	name := utf16.Encode([]rune(p.Name))
+	for i := 0; i < len(name) && i < 127; i++ {
+		le.PutUint16(h[4+2*i:], name[i])
+	}
+	le.PutUint32(h[260:], packageHeaderLen)
+	le.PutUint32(h[264:], p.lastPublicType)
+	le.PutUint32(h[268:], uint32(packageHeaderLen+len(typeStrings)))
+	le.PutUint32(h[272:], p.lastPublicKey)
+	le.PutUint32(h[276:], p.typeIDOffset)
+	c.write(typeStrings, keyStrings)
+	for _, ch := range p.chunks {
+		switch ch := ch.(type) {
+		case *TypeSpec:
+			c.write(ch.marshal())
+			for _, typ := range ch.Types {
+				c.write(typ.marshal())
+			}
+		case []byte:
+			c.write(ch)
+		}
+	}
+	return c.bytes()
+}
+
+func (s *TypeSpec) marshal() []byte {
+	c := newChunk(chunkTableTypeSpec, typeSpecHeaderLen)
+	h := c.header()
+	h[0] = s.ID
+	if s.countTypes {
+		binary.LittleEndian.PutUint16(h[2:], uint16(len(s.Types)))
+	}
+	binary.LittleEndian.PutUint32(h[4:], uint32(len(s.Flags)))
+	for _, f := range s.Flags {
+		c.u32(f)
+	}
+	return c.bytes()
+}
+
+func (t *Type) marshal() []byte {
+	config := t.Config.bytes()
+	headerLen := typeHeaderLen + len(config)
+	c := newChunk(chunkTableType, headerLen)
+	h := c.header()
+	le := binary.LittleEndian
+	h[0] = t.ID
+	le.PutUint32(h[4:], uint32(len(t.Entries)))
+	le.PutUint32(h[8:], uint32(headerLen+4*len(t.Entries)))
+	copy(h[12:], config)
+
+	var entries []byte
+	for _, e := range t.Entries {
+		if e == nil {
+			c.u32(noEntry)
+			continue
+		}
+		c.u32(uint32(len(entries)))
+		entries = e.appendTo(entries)
+	}
+	c.write(entries)
+	return c.bytes()
+}
+
+func (e *Entry) appendTo(b []byte) []byte {
+	le := binary.LittleEndian
+	if e.Flags&flagCompact != 0 && !e.IsComplex() && e.Key <= 0xFFFF {
+		b = le.AppendUint16(b, uint16(e.Key))
+		b = le.AppendUint16(b, e.Flags&0xFF|uint16(e.Value.Type)<<8)
+		return le.AppendUint32(b, e.Value.Data)
+	}
+	flags := e.Flags &^ flagCompact
+	if !e.IsComplex() {
+		b = le.AppendUint16(b, 8)
+		b = le.AppendUint16(b, flags)
+		b = le.AppendUint32(b, e.Key)
+		return appendValue(b, e.Value)
+	}
+	b = le.AppendUint16(b, 16)
+	b = le.AppendUint16(b, flags)
+	b = le.AppendUint32(b, e.Key)
+	b = le.AppendUint32(b, e.Parent)
+	b = le.AppendUint32(b, uint32(len(e.Map)))
+	for _, m := range e.Map {
+		b = le.AppendUint32(b, m.Name)
+		b = appendValue(b, m.Value)
+	}
+	return b
+}
+
+// Spec returns the type spec with the given type id.
+func (p *Package) Spec(id uint8) *TypeSpec {
+	for _, s := range p.Specs {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// TypeName returns the name of type id, e.g. "string".
+func (p *Package) TypeName(id uint8) string {
+	if int(id) < 1 || int(id) > len(p.TypeStrings.Strings) {
+		return ""
+	}
+	return p.TypeStrings.Strings[id-1]
+}
+
+// KeyName returns the name of an entry key.
+func (p *Package) KeyName(key uint32) string {
+	if int(key) >= len(p.KeyStrings.Strings) {
+		return ""
+	}
+	return p.KeyStrings.Strings[key]
+}
+
+// entryKey returns the key index of entry idx of spec, looking through its configurations.
+func (s *TypeSpec) entryKey(idx int) (uint32, bool) {
+	for _, t := range s.Types {
+		if idx < len(t.Entries) && t.Entries[idx] != nil {
+			return t.Entries[idx].Key, true
+		}
+	}
+	return 0, false
+}
+
+// Package returns the package with resource package id, e.g. 0x7f.
+func (t *Table) Package(id uint8) *Package {
+	for _, p := range t.Packages {
+		if p.ID == uint32(id) {
+			return p
+		}
+	}
+	return nil
+}
+
+func splitID(id uint32) (pkg, typ uint8, entry int) {
+	return uint8(id >> 24), uint8(id >> 16), int(id & 0xFFFF)
+}
+
+// Find returns the resource id of a resource named like "string/app_name" or
+// "@mipmap/ic_launcher", searching every package.
+func (t *Table) Find(name string) (uint32, bool) {
+	typ, key, ok := strings.Cut(strings.TrimPrefix(name, "@"), "/")
+	if !ok {
+		return 0, false
+	}
+	for _, p := range t.Packages {
+		keyIdx := p.KeyStrings.Index(key)
+		if keyIdx < 0 {
+			continue
+		}
+		for _, s := range p.Specs {
+			if p.TypeName(s.ID) != typ {
+				continue
+			}
+			for i := range s.Flags {
+				if k, ok := s.entryKey(i); ok && k == uint32(keyIdx) {
+					return p.ID<<24 | uint32(s.ID)<<16 | uint32(i), true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// Name returns the "type/name" of resource id.
+func (t *Table) Name(id uint32) (string, bool) {
+	pkgID, typID, idx := splitID(id)
+	p := t.Package(pkgID)
+	if p == nil {
+		return "", false
+	}
+	s := p.Spec(typID)
+	if s == nil {
+		return "", false
+	}
+	key, ok := s.entryKey(idx)
+	if !ok {
+		return "", false
+	}
+	return p.TypeName(typID) + "/" + p.KeyName(key), true
+}
+
+// Entries returns the values of resource id in every configuration that defines it.
+func (t *Table) Entries(id uint32) []ConfigEntry {
+	pkgID, typID, idx := splitID(id)
+	p := t.Package(pkgID)
+	if p == nil {
+		return nil
+	}
+	s := p.Spec(typID)
+	if s == nil {
+		return nil
+	}
+	var ret []ConfigEntry
+	for _, typ := range s.Types {
+		if idx < len(typ.Entries) && typ.Entries[idx] != nil {
+			ret = append(ret, ConfigEntry{typ.Config, typ.Entries[idx]})
+		}
+	}
+	return ret
+}
+
+// String returns the string a TypeString value refers to in the global string pool.
+func (t *Table) String(v Value) (string, bool) {
+	if v.Type != TypeString || int(v.Data) >= len(t.Strings.Strings) {
+		return "", false
+	}
+	return t.Strings.Strings[v.Data], true
+}
+
+// Resolve follows references to a simple value, taking the default configuration of each
+// resource, or its first configuration when there is no default. Values that are not references,
+// or cannot be resolved, are returned as they are.
+func (t *Table) Resolve(v Value) Value {
+	for depth := 0; v.Type == TypeReference && depth < 8; depth++ {
+		entries := t.Entries(v.Data)
+		if len(entries) == 0 {
+			break
+		}
+		ce := entries[0]
+		for _, e := range entries {
+			if e.Config.IsDefault() {
+				ce = e
+				break
+			}
+		}
+		if ce.Entry.IsComplex() {
+			break
+		}
+		v = ce.Entry.Value
+	}
+	return v
+}
+
+// AddEntry adds the resource typ/name to package p with value v in the default configuration
+// and returns its id. When p already has the resource, only its default value is replaced.
+// The type must already exist in the package.
+func (t *Table) AddEntry(p *Package, typ, name string, v Value) (uint32, error) {
+	if id, ok := t.Find(typ + "/" + name); ok && uint8(id>>24) == uint8(p.ID) {
+		return id, t.SetValue(id, Config{}, v)
+	}
+	var s *TypeSpec
+	for _, spec := range p.Specs {
+		if p.TypeName(spec.ID) == typ {
+			s = spec
+		}
+	}
+	if s == nil {
+		return 0, fmt.Errorf("res: package %s has no %s type", p.Name, typ)
+	}
+	if len(s.Flags) > 0xFFFF {
+		return 0, fmt.Errorf("res: %s type is full", typ)
+	}
+	idx := len(s.Flags)
+	s.Flags = append(s.Flags, 0)
+	for _, typ := range s.Types {
+		for len(typ.Entries) < len(s.Flags) {
+			typ.Entries = append(typ.Entries, nil)
+		}
+	}
+	id := p.ID<<24 | uint32(s.ID)<<16 | uint32(idx)
+	s.setEntry(idx, Config{}, &Entry{Key: p.KeyStrings.Add(name), Value: v})
+	return id, nil
+}
+
+// SetValue sets the simple value of resource id in configuration c, adding the configuration to
+// the type when it has no such configuration yet.
+func (t *Table) SetValue(id uint32, c Config, v Value) error {
+	return t.SetEntry(id, c, &Entry{Value: v})
+}
+
+// SetEntry sets the entry of resource id in configuration c like SetValue; e may be a bag. The key
+// of e is set to the resource's name.
+func (t *Table) SetEntry(id uint32, c Config, e *Entry) error {
+	pkgID, typID, idx := splitID(id)
+	p := t.Package(pkgID)
+	if p == nil {
+		return fmt.Errorf("res: no package for 0x%08x", id)
+	}
+	s := p.Spec(typID)
+	if s == nil || idx >= len(s.Flags) {
+		return fmt.Errorf("res: no resource 0x%08x", id)
+	}
+	key, ok := s.entryKey(idx)
+	if !ok {
+		return fmt.Errorf("res: resource 0x%08x has no name", id)
+	}
+	e.Key = key
+	s.setEntry(idx, c, e)
+	return nil
+}
+
+// SetMap sets the value of attribute name in bag e, keeping the map sorted by attribute id as
+// the runtime expects.
+func (e *Entry) SetMap(name uint32, v Value) {
+	i := sort.Search(len(e.Map), func(i int) bool { return e.Map[i].Name >= name })
+	if i < len(e.Map) && e.Map[i].Name == name {
+		e.Map[i].Value = v
+		return
+	}
+	e.Map = append(e.Map, MapEntry{})
+	copy(e.Map[i+1:], e.Map[i:])
+	e.Map[i] = MapEntry{name, v}
+}
+
+// MapValue returns the value of attribute name in bag e.
+func (e *Entry) MapValue(name uint32) (Value, bool) {
+	for _, m := range e.Map {
+		if m.Name == name {
+			return m.Value, true
+		}
+	}
+	return Value{}, false
+}
+
+// setEntry stores e as entry idx of configuration c, and marks the entry as varying with the
+// qualifiers c sets.
+func (s *TypeSpec) setEntry(idx int, c Config, e *Entry) {
+	var typ *Type
+	for _, t := range s.Types {
+		if t.Config.Equal(c) {
+			typ = t
+			break
+		}
+	}
+	if typ == nil {
+		typ = &Type{ID: s.ID, Config: Config{append([]byte(nil), c.bytes()...)}, Entries: make([]*Entry, len(s.Flags))}
+		s.Types = append(s.Types, typ)
+	}
+	typ.Entries[idx] = e
+	s.Flags[idx] |= c.changeFlags()
+}
@@ -0,0 +1,385 @@
+package res
+
+import (
+	"encoding/binary"
+	"math"
+	"path"
+	"strings"
+)
+
+// bag keys of attr and plurals resources (ResTable_map)
+const (
+	attrType  = 0x01000000
+	attrMin   = 0x01000001
+	attrMax   = 0x01000002
+	attrL10n  = 0x01000003
+	attrOther = 0x01000004
+	attrZero  = 0x01000005
+	attrOne   = 0x01000006
+	attrTwo   = 0x01000007
+	attrFew   = 0x01000008
+	attrMany  = 0x01000009
+)
+
+// pluralArity maps plural bag keys to Plural.Arity.
+var pluralArity = map[uint32]uint64{attrZero: 0, attrOne: 1, attrTwo: 2, attrFew: 3, attrMany: 4, attrOther: 5}
+
+// specPublic is ResTable_typeSpec::SPEC_PUBLIC.
+const specPublic = 0x40000000
+
+// FileReference.Type
+const (
+	fileUnknown  = 0
+	filePNG      = 1
+	fileProtoXML = 3
+)
+
+// XMLFiles returns the binary XML files t references, the files aapt2 converts to proto XML when
+// it converts an APK to bundle format. MarshalProto marks them as proto XML, so they must be
+// replaced by XML.MarshalProto.
+func (t *Table) XMLFiles() []string {
+	var files []string
+	seen := map[string]bool{}
+	t.eachFile(func(typ, file string) {
+		if fileType(typ, file) == fileProtoXML && !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	})
+	return files
+}
+
+// eachFile calls fn for every file path the entries of t refer to, with the type name of the
+// entry.
+func (t *Table) eachFile(fn func(typ, file string)) {
+	for _, p := range t.Packages {
+		for _, s := range p.Specs {
+			typ := p.TypeName(s.ID)
+			for _, ty := range s.Types {
+				for _, e := range ty.Entries {
+					if e == nil || e.IsComplex() {
+						continue
+					}
+					if file, ok := t.file(typ, e.Value); ok {
+						fn(typ, file)
+					}
+				}
+			}
+		}
+	}
+}
+
+// file reports whether v of a resource of type typ is a file path. Like aapt2, any unstyled
+// string starting with res/ is a file unless the resource is a string.
+func (t *Table) file(typ string, v Value) (string, bool) {
+	s, ok := t.String(v)
+	if !ok || typ == "string" || !strings.HasPrefix(s, "res/") {
+		return "", false
+	}
+	if int(v.Data) < len(t.Strings.Styles) && len(t.Strings.Styles[v.Data]) > 0 {
+		return "", false
+	}
+	return s, true
+}
+
+func fileType(typ, file string) uint64 {
+	switch {
+	case typ == "raw":
+		return fileUnknown
+	case path.Ext(file) == ".xml":
+		return fileProtoXML
+	case path.Ext(file) == ".png":
+		return filePNG
+	}
+	return fileUnknown
+}
+
+// MarshalProto encodes t as an aapt2 ResourceTable message (Resources.proto), the resources.pb of
+// an Android App Bundle module.
+func (t *Table) MarshalProto() []byte {
+	var b pb
+	for _, p := range t.Packages {
+		b.message(2, t.packageProto(p))
+	}
+	var tool pb
+	tool.string(1, "apk-editor")
+	b.message(4, tool)
+	return b
+}
+
+func (t *Table) packageProto(p *Package) pb {
+	var b, id pb
+	id.uint32(1, p.ID)
+	b.message(1, id)
+	b.string(2, p.Name)
+	for _, s := range p.Specs {
+		typ := p.TypeName(s.ID)
+		var tb, tid pb
+		tid.uint32(1, uint32(s.ID))
+		tb.message(1, tid)
+		tb.string(2, typ)
+		for idx := range s.Flags {
+			key, ok := s.entryKey(idx)
+			if !ok {
+				continue
+			}
+			var eb, eid pb
+			eid.uint32(1, uint32(idx))
+			eb.message(1, eid)
+			eb.string(2, p.KeyName(key))
+			if s.Flags[idx]&specPublic != 0 {
+				var vis pb
+				vis.uint32(3, 2) // PUBLIC
+				eb.message(3, vis)
+			}
+			for _, ty := range s.Types {
+				if idx >= len(ty.Entries) || ty.Entries[idx] == nil {
+					continue
+				}
+				var cv pb
+				cv.message(1, ty.Config.proto())
+				cv.message(2, t.valueProto(typ, ty.Entries[idx]))
+				eb.message(6, cv)
+			}
+			tb.message(3, eb)
+		}
+		b.message(3, tb)
+	}
+	return b
+}
+
+// valueProto encodes entry e of a resource of type typ as a Value message.
+func (t *Table) valueProto(typ string, e *Entry) pb {
+	var b pb
+	b.bool(3, e.Flags&FlagWeak != 0)
+	if typ == "id" {
+		var item pb
+		item.message(6, nil)
+		b.message(4, item)
+		return b
+	}
+	if !e.IsComplex() {
+		b.message(4, t.itemProto(typ, e.Value))
+		return b
+	}
+	var c pb
+	switch typ {
+	case "attr", "^attr-private":
+		c.message(1, attrProto(e))
+	case "array":
+		var a pb
+		for _, m := range e.Map {
+			var el pb
+			el.message(3, t.itemProto(typ, m.Value))
+			a.message(1, el)
+		}
+		c.message(4, a)
+	case "plurals":
+		var p pb
+		for _, m := range e.Map {
+			var pe pb
+			pe.uint32(3, uint32(pluralArity[m.Name]))
+			pe.message(4, t.itemProto(typ, m.Value))
+			p.message(1, pe)
+		}
+		c.message(5, p)
+	default:
+		var s pb
+		if e.Parent != 0 {
+			s.message(1, referenceProto(Reference(e.Parent)))
+		}
+		for _, m := range e.Map {
+			var se pb
+			se.message(3, referenceProto(Reference(m.Name)))
+			se.message(4, t.itemProto(typ, m.Value))
+			s.message(3, se)
+		}
+		c.message(2, s)
+	}
+	b.message(5, c)
+	return b
+}
+
+func attrProto(e *Entry) pb {
+	var b pb
+	for _, m := range e.Map {
+		switch m.Name {
+		case attrType:
+			b.uint32(1, m.Value.Data)
+		case attrMin:
+			b.int32(2, int32(m.Value.Data))
+		case attrMax:
+			b.int32(3, int32(m.Value.Data))
+		case attrL10n:
+		default:
+			// enum or flag symbol: the bag key is the id of the symbol
+			var sym pb
+			sym.message(3, referenceProto(Reference(m.Name)))
+			sym.uint32(4, m.Value.Data)
+			sym.uint32(5, uint32(m.Value.Type))
+			b.message(4, sym)
+		}
+	}
+	return b
+}
+
+// itemProto encodes a value of the table as an Item message, turning strings into String,
+// StyledString or FileReference items.
+func (t *Table) itemProto(typ string, v Value) pb {
+	if v.Type != TypeString {
+		return itemProto(v)
+	}
+	var b pb
+	s, _ := t.String(v)
+	if file, ok := t.file(typ, v); ok {
+		var f pb
+		f.string(1, file)
+		f.uint32(2, uint32(fileType(typ, file)))
+		b.message(5, f)
+		return b
+	}
+	if int(v.Data) < len(t.Strings.Styles) && len(t.Strings.Styles[v.Data]) > 0 {
+		var st pb
+		st.string(1, s)
+		for _, span := range t.Strings.Styles[v.Data] {
+			var sp pb
+			if int(span.Name) < len(t.Strings.Strings) {
+				sp.string(1, t.Strings.Strings[span.Name])
+			}
+			sp.uint32(2, span.First)
+			sp.uint32(3, span.Last)
+			st.message(2, sp)
+		}
+		b.message(4, st)
+		return b
+	}
+	var str pb
+	str.string(1, s)
+	b.message(2, str)
+	return b
+}
+
+// itemProto encodes a value that is not a string as an Item message.
+func itemProto(v Value) pb {
+	var b, prim pb
+	switch v.Type {
+	case TypeReference, TypeAttribute, TypeDynamicRef, TypeDynamicAttr:
+		b.message(1, referenceProto(v))
+		return b
+	case TypeNull:
+		if v.Data == 1 {
+			prim.message(2, nil) // @empty
+		} else {
+			prim.message(1, nil)
+		}
+	case TypeFloat:
+		prim.float32Always(3, math.Float32frombits(v.Data))
+	case TypeDimension:
+		prim.varintAlways(13, uint64(v.Data))
+	case TypeFraction:
+		prim.varintAlways(14, uint64(v.Data))
+	case TypeIntDec:
+		prim.varintAlways(6, uint64(int64(int32(v.Data))))
+	case TypeIntHex:
+		prim.varintAlways(7, uint64(v.Data))
+	case TypeIntBoolean:
+		var x uint64
+		if v.Data != 0 {
+			x = 1
+		}
+		prim.varintAlways(8, x)
+	case TypeIntColorARGB8:
+		prim.varintAlways(9, uint64(v.Data))
+	case TypeIntColorRGB8:
+		prim.varintAlways(10, uint64(v.Data))
+	case TypeIntColorARGB4:
+		prim.varintAlways(11, uint64(v.Data))
+	case TypeIntColorRGB4:
+		prim.varintAlways(12, uint64(v.Data))
+	default:
+		prim.varintAlways(7, uint64(v.Data))
+	}
+	b.message(7, prim)
+	return b
+}
+
+func referenceProto(v Value) pb {
+	var b pb
+	if v.Type == TypeAttribute || v.Type == TypeDynamicAttr {
+		b.uint32(1, 1) // ATTRIBUTE
+	}
+	b.uint32(2, v.Data)
+	if v.Type == TypeDynamicRef || v.Type == TypeDynamicAttr {
+		var dyn pb
+		dyn.bool(1, true)
+		b.message(5, dyn)
+	}
+	return b
+}
+
+// proto encodes c as an aapt2 Configuration message (Configuration.proto).
+func (c Config) proto() pb {
+	le := binary.LittleEndian
+	var b pb
+	b.uint32(1, uint32(le.Uint16(c.field(4, 2))))
+	b.uint32(2, uint32(le.Uint16(c.field(6, 2))))
+	b.string(3, c.bcp47())
+	// enums where the binary and proto values differ only in order: 1 means "no" in the binary
+	// config and is the second proto value
+	swap := func(v byte) uint32 {
+		switch v {
+		case 1:
+			return 2
+		case 2:
+			return 1
+		}
+		return 0
+	}
+	layout := c.field(28, 1)[0]
+	b.uint32(4, uint32(layout>>6&0x3))
+	b.uint32(5, uint32(le.Uint16(c.field(20, 2))))
+	b.uint32(6, uint32(le.Uint16(c.field(22, 2))))
+	b.uint32(7, uint32(le.Uint16(c.field(32, 2))))
+	b.uint32(8, uint32(le.Uint16(c.field(34, 2))))
+	b.uint32(9, uint32(le.Uint16(c.field(30, 2))))
+	b.uint32(10, uint32(layout&0x0F))
+	b.uint32(11, swap(layout>>4&0x3))
+	layout2 := c.field(48, 1)[0]
+	b.uint32(12, swap(layout2&0x3))
+	color := c.field(49, 1)[0]
+	b.uint32(13, swap(color&0x3))
+	b.uint32(14, swap(color>>2&0x3))
+	b.uint32(15, uint32(c.field(12, 1)[0]))
+	ui := c.field(29, 1)[0]
+	b.uint32(16, uint32(ui&0x0F))
+	b.uint32(17, swap(ui>>4&0x3))
+	b.uint32(18, uint32(c.Density()))
+	b.uint32(19, uint32(c.field(13, 1)[0]))
+	input := c.field(18, 1)[0]
+	b.uint32(20, uint32(input&0x3))
+	b.uint32(21, uint32(c.field(16, 1)[0]))
+	b.uint32(22, uint32(input>>2&0x3))
+	b.uint32(23, uint32(c.field(17, 1)[0]))
+	b.uint32(24, uint32(c.SDKVersion()))
+	return b
+}
+
+// bcp47 returns the locale of c as a BCP-47 tag, e.g. "zh-Hans-CN", the form Configuration.locale
+// uses.
+func (c Config) bcp47() string {
+	l := c.Language()
+	if l == "" {
+		return ""
+	}
+	// the script is only part of the qualifier when aapt did not compute it from the locale
+	if script := strings.TrimRight(string(c.field(36, 4)), "\x00"); script != "" && c.field(52, 1)[0] == 0 {
+		l += "-" + script
+	}
+	if r := c.Region(); r != "" {
+		l += "-" + r
+	}
+	if v := strings.TrimRight(string(c.field(40, 8)), "\x00"); v != "" {
+		l += "-" + v
+	}
+	return l
+}
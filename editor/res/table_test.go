@@ -0,0 +1,173 @@
+package res
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+)
+
+const templateApk = "../../release/app-release.apk"
+
+func readTemplateEntry(t *testing.T, name string) []byte {
+	t.Helper()
+	z, err := zip.OpenReader(templateApk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer z.Close()
+	for _, f := range z.File {
+		if f.Name == name {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			b, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return b
+		}
+	}
+	t.Fatalf("%s not found in template", name)
+	return nil
+}
+
+func TestTableRoundTrip(t *testing.T) {
+	raw := readTemplateEntry(t, "resources.arsc")
+	table, err := ParseTable(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := table.Marshal(); !bytes.Equal(out, raw) {
+		t.Fatalf("marshal changed the table: %d bytes, want %d", len(out), len(raw))
+	}
+}
+
+func TestTableFind(t *testing.T) {
+	table, err := ParseTable(readTemplateEntry(t, "resources.arsc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, ok := table.Find("@color/purple_500")
+	if !ok {
+		t.Fatal("color/purple_500 not found")
+	}
+	if name, _ := table.Name(id); name != "color/purple_500" {
+		t.Errorf("Name(0x%08x) = %q", id, name)
+	}
+	entries := table.Entries(id)
+	if len(entries) != 1 || entries[0].Entry.Value != Color(0xFF6200EE) {
+		t.Errorf("color/purple_500 = %+v", entries)
+	}
+	id, ok = table.Find("xml/network_security_config")
+	if !ok {
+		t.Fatal("xml/network_security_config not found")
+	}
+	if s, _ := table.String(table.Entries(id)[0].Entry.Value); s == "" {
+		t.Error("xml/network_security_config has no file path")
+	}
+}
+
+func TestTableAddEntry(t *testing.T) {
+	table, err := ParseTable(readTemplateEntry(t, "resources.arsc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := table.Packages[0]
+	id, err := table.AddEntry(p, "string", "app_name", Value{TypeString, table.Strings.Add("Demo")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ja Config
+	ja.SetLocale("ja", "")
+	if err := table.SetValue(id, ja, Value{TypeString, table.Strings.Add("デモ")}); err != nil {
+		t.Fatal(err)
+	}
+	var zh Config
+	zh.SetLocale("zh", "TW")
+	if err := table.SetValue(id, zh, Value{TypeString, table.Strings.Add("演示")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := table.AddEntry(p, "nosuchtype", "x", Value{}); err == nil {
+		t.Error("unknown type accepted")
+	}
+
+	table, err = ParseTable(table.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := table.Find("string/app_name"); !ok || got != id {
+		t.Fatalf("string/app_name = 0x%08x %v, want 0x%08x", got, ok, id)
+	}
+	labels := map[string]string{}
+	for _, ce := range table.Entries(id) {
+		labels[ce.Config.Locale()], _ = table.String(ce.Entry.Value)
+	}
+	if labels[""] != "Demo" || labels["ja"] != "デモ" || labels["zh-TW"] != "演示" || len(labels) != 3 {
+		t.Errorf("labels %v", labels)
+	}
+	_, typ, idx := splitID(id)
+	if f := table.Packages[0].Spec(typ).Flags[idx]; f != ConfigLocale {
+		t.Errorf("spec flags %#x", f)
+	}
+	// 已有的资源只修改默认值
+	again, err := table.AddEntry(table.Packages[0], "string", "app_name", Value{TypeString, table.Strings.Add("Demo2")})
+	if err != nil || again != id {
+		t.Errorf("AddEntry again = 0x%08x %v", again, err)
+	}
+}
+
+func TestParseTableErrors(t *testing.T) {
+	raw := readTemplateEntry(t, "resources.arsc")
+	header, chunks := splitChunks(t, raw)
+	pool, pkg := chunks[0], chunks[1]
+	pkgHeader, pkgChunks := splitChunks(t, pkg)
+	var noSpecs [][]byte
+	for _, c := range pkgChunks {
+		if binary.LittleEndian.Uint16(c) != chunkTableTypeSpec {
+			noSpecs = append(noSpecs, c)
+		}
+	}
+	badPool := append([]byte(nil), pkgHeader...)
+	binary.LittleEndian.PutUint32(badPool[268:], uint32(len(pkg)+1))
+	shortPkg := append([]byte(nil), pkg[:chunkHeaderLen]...)
+	binary.LittleEndian.PutUint16(shortPkg[2:], chunkHeaderLen)
+	binary.LittleEndian.PutUint32(shortPkg[4:], chunkHeaderLen)
+	unknown := make([]byte, chunkHeaderLen)
+	binary.LittleEndian.PutUint16(unknown, chunkXML)
+	binary.LittleEndian.PutUint16(unknown[2:], chunkHeaderLen)
+	binary.LittleEndian.PutUint32(unknown[4:], chunkHeaderLen)
+
+	for _, tt := range []struct {
+		name string
+		b    []byte
+		err  string // 为空时应当解析成功
+	}{
+		{"empty", nil, "truncated"},
+		{"not a table", pool, "not a resource table"},
+		{"size beyond data", raw[:len(raw)-1], "bad chunk"},
+		{"no string pool", joinChunks(header, pkg), "no string pool"},
+		{"unexpected chunk", joinChunks(header, pool, unknown, pkg), "unexpected chunk"},
+		{"short package header", joinChunks(header, pool, shortPkg), "package header too short"},
+		{"pool offset beyond package", joinChunks(header, pool, joinChunks(badPool, pkgChunks...)), "truncated"},
+		{"type without spec", joinChunks(header, pool, joinChunks(pkgHeader, noSpecs...)), "has no type spec"},
+		{"no packages", joinChunks(header, pool), ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTable(tt.b)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
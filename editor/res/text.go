@@ -0,0 +1,224 @@
+package res
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// androidAttrNames maps the ids of androidAttrs back to their names.
+var androidAttrNames = func() map[uint32]string {
+	m := make(map[uint32]string, len(androidAttrs))
+	for name, d := range androidAttrs {
+		m[d.ID] = name
+	}
+	return m
+}()
+
+// layoutSymbols are enums of common layout attributes, used only to make decoded layouts
+// readable; the attributes are not in androidAttrs, so they are looked up by name.
+var layoutSymbols = map[string]AttrDef{
+	"layout_width":  {Format: FormatEnum, Symbols: layoutSizes},
+	"layout_height": {Format: FormatEnum, Symbols: layoutSizes},
+	"visibility":    {Format: FormatEnum, Symbols: map[string]uint32{"visible": 0, "invisible": 1, "gone": 2}},
+	"orientation":   {Format: FormatEnum, Symbols: map[string]uint32{"horizontal": 0, "vertical": 1}},
+}
+
+var layoutSizes = map[string]uint32{"match_parent": 0xFFFFFFFF, "wrap_content": 0xFFFFFFFE}
+
+// Text decodes the document back to text XML, one attribute per line like Android Studio
+// formats it. References are written as @type/name when table has the resource and as
+// @0x7f010000 otherwise; enum and flag values of known android: attributes are written as
+// their symbols, so the output of a manifest compiles again with CompileXML. table may be nil.
+func (x *XML) Text(table *Table) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	prefixes := map[string]string{}
+	x.Root.text(&b, table, prefixes, 0)
+	return b.Bytes()
+}
+
+func (e *Element) text(b *bytes.Buffer, table *Table, prefixes map[string]string, depth int) {
+	indent := strings.Repeat("    ", depth)
+	if e.Name == "" {
+		b.WriteString(indent)
+		b.WriteString(escapeText(e.Text, false))
+		b.WriteByte('\n')
+		return
+	}
+	if e.Comment != "" {
+		fmt.Fprintf(b, "%s<!-- %s -->\n", indent, e.Comment)
+	}
+	// 命名空间只在声明它的元素及其子元素中有效
+	var declared []string
+	for _, ns := range e.Namespaces {
+		if _, ok := prefixes[ns.URI]; !ok {
+			prefixes[ns.URI] = ns.Prefix
+			declared = append(declared, ns.URI)
+		}
+	}
+	// 没有声明的命名空间在使用它的元素上声明
+	var undeclared []Namespace
+	defer func() {
+		for _, uri := range declared {
+			delete(prefixes, uri)
+		}
+	}()
+	qname := func(ns, name string) string {
+		if ns == "" {
+			return name
+		}
+		p, ok := prefixes[ns]
+		if !ok {
+			p = fmt.Sprintf("ns%d", len(prefixes))
+			prefixes[ns] = p
+			declared = append(declared, ns)
+			undeclared = append(undeclared, Namespace{p, ns})
+		}
+		return p + ":" + name
+	}
+	name := qname(e.Namespace, e.Name)
+	var attrs []string
+	for _, a := range e.Attrs {
+		n := a.Name
+		if n == "" {
+			n = androidAttrNames[a.ID]
+		}
+		attrs = append(attrs, fmt.Sprintf(`%s="%s"`, qname(a.Namespace, n), escapeText(a.text(table), true)))
+	}
+	var nsAttrs []string
+	for _, ns := range append(e.Namespaces[:len(e.Namespaces):len(e.Namespaces)], undeclared...) {
+		if ns.Prefix == "" {
+			nsAttrs = append(nsAttrs, fmt.Sprintf(`xmlns="%s"`, escapeText(ns.URI, true)))
+		} else {
+			nsAttrs = append(nsAttrs, fmt.Sprintf(`xmlns:%s="%s"`, ns.Prefix, escapeText(ns.URI, true)))
+		}
+	}
+	attrs = append(nsAttrs, attrs...)
+	b.WriteString(indent + "<" + name)
+	for i, a := range attrs {
+		if i == 0 {
+			b.WriteString(" " + a)
+		} else {
+			b.WriteString("\n" + indent + "    " + a)
+		}
+	}
+	if len(e.Children) == 0 {
+		b.WriteString(" />\n")
+		return
+	}
+	b.WriteString(">\n")
+	for _, c := range e.Children {
+		c.text(b, table, prefixes, depth+1)
+	}
+	b.WriteString(indent + "</" + name + ">\n")
+}
+
+// text returns the value of a as written in text XML.
+func (a *Attr) text(table *Table) string {
+	v := a.Value
+	switch v.Type {
+	case TypeString:
+		// 以@或?开头的字符串需要转义, 否则会被当作引用
+		if strings.HasPrefix(a.Raw, "@") || strings.HasPrefix(a.Raw, "?") {
+			return `\` + a.Raw
+		}
+		return a.Raw
+	case TypeReference, TypeAttribute, TypeDynamicRef, TypeDynamicAttr:
+		if v.Data == 0 || table == nil {
+			break
+		}
+		if name, ok := table.Name(v.Data); ok {
+			if v.Type == TypeAttribute || v.Type == TypeDynamicAttr {
+				return "?" + name
+			}
+			return "@" + name
+		}
+	case TypeIntDec, TypeIntHex:
+		if a.Namespace != AndroidNS {
+			break
+		}
+		d, ok := androidAttrs[androidAttrNames[a.ID]]
+		if !ok || d.ID != a.ID {
+			d, ok = layoutSymbols[a.Name]
+		}
+		if ok && d.Symbols != nil {
+			if s, ok := d.formatSymbols(v.Data); ok {
+				return s
+			}
+		}
+	}
+	return v.Format(nil)
+}
+
+// formatSymbols writes an enum value as its name and a flags value as name|name, leaving out
+// flags whose bits are covered by another flag in the value.
+func (d AttrDef) formatSymbols(v uint32) (string, bool) {
+	names := make([]string, 0, len(d.Symbols))
+	for name := range d.Symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if d.Format&FormatEnum != 0 {
+		for _, name := range names {
+			if d.Symbols[name] == v {
+				return name, true
+			}
+		}
+		return "", false
+	}
+	if v == 0 {
+		for _, name := range names {
+			if d.Symbols[name] == 0 {
+				return name, true
+			}
+		}
+		return "", false
+	}
+	var matched []string
+	for _, name := range names {
+		f := d.Symbols[name]
+		if f == 0 || v&f != f {
+			continue
+		}
+		dup := false
+		for _, m := range matched {
+			if d.Symbols[m] == f {
+				dup = true
+			}
+		}
+		if !dup {
+			matched = append(matched, name)
+		}
+	}
+	var flags []string
+	var got uint32
+	for _, name := range matched {
+		f := d.Symbols[name]
+		covered := false
+		for _, other := range matched {
+			o := d.Symbols[other]
+			if o != f && o&f == f {
+				covered = true
+			}
+		}
+		if !covered {
+			flags = append(flags, name)
+			got |= f
+		}
+	}
+	if got != v {
+		return "", false
+	}
+	sort.Slice(flags, func(i, j int) bool { return d.Symbols[flags[i]] < d.Symbols[flags[j]] })
+	return strings.Join(flags, "|"), true
+}
+
+func escapeText(s string, attr bool) string {
+	r := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	if attr {
+		r = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "\n", "&#10;")
+	}
+	return r.Replace(s)
+}
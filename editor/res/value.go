@@ -0,0 +1,138 @@
+package res
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// DataType is Res_value.dataType
+type DataType uint8
+
+const (
+	TypeNull          DataType = 0x00
+	TypeReference     DataType = 0x01
+	TypeAttribute     DataType = 0x02
+	TypeString        DataType = 0x03
+	TypeFloat         DataType = 0x04
+	TypeDimension     DataType = 0x05
+	TypeFraction      DataType = 0x06
+	TypeDynamicRef    DataType = 0x07
+	TypeDynamicAttr   DataType = 0x08
+	TypeIntDec        DataType = 0x10
+	TypeIntHex        DataType = 0x11
+	TypeIntBoolean    DataType = 0x12
+	TypeIntColorARGB8 DataType = 0x1c
+	TypeIntColorRGB8  DataType = 0x1d
+	TypeIntColorARGB4 DataType = 0x1e
+	TypeIntColorRGB4  DataType = 0x1f
+)
+
+const valueLen = 8
+
+// Value is Res_value. For TypeString, Data is an index into the string pool of the owning
+// document or table.
+type Value struct {
+	Type DataType
+	Data uint32
+}
+
+// Bool returns a TypeIntBoolean value.
+func Bool(b bool) Value {
+	if b {
+		return Value{TypeIntBoolean, 0xFFFFFFFF}
+	}
+	return Value{TypeIntBoolean, 0}
+}
+
+// Int returns a TypeIntDec value.
+func Int(i int32) Value {
+	return Value{TypeIntDec, uint32(i)}
+}
+
+// Reference returns a TypeReference value pointing at resource id.
+func Reference(id uint32) Value {
+	return Value{TypeReference, id}
+}
+
+// Color returns a TypeIntColorARGB8 value.
+func Color(argb uint32) Value {
+	return Value{TypeIntColorARGB8, argb}
+}
+
+func parseValue(b []byte) (Value, error) {
+	if len(b) < valueLen {
+		return Value{}, errShort
+	}
+	return Value{DataType(b[3]), binary.LittleEndian.Uint32(b[4:])}, nil
+}
+
+func appendValue(b []byte, v Value) []byte {
+	b = binary.LittleEndian.AppendUint16(b, valueLen)
+	b = append(b, 0, byte(v.Type))
+	return binary.LittleEndian.AppendUint32(b, v.Data)
+}
+
+var dimensionUnits = []string{"px", "dip", "sp", "pt", "in", "mm"}
+var fractionUnits = []string{"%", "%p"}
+var radixMults = []float64{1.0 / (1 << 8), 1.0 / (1 << 15), 1.0 / (1 << 23), 1.0 / (1 << 31)}
+
+func complexToFloat(c uint32) float64 {
+	return float64(int32(c&0xFFFFFF00)) * radixMults[(c>>4)&0x3]
+}
+
+// Format returns the value the way it would be written in a text resource. Strings are looked
+// up in pool, references are printed as hex ids; callers that have a table can resolve names.
+func (v Value) Format(pool *StringPool) string {
+	switch v.Type {
+	case TypeNull:
+		if v.Data == 1 {
+			return "@empty"
+		}
+		return "@null"
+	case TypeReference, TypeDynamicRef:
+		if v.Data == 0 {
+			return "@null"
+		}
+		return fmt.Sprintf("@0x%08x", v.Data)
+	case TypeAttribute, TypeDynamicAttr:
+		return fmt.Sprintf("?0x%08x", v.Data)
+	case TypeString:
+		if pool != nil && int(v.Data) < len(pool.Strings) {
+			return pool.Strings[v.Data]
+		}
+		return fmt.Sprintf("@string#%d", v.Data)
+	case TypeFloat:
+		return fmt.Sprint(math.Float32frombits(v.Data))
+	case TypeDimension:
+		unit := "?"
+		if u := v.Data & 0xF; int(u) < len(dimensionUnits) {
+			unit = dimensionUnits[u]
+		}
+		return fmt.Sprint(complexToFloat(v.Data)) + unit
+	case TypeFraction:
+		unit := "?"
+		if u := v.Data & 0xF; int(u) < len(fractionUnits) {
+			unit = fractionUnits[u]
+		}
+		return fmt.Sprint(complexToFloat(v.Data)*100) + unit
+	case TypeIntDec:
+		return fmt.Sprint(int32(v.Data))
+	case TypeIntHex:
+		return fmt.Sprintf("0x%x", v.Data)
+	case TypeIntBoolean:
+		if v.Data != 0 {
+			return "true"
+		}
+		return "false"
+	case TypeIntColorARGB8:
+		return fmt.Sprintf("#%08x", v.Data)
+	case TypeIntColorRGB8:
+		return fmt.Sprintf("#%06x", v.Data&0xFFFFFF)
+	case TypeIntColorARGB4:
+		return fmt.Sprintf("#%x%x%x%x", (v.Data>>28)&0xF, (v.Data>>20)&0xF, (v.Data>>12)&0xF, (v.Data>>4)&0xF)
+	case TypeIntColorRGB4:
+		return fmt.Sprintf("#%x%x%x", (v.Data>>20)&0xF, (v.Data>>12)&0xF, (v.Data>>4)&0xF)
+	}
+	return fmt.Sprintf("0x%08x (type 0x%02x)", v.Data, uint8(v.Type))
+}
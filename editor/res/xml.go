@@ -0,0 +1,536 @@
+package res
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+const (
+	xmlHeaderLen     = 8
+	xmlNodeHeaderLen = 16
+	xmlAttrLen       = 20
+	attrExtLen       = 20
+	noIndex          = 0xFFFFFFFF
+)
+
+// AndroidNS is the namespace of the android: attributes.
+const AndroidNS = "http://schemas.android.com/apk/res/android"
+
+// XML is a parsed binary XML document such as a compiled AndroidManifest.xml. Marshal rebuilds
+// the string pool and resource map from the tree, so the tree can be edited freely.
+type XML struct {
+	Root *Element
+
+	// the original pool and resource map, reused by Marshal so that an unchanged document
+	// round-trips to the same bytes
+	pool   *StringPool
+	resIDs []uint32
+}
+
+// Namespace is a namespace declaration (ResXMLTree_namespaceExt).
+type Namespace struct {
+	Prefix string
+	URI    string
+}
+
+// Element is a start/end element pair with its attributes and children. A CDATA node is an
+// Element with an empty Name and the text in Text.
+type Element struct {
+	Namespace  string
+	Name       string
+	Attrs      []*Attr
+	Children   []*Element
+	Text       string
+	Namespaces []Namespace // declared on this element
+	Line       uint32
+	Comment    string
+
+	textValue Value
+}
+
+// Attr is a ResXMLTree_attribute. ID is the android attribute resource id, or 0. For
+// TypeString values the string is Raw and Value.Data is ignored.
+type Attr struct {
+	Namespace string
+	Name      string
+	ID        uint32
+	Raw       string
+	Value     Value
+
+	hasRaw bool
+}
+
+// ParseXML parses a RES_XML_TYPE chunk.
+func ParseXML(b []byte) (*XML, error) {
+	h, b, _, err := readChunk(b)
+	if err != nil {
+		return nil, err
+	}
+	if h.Type != chunkXML || h.HeaderSize < xmlHeaderLen {
+		return nil, errors.New("res: not a binary xml")
+	}
+	x := &XML{}
+	le := binary.LittleEndian
+	str := func(i uint32) string {
+		if i == noIndex || x.pool == nil || int(i) >= len(x.pool.Strings) {
+			return ""
+		}
+		return x.pool.Strings[i]
+	}
+	var stack []*Element
+	var pending []Namespace
+	rest := b[h.HeaderSize:]
+	for len(rest) > 0 {
+		var ch chunkHeader
+		var c []byte
+		ch, c, rest, err = readChunk(rest)
+		if err != nil {
+			return nil, err
+		}
+		switch ch.Type {
+		case chunkStringPool:
+			if x.pool, err = ParseStringPool(c); err != nil {
+				return nil, err
+			}
+			continue
+		case chunkXMLResourceMap:
+			for i := int(ch.HeaderSize); i+4 <= len(c); i += 4 {
+				x.resIDs = append(x.resIDs, le.Uint32(c[i:]))
+			}
+			continue
+		case chunkXMLStartNS, chunkXMLEndNS, chunkXMLStartElement, chunkXMLEndElement, chunkXMLCData:
+		default:
+			// 不认识的chunk直接忽略, 与 ResXMLParser 相同
+			continue
+		}
+		if ch.HeaderSize < xmlNodeHeaderLen || len(c) < int(ch.HeaderSize)+8 {
+			return nil, errShort
+		}
+		line, comment := le.Uint32(c[8:]), str(le.Uint32(c[12:]))
+		ext := c[ch.HeaderSize:]
+		switch ch.Type {
+		case chunkXMLStartNS:
+			pending = append(pending, Namespace{str(le.Uint32(ext)), str(le.Uint32(ext[4:]))})
+		case chunkXMLEndNS:
+		case chunkXMLStartElement:
+			if len(ext) < attrExtLen {
+				return nil, errShort
+			}
+			e := &Element{
+				Namespace:  str(le.Uint32(ext)),
+				Name:       str(le.Uint32(ext[4:])),
+				Namespaces: pending,
+				Line:       line,
+				Comment:    comment,
+			}
+			pending = nil
+			start, size, count := int(le.Uint16(ext[8:])), int(le.Uint16(ext[10:])), int(le.Uint16(ext[12:]))
+			if size < xmlAttrLen || start+size*count > len(ext) {
+				return nil, errShort
+			}
+			for i := 0; i < count; i++ {
+				a := ext[start+size*i:]
+				nameIdx := le.Uint32(a[4:])
+				raw := le.Uint32(a[8:])
+				v, err := parseValue(a[12:])
+				if err != nil {
+					return nil, err
+				}
+				attr := &Attr{
+					Namespace: str(le.Uint32(a)),
+					Name:      str(nameIdx),
+					Raw:       str(raw),
+					Value:     v,
+					hasRaw:    raw != noIndex,
+				}
+				if int(nameIdx) < len(x.resIDs) {
+					attr.ID = x.resIDs[nameIdx]
+				}
+				if v.Type == TypeString && !attr.hasRaw {
+					attr.Raw = str(v.Data)
+				}
+				e.Attrs = append(e.Attrs, attr)
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, e)
+			} else if x.Root == nil {
+				x.Root = e
+			} else {
+				return nil, errors.New("res: xml has more than one root element")
+			}
+			stack = append(stack, e)
+		case chunkXMLEndElement:
+			if len(stack) == 0 {
+				return nil, errors.New("res: unbalanced xml end element")
+			}
+			stack = stack[:len(stack)-1]
+		case chunkXMLCData:
+			if len(ext) < 4+valueLen {
+				return nil, errShort
+			}
+			if len(stack) == 0 {
+				continue
+			}
+			v, err := parseValue(ext[4:])
+			if err != nil {
+				return nil, err
+			}
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, &Element{Text: str(le.Uint32(ext)), Line: line, Comment: comment, textValue: v})
+		}
+	}
+	if x.Root == nil {
+		return nil, errors.New("res: xml has no root element")
+	}
+	return x, nil
+}
+
+// xmlStrings builds the string pool for Marshal. Attribute names with a resource id have to come
+// first, at the same index as their id in the resource map.
+type xmlStrings struct {
+	pool   *StringPool
+	resIDs []uint32
+	index  map[string]uint32
+	named  map[Attr]uint32 // (name, id) -> index, only Name and ID are set
+}
+
+func (x *XML) newStrings() *xmlStrings {
+	s := &xmlStrings{pool: &StringPool{}, index: map[string]uint32{}, named: map[Attr]uint32{}}
+	var old []string
+	if x.pool != nil {
+		s.pool.UTF8 = x.pool.UTF8
+		old = x.pool.Strings
+	}
+	// 收集新增的带资源id的属性名, 插入到资源表的末尾
+	var added []Attr
+	have := map[Attr]bool{}
+	for i, id := range x.resIDs {
+		if i < len(old) {
+			have[Attr{Name: old[i], ID: id}] = true
+		}
+	}
+	x.Root.walk(func(e *Element) {
+		for _, a := range e.Attrs {
+			k := Attr{Name: a.Name, ID: a.ID}
+			if a.ID != 0 && !have[k] {
+				have[k] = true
+				added = append(added, k)
+			}
+		}
+	})
+	sort.Slice(added, func(i, j int) bool { return added[i].ID < added[j].ID })
+	n := len(x.resIDs)
+	if n > len(old) {
+		n = len(old)
+	}
+	for i := 0; i < n; i++ {
+		s.addNamed(old[i], x.resIDs[i])
+	}
+	for _, k := range added {
+		s.addNamed(k.Name, k.ID)
+	}
+	for _, str := range old[n:] {
+		s.pool.Strings = append(s.pool.Strings, str)
+		if _, ok := s.index[str]; !ok {
+			s.index[str] = uint32(len(s.pool.Strings) - 1)
+		}
+	}
+	return s
+}
+
+func (s *xmlStrings) addNamed(name string, id uint32) {
+	s.pool.Strings = append(s.pool.Strings, name)
+	s.resIDs = append(s.resIDs, id)
+	i := uint32(len(s.pool.Strings) - 1)
+	if _, ok := s.named[Attr{Name: name, ID: id}]; !ok {
+		s.named[Attr{Name: name, ID: id}] = i
+	}
+	if _, ok := s.index[name]; !ok {
+		s.index[name] = i
+	}
+}
+
+// ref returns the index of str, appending it to the pool if needed.
+func (s *xmlStrings) ref(str string) uint32 {
+	if i, ok := s.index[str]; ok {
+		return i
+	}
+	s.pool.Strings = append(s.pool.Strings, str)
+	i := uint32(len(s.pool.Strings) - 1)
+	s.index[str] = i
+	return i
+}
+
+// optRef is ref, with "" written as no string.
+func (s *xmlStrings) optRef(str string) uint32 {
+	if str == "" {
+		return noIndex
+	}
+	return s.ref(str)
+}
+
+// attrName returns the index of an attribute name. Names without a resource id must not land
+// inside the resource map, or the parser would give them that id.
+func (s *xmlStrings) attrName(a *Attr) uint32 {
+	if a.ID != 0 {
+		return s.named[Attr{Name: a.Name, ID: a.ID}]
+	}
+	if i, ok := s.index[a.Name]; ok && int(i) >= len(s.resIDs) {
+		return i
+	}
+	for i := len(s.resIDs); i < len(s.pool.Strings); i++ {
+		if s.pool.Strings[i] == a.Name {
+			return uint32(i)
+		}
+	}
+	s.pool.Strings = append(s.pool.Strings, a.Name)
+	return uint32(len(s.pool.Strings) - 1)
+}
+
+// Marshal encodes the document as a RES_XML_TYPE chunk.
+func (x *XML) Marshal() []byte {
+	s := x.newStrings()
+	var nodes []byte
+	node := func(typ uint16, line uint32, comment string) *chunkWriter {
+		c := newChunk(typ, xmlNodeHeaderLen)
+		h := c.header()
+		binary.LittleEndian.PutUint32(h, line)
+		binary.LittleEndian.PutUint32(h[4:], s.optRef(comment))
+		return c
+	}
+	var write func(e *Element)
+	write = func(e *Element) {
+		if e.Name == "" {
+			c := node(chunkXMLCData, e.Line, e.Comment)
+			c.u32(s.ref(e.Text))
+			c.buf = appendValue(c.buf, e.textValue)
+			nodes = append(nodes, c.bytes()...)
+			return
+		}
+		for _, ns := range e.Namespaces {
+			c := node(chunkXMLStartNS, e.Line, "")
+			c.u32(s.optRef(ns.Prefix))
+			c.u32(s.ref(ns.URI))
+			nodes = append(nodes, c.bytes()...)
+		}
+		c := node(chunkXMLStartElement, e.Line, e.Comment)
+		c.u32(s.optRef(e.Namespace))
+		c.u32(s.ref(e.Name))
+		c.u16(attrExtLen)
+		c.u16(xmlAttrLen)
+		c.u16(uint16(len(e.Attrs)))
+		var special [3]uint16 // id, class, style
+		for i, a := range e.Attrs {
+			if a.Namespace != "" {
+				continue
+			}
+			switch a.Name {
+			case "id":
+				special[0] = uint16(i + 1)
+			case "class":
+				special[1] = uint16(i + 1)
+			case "style":
+				special[2] = uint16(i + 1)
+			}
+		}
+		c.u16(special[0])
+		c.u16(special[1])
+		c.u16(special[2])
+		for _, a := range e.Attrs {
+			c.u32(s.optRef(a.Namespace))
+			c.u32(s.attrName(a))
+			v := a.Value
+			raw := uint32(noIndex)
+			if v.Type == TypeString {
+				v.Data = s.ref(a.Raw)
+				raw = v.Data
+			} else if a.hasRaw {
+				raw = s.ref(a.Raw)
+			}
+			c.u32(raw)
+			c.buf = appendValue(c.buf, v)
+		}
+		nodes = append(nodes, c.bytes()...)
+		for _, child := range e.Children {
+			write(child)
+		}
+		c = node(chunkXMLEndElement, e.Line, "")
+		c.u32(s.optRef(e.Namespace))
+		c.u32(s.ref(e.Name))
+		nodes = append(nodes, c.bytes()...)
+		for i := len(e.Namespaces) - 1; i >= 0; i-- {
+			ns := e.Namespaces[i]
+			c := node(chunkXMLEndNS, e.Line, "")
+			c.u32(s.optRef(ns.Prefix))
+			c.u32(s.ref(ns.URI))
+			nodes = append(nodes, c.bytes()...)
+		}
+	}
+	write(x.Root)
+
+	c := newChunk(chunkXML, xmlHeaderLen)
+	c.write(s.pool.Marshal())
+	rm := newChunk(chunkXMLResourceMap, chunkHeaderLen)
+	for _, id := range s.resIDs {
+		rm.u32(id)
+	}
+	c.write(rm.bytes(), nodes)
+	return c.bytes()
+}
+
+// walk calls fn for e and all of its descendant elements, depth first.
+func (e *Element) walk(fn func(*Element)) {
+	if e.Name == "" {
+		return
+	}
+	fn(e)
+	for _, c := range e.Children {
+		c.walk(fn)
+	}
+}
+
+// Walk calls fn for every element of the document, depth first.
+func (x *XML) Walk(fn func(*Element)) {
+	x.Root.walk(fn)
+}
+
+// Attr returns the attribute ns:name, or nil.
+func (e *Element) Attr(ns, name string) *Attr {
+	for _, a := range e.Attrs {
+		if a.Namespace == ns && a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// AndroidAttr returns the android: attribute with resource id, or nil.
+func (e *Element) AndroidAttr(id uint32) *Attr {
+	for _, a := range e.Attrs {
+		if a.ID == id {
+			return a
+		}
+	}
+	return nil
+}
+
+// SetAttr sets the value of a, replacing an attribute with the same namespace and name (or
+// resource id) or inserting it in resource id order, as aapt2 does. It returns the attribute
+// now on e.
+func (e *Element) SetAttr(a *Attr) *Attr {
+	for i, old := range e.Attrs {
+		if a.ID != 0 && old.ID == a.ID || old.Namespace == a.Namespace && old.Name == a.Name {
+			e.Attrs[i] = a
+			return a
+		}
+	}
+	i := sort.Search(len(e.Attrs), func(i int) bool {
+		id := e.Attrs[i].ID
+		if a.ID == 0 {
+			return false
+		}
+		return id == 0 || id > a.ID
+	})
+	e.Attrs = append(e.Attrs, nil)
+	copy(e.Attrs[i+1:], e.Attrs[i:])
+	e.Attrs[i] = a
+	return a
+}
+
+// RemoveAttr removes the attribute ns:name and reports whether it was there.
+func (e *Element) RemoveAttr(ns, name string) bool {
+	for i, a := range e.Attrs {
+		if a.Namespace == ns && a.Name == name {
+			e.Attrs = append(e.Attrs[:i], e.Attrs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Child returns the first child element with the given name, or nil.
+func (e *Element) Child(name string) *Element {
+	for _, c := range e.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// ChildrenNamed returns the child elements with the given name.
+func (e *Element) ChildrenNamed(name string) []*Element {
+	var out []*Element
+	for _, c := range e.Children {
+		if c.Name == name {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// NewElement returns an element without a namespace holding attrs.
+func NewElement(name string, attrs ...*Attr) *Element {
+	e := &Element{Name: name}
+	for _, a := range attrs {
+		e.SetAttr(a)
+	}
+	return e
+}
+
+// InsertChild inserts c as the i-th child of e. The line number is taken from the child it
+// follows, or from e, so that parser errors still point somewhere sensible.
+func (e *Element) InsertChild(i int, c *Element) {
+	if c.Line == 0 {
+		c.Line = e.Line
+		if i > 0 {
+			c.Line = e.Children[i-1].Line
+		}
+	}
+	e.Children = append(e.Children, nil)
+	copy(e.Children[i+1:], e.Children[i:])
+	e.Children[i] = c
+}
+
+// AppendChild inserts c after the last child named after, or at the end when there is none.
+func (e *Element) AppendChild(after string, c *Element) {
+	i := len(e.Children)
+	for j := len(e.Children) - 1; j >= 0; j-- {
+		if e.Children[j].Name == after {
+			i = j + 1
+			break
+		}
+	}
+	e.InsertChild(i, c)
+}
+
+// RemoveChild removes c from the children of e and reports whether it was there.
+func (e *Element) RemoveChild(c *Element) bool {
+	for i, child := range e.Children {
+		if child == c {
+			e.Children = append(e.Children[:i], e.Children[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// StringAttr returns an attribute holding the string s.
+func StringAttr(ns, name string, id uint32, s string) *Attr {
+	return &Attr{Namespace: ns, Name: name, ID: id, Raw: s, Value: Value{Type: TypeString}, hasRaw: true}
+}
+
+// ValueAttr returns an attribute holding the typed value v.
+func ValueAttr(ns, name string, id uint32, v Value) *Attr {
+	return &Attr{Namespace: ns, Name: name, ID: id, Value: v}
+}
+
+// String returns the string value of a, or its typed value formatted as text.
+func (a *Attr) String() string {
+	if a.Value.Type == TypeString {
+		return a.Raw
+	}
+	return a.Value.Format(nil)
+}
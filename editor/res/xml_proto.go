@@ -0,0 +1,53 @@
+package res
+
+// MarshalProto encodes x as an aapt2 XmlNode message (Resources.proto), the format of
+// AndroidManifest.xml and the XML resources in an Android App Bundle module. As in aapt2,
+// string attributes only carry their text and other attributes also a compiled item.
+func (x *XML) MarshalProto() []byte {
+	return x.Root.proto()
+}
+
+func (e *Element) proto() pb {
+	var b pb
+	if e.Name == "" {
+		// CDATA
+		b.bytesAlways(2, []byte(e.Text))
+	} else {
+		var el pb
+		for _, ns := range e.Namespaces {
+			var n pb
+			n.string(1, ns.Prefix)
+			n.string(2, ns.URI)
+			el.message(1, n)
+		}
+		el.string(2, e.Namespace)
+		el.string(3, e.Name)
+		for _, a := range e.Attrs {
+			el.message(4, a.proto())
+		}
+		for _, c := range e.Children {
+			el.message(5, c.proto())
+		}
+		b.message(1, el)
+	}
+	if e.Line != 0 {
+		var pos pb
+		pos.uint32(1, e.Line)
+		b.message(3, pos)
+	}
+	return b
+}
+
+func (a *Attr) proto() pb {
+	var b pb
+	b.string(1, a.Namespace)
+	b.string(2, a.Name)
+	if a.Value.Type == TypeString || a.hasRaw {
+		b.string(3, a.Raw)
+	}
+	b.uint32(5, a.ID)
+	if a.Value.Type != TypeString {
+		b.message(6, itemProto(a.Value))
+	}
+	return b
+}
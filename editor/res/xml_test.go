@@ -0,0 +1,226 @@
+package res
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestXMLRoundTrip(t *testing.T) {
+	raw := readTemplateEntry(t, "AndroidManifest.xml")
+	x, err := ParseXML(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := x.Marshal(); !bytes.Equal(out, raw) {
+		t.Fatalf("marshal changed the document: %d bytes, want %d", len(out), len(raw))
+	}
+}
+
+func TestXMLEdit(t *testing.T) {
+	x, err := ParseXML(readTemplateEntry(t, "AndroidManifest.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := x.Root.Child("application")
+	app.SetAttr(ValueAttr(AndroidNS, "debuggable", 0x0101000f, Bool(true)))
+	app.SetAttr(StringAttr(AndroidNS, "label", AttrLabel, "New"))
+	app.SetAttr(StringAttr("", "custom", 0, "x"))
+	if !app.RemoveAttr(AndroidNS, "allowBackup") {
+		t.Error("allowBackup not removed")
+	}
+	x, err = ParseXML(x.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	app = x.Root.Child("application")
+	var ids []uint32
+	for _, a := range app.Attrs {
+		ids = append(ids, a.ID)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] != 0 && ids[i] < ids[i-1] || ids[i-1] == 0 && ids[i] != 0 {
+			t.Errorf("attributes not sorted by id: %x", ids)
+		}
+	}
+	if a := app.AndroidAttr(0x0101000f); a == nil || a.Name != "debuggable" || a.Value != Bool(true) {
+		t.Errorf("debuggable %+v", a)
+	}
+	if a := app.AndroidAttr(AttrLabel); a == nil || a.Raw != "New" {
+		t.Errorf("label %+v", a)
+	}
+	if a := app.Attr("", "custom"); a == nil || a.ID != 0 || a.Raw != "x" {
+		t.Errorf("custom %+v", a)
+	}
+	if app.AndroidAttr(AttrAllowBackup) != nil {
+		t.Error("allowBackup still there")
+	}
+	if a := x.Root.Attr("", "package"); a == nil || a.ID != 0 {
+		t.Errorf("package %+v", a)
+	}
+}
+
+func TestXMLMarshalParse(t *testing.T) {
+	android := []Namespace{{"android", AndroidNS}}
+	for _, tt := range []struct {
+		name string
+		root *Element
+	}{
+		{"empty root", NewElement("manifest")},
+		{"plain attrs", NewElement("manifest", StringAttr("", "package", 0, "com.example"), StringAttr("", "empty", 0, ""))},
+		{"android attrs", &Element{Name: "manifest", Namespaces: android, Attrs: []*Attr{
+			ValueAttr(AndroidNS, "versionCode", AttrVersionCode, Int(7)),
+			StringAttr(AndroidNS, "versionName", AttrVersionName, "1.0"),
+			StringAttr("", "package", 0, "com.example"),
+		}}},
+		{"typed values", &Element{Name: "application", Namespaces: android, Attrs: []*Attr{
+			StringAttr(AndroidNS, "label", AttrLabel, "名称"),
+			ValueAttr(AndroidNS, "debuggable", 0x0101000f, Bool(true)),
+			ValueAttr(AndroidNS, "icon", 0x01010002, Reference(0x7f010000)),
+		}}},
+		{"children and text", &Element{Name: "manifest", Children: []*Element{
+			NewElement("uses-permission", StringAttr("", "name", 0, "a")),
+			{Name: "application", Children: []*Element{{Text: "hello"}, NewElement("activity")}},
+		}}},
+		{"special attrs", NewElement("view", StringAttr("", "id", 0, "x"), StringAttr("", "class", 0, "y"), StringAttr("", "style", 0, "z"))},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			x, err := ParseXML((&XML{Root: tt.root}).Marshal())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := dumpElement(x.Root), dumpElement(tt.root); got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+			// 解析后的文档再次编码不变
+			b := x.Marshal()
+			y, err := ParseXML(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(y.Marshal(), b) {
+				t.Error("marshal is not stable")
+			}
+		})
+	}
+}
+
+func TestParseXMLErrors(t *testing.T) {
+	doc := (&XML{Root: &Element{Name: "manifest", Children: []*Element{NewElement("application")}}}).Marshal()
+	header, chunks := splitChunks(t, doc)
+	without := func(types ...uint16) []byte {
+		var keep [][]byte
+	next:
+		for _, c := range chunks {
+			for _, typ := range types {
+				if binary.LittleEndian.Uint16(c) == typ {
+					continue next
+				}
+			}
+			keep = append(keep, c)
+		}
+		return joinChunks(header, keep...)
+	}
+	var starts, ends [][]byte
+	for _, c := range chunks {
+		switch binary.LittleEndian.Uint16(c) {
+		case chunkXMLStartElement:
+			starts = append(starts, c)
+		case chunkXMLEndElement:
+			ends = append(ends, c)
+		}
+	}
+	manyAttrs := append([]byte(nil), starts[0]...)
+	binary.LittleEndian.PutUint16(manyAttrs[xmlNodeHeaderLen+12:], 100)
+	shortNode := append([]byte(nil), starts[0][:chunkHeaderLen]...)
+	binary.LittleEndian.PutUint16(shortNode[2:], chunkHeaderLen)
+	binary.LittleEndian.PutUint32(shortNode[4:], chunkHeaderLen)
+	unknown := make([]byte, chunkHeaderLen)
+	binary.LittleEndian.PutUint16(unknown, 0x0200)
+	binary.LittleEndian.PutUint16(unknown[2:], chunkHeaderLen)
+	binary.LittleEndian.PutUint32(unknown[4:], chunkHeaderLen)
+	pool := chunks[0]
+
+	for _, tt := range []struct {
+		name string
+		b    []byte
+		err  string // 为空时应当解析成功
+	}{
+		{"empty", nil, "truncated"},
+		{"short header", doc[:4], "truncated"},
+		{"not xml", pool, "not a binary xml"},
+		{"size beyond data", doc[:len(doc)-1], "bad chunk"},
+		{"size below header", []byte{3, 0, 8, 0, 4, 0, 0, 0}, "bad chunk"},
+		{"no root", without(chunkXMLStartElement, chunkXMLEndElement), "no root element"},
+		{"end without start", joinChunks(header, pool, ends[0]), "unbalanced"},
+		{"two roots", joinChunks(header, append(chunks, starts[0], ends[0])...), "more than one root"},
+		{"attrs beyond chunk", joinChunks(header, pool, manyAttrs), "truncated"},
+		{"short node", joinChunks(header, pool, shortNode), "truncated"},
+		{"unknown chunk", joinChunks(header, append([][]byte{unknown}, chunks...)...), ""},
+		{"trailing bytes", append(append([]byte(nil), doc...), 0, 0), ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseXML(tt.b)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// splitChunks 返回chunk的头部和其中的各个子chunk
+func splitChunks(t *testing.T, doc []byte) ([]byte, [][]byte) {
+	t.Helper()
+	h, b, _, err := readChunk(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks [][]byte
+	for rest := b[h.HeaderSize:]; len(rest) > 0; {
+		var c []byte
+		if _, c, rest, err = readChunk(rest); err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, c)
+	}
+	return b[:h.HeaderSize], chunks
+}
+
+// joinChunks 把子chunk拼接到头部之后并更新大小
+func joinChunks(header []byte, chunks ...[]byte) []byte {
+	b := append([]byte(nil), header...)
+	for _, c := range chunks {
+		b = append(b, c...)
+	}
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)))
+	return b
+}
+
+// dumpElement 以文本形式输出元素, 用于比较
+func dumpElement(e *Element) string {
+	var sb strings.Builder
+	var dump func(e *Element, indent string)
+	dump = func(e *Element, indent string) {
+		if e.Name == "" {
+			fmt.Fprintf(&sb, "%s%q\n", indent, e.Text)
+			return
+		}
+		fmt.Fprintf(&sb, "%s<%s %s> %v\n", indent, e.Namespace, e.Name, e.Namespaces)
+		for _, a := range e.Attrs {
+			fmt.Fprintf(&sb, "%s  %s:%s 0x%08x %q %v\n", indent, a.Namespace, a.Name, a.ID, a.String(), a.Value.Type)
+		}
+		for _, c := range e.Children {
+			dump(c, indent+"    ")
+		}
+	}
+	dump(e, "")
+	return sb.String()
+}
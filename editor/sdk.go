@@ -0,0 +1,229 @@
+package editor
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+	"github.com/pzx521521/apk-editor/editor/zip"
+)
+
+// dexVersions dex文件版本需要的最低SDK, 见 art/libdexfile/dex/dex_file.h
+var dexVersions = map[string]int{
+	"035": 1,
+	"037": 24,
+	"038": 26,
+	"039": 28,
+	"040": 35,
+}
+
+// dexMinSdk 返回apk中所有 classes*.dex 需要的最低SDK
+func dexMinSdk(r *zip.Reader) (int, error) {
+	min := 1
+	for _, f := range r.File {
+		if !strings.HasPrefix(f.Name, "classes") || !strings.HasSuffix(f.Name, ".dex") || strings.Contains(f.Name, "/") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return 0, err
+		}
+		var magic [8]byte
+		_, err = io.ReadFull(rc, magic[:])
+		rc.Close()
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		if string(magic[:4]) != "dex\n" {
+			return 0, fmt.Errorf("%s: not a dex file", f.Name)
+		}
+		sdk, ok := dexVersions[string(magic[4:7])]
+		if !ok {
+			return 0, fmt.Errorf("%s: unknown dex version %q", f.Name, magic[4:7])
+		}
+		if sdk > min {
+			min = sdk
+		}
+	}
+	return min, nil
+}
+
+// sdkBehavior 是 targetSdkVersion 达到 SDK 后改变的运行时行为, check 返回需要提醒的内容
+type sdkBehavior struct {
+	SDK   int
+	check func(m *AndroidManifest) string
+}
+
+var sdkBehaviors = []sdkBehavior{
+	{28, func(m *AndroidManifest) string {
+		app := m.Application
+		if _, ok := app.Attributes["usesCleartextTraffic"]; ok || app.NetworkSecurityConfig != "" {
+			return ""
+		}
+		return "cleartext http traffic is blocked by default, set usesCleartextTraffic or a network security config"
+	}},
+	{29, func(m *AndroidManifest) string {
+		for _, p := range m.Permissions {
+			if p.Name == "android.permission.WRITE_EXTERNAL_STORAGE" {
+				return "scoped storage applies, WRITE_EXTERNAL_STORAGE no longer grants access to shared files"
+			}
+		}
+		return ""
+	}},
+	{31, func(m *AndroidManifest) string {
+		var missing []string
+		app := m.Application
+		for _, list := range [][]Component{app.Activities, app.Services, app.Receivers, app.Providers} {
+			for _, c := range list {
+				if c.Exported == nil && len(c.IntentFilters) > 0 {
+					missing = append(missing, c.Name)
+				}
+			}
+		}
+		if len(missing) == 0 {
+			return ""
+		}
+		return "components with intent filters must set android:exported or the install fails: " + strings.Join(missing, ", ")
+	}},
+	{33, func(m *AndroidManifest) string {
+		return "notifications need the POST_NOTIFICATIONS runtime permission"
+	}},
+	{34, func(m *AndroidManifest) string {
+		if len(m.Application.Services) == 0 {
+			return ""
+		}
+		return "foreground services must declare android:foregroundServiceType"
+	}},
+}
+
+// checkSdk 检查 m 中的SDK版本能否用于模板: minSdkVersion 不能低于dex需要的版本, 也不能高于 targetSdkVersion.
+// 返回提醒: 模板资源在新的 minSdkVersion 下不可用. 运行时行为的提醒由 sdkBehaviorWarnings 在修改后检查
+func (m *Manifest) checkSdk(x *res.XML, r *zip.Reader, table *res.Table) ([]string, error) {
+	cur := newAndroidManifest(x, table)
+	minSdk, targetSdk := cur.MinSdkVersion, cur.TargetSdkVersion
+	if minSdk == 0 {
+		minSdk = 1
+	}
+	if targetSdk == 0 {
+		targetSdk = minSdk
+	}
+	if m.MinSdkVersion != 0 {
+		minSdk = m.MinSdkVersion
+	}
+	if m.TargetSdkVersion != 0 {
+		targetSdk = m.TargetSdkVersion
+	}
+	if minSdk < 1 || targetSdk < 1 {
+		return nil, fmt.Errorf("sdk: versions must be positive, got min %d target %d", minSdk, targetSdk)
+	}
+	if targetSdk < minSdk {
+		return nil, fmt.Errorf("sdk: targetSdkVersion %d is lower than minSdkVersion %d", targetSdk, minSdk)
+	}
+	if cur.MaxSdkVersion != 0 && targetSdk > cur.MaxSdkVersion {
+		return nil, fmt.Errorf("sdk: targetSdkVersion %d is higher than maxSdkVersion %d", targetSdk, cur.MaxSdkVersion)
+	}
+	dexSdk, err := dexMinSdk(r)
+	if err != nil {
+		return nil, err
+	}
+	if minSdk < dexSdk {
+		return nil, fmt.Errorf("sdk: minSdkVersion %d is lower than %d required by the template's dex files", minSdk, dexSdk)
+	}
+
+	var warnings []string
+	if cur.CompileSdkVersion != 0 && targetSdk > cur.CompileSdkVersion {
+		warnings = append(warnings, fmt.Sprintf("targetSdkVersion %d is higher than the template's compileSdkVersion %d", targetSdk, cur.CompileSdkVersion))
+	}
+	if table != nil && m.MinSdkVersion != 0 && m.MinSdkVersion < cur.MinSdkVersion {
+		warnings = append(warnings, resourceSdkWarnings(x, table, minSdk)...)
+	}
+	return warnings, nil
+}
+
+// sdkBehaviorWarnings 返回 targetSdkVersion 从 before 提高到 after 后改变的运行时行为.
+// after 是所有修改之后的manifest, 其中的 usesCleartextTraffic, exported 等已经是最终的值
+func sdkBehaviorWarnings(before, after *AndroidManifest) []string {
+	var warnings []string
+	for _, b := range sdkBehaviors {
+		if effectiveTargetSdk(before) < b.SDK && effectiveTargetSdk(after) >= b.SDK {
+			if msg := b.check(after); msg != "" {
+				warnings = append(warnings, "targetSdkVersion "+strconv.Itoa(b.SDK)+": "+msg)
+			}
+		}
+	}
+	return warnings
+}
+
+// effectiveTargetSdk 返回生效的 targetSdkVersion, 没有设置时与 minSdkVersion 相同
+func effectiveTargetSdk(m *AndroidManifest) int {
+	if m.TargetSdkVersion != 0 {
+		return m.TargetSdkVersion
+	}
+	if m.MinSdkVersion != 0 {
+		return m.MinSdkVersion
+	}
+	return 1
+}
+
+// resourceSdkWarnings 返回manifest引用的资源中, 在 minSdk 上没有可用配置的资源
+func resourceSdkWarnings(x *res.XML, table *res.Table, minSdk int) []string {
+	var warnings []string
+	seen := map[uint32]bool{}
+	x.Walk(func(e *res.Element) {
+		for _, a := range e.Attrs {
+			if a.Value.Type != res.TypeReference || a.Value.Data>>24 != 0x7f || seen[a.Value.Data] {
+				continue
+			}
+			seen[a.Value.Data] = true
+			entries := table.Entries(a.Value.Data)
+			if len(entries) == 0 {
+				continue
+			}
+			lowest := int(entries[0].Config.SDKVersion())
+			for _, ce := range entries[1:] {
+				if sdk := int(ce.Config.SDKVersion()); sdk < lowest {
+					lowest = sdk
+				}
+			}
+			if lowest > minSdk {
+				name, _ := table.Name(a.Value.Data)
+				warnings = append(warnings, fmt.Sprintf("@%s used by <%s %s> is only available from API %d", name, e.Name, a.Name, lowest))
+			}
+		}
+	})
+	return warnings
+}
+
+// applySdk 修改 <uses-sdk>, 没有时添加
+func (m *Manifest) applySdk(root *res.Element) []ManifestChange {
+	if m.MinSdkVersion == 0 && m.TargetSdkVersion == 0 {
+		return nil
+	}
+	var changes []ManifestChange
+	sdk := root.Child("uses-sdk")
+	if sdk == nil {
+		sdk = res.NewElement("uses-sdk")
+		insertTopLevel(root, sdk)
+	}
+	for _, f := range []struct {
+		name  string
+		id    uint32
+		value int
+	}{
+		{"minSdkVersion", res.AttrMinSdkVersion, m.MinSdkVersion},
+		{"targetSdkVersion", res.AttrTargetSdkVersion, m.TargetSdkVersion},
+	} {
+		if f.value == 0 {
+			continue
+		}
+		old := sdk.AndroidAttr(f.id)
+		if old != nil && old.Value == res.Int(int32(f.value)) {
+			continue
+		}
+		sdk.SetAttr(res.ValueAttr(res.AndroidNS, f.name, f.id, res.Int(int32(f.value))))
+		changes = append(changes, ManifestChange{f.name, attrString(old), strconv.Itoa(f.value)})
+	}
+	return changes
+}
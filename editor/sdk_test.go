@@ -0,0 +1,80 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEditSdk(t *testing.T) {
+	a := newTestEditor(t)
+	a.Url = "https://example.com"
+	a.Manifest = &Manifest{MinSdkVersion: 21}
+	if _, err := a.Edit(); err == nil || !strings.Contains(err.Error(), "dex") {
+		t.Errorf("minSdkVersion below the dex version: %v", err)
+	}
+	a.Manifest = &Manifest{MinSdkVersion: 30, TargetSdkVersion: 29}
+	if _, err := a.Edit(); err == nil {
+		t.Error("targetSdkVersion below minSdkVersion accepted")
+	}
+
+	a.Manifest = &Manifest{MinSdkVersion: 26, TargetSdkVersion: 34}
+	out := mustEdit(t, a)
+	if len(a.ManifestChanges) != 2 {
+		t.Errorf("changes %v", a.ManifestChanges)
+	}
+	// 模板编译于31, 提高到34会提醒 compileSdkVersion 和通知权限
+	warnings := strings.Join(a.Warnings, "\n")
+	if !strings.Contains(warnings, "compileSdkVersion") || !strings.Contains(warnings, "POST_NOTIFICATIONS") {
+		t.Errorf("warnings %q", warnings)
+	}
+	m := manifestOf(t, out)
+	if m.MinSdkVersion != 26 || m.TargetSdkVersion != 34 {
+		t.Errorf("sdk %d %d", m.MinSdkVersion, m.TargetSdkVersion)
+	}
+}
+
+func TestEditSdkBehaviorsAfterApply(t *testing.T) {
+	manifestXML := []byte(`<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.parap.webview">
+	<uses-sdk android:minSdkVersion="24" android:targetSdkVersion="27"/>
+	<application android:label="demo">
+		<activity android:name=".MainActivity">
+			<intent-filter>
+				<action android:name="android.intent.action.MAIN"/>
+				<category android:name="android.intent.category.LAUNCHER"/>
+			</intent-filter>
+		</activity>
+	</application>
+</manifest>`)
+	cleartext := true
+	for _, tt := range []struct {
+		name     string
+		manifest *Manifest
+		want     []string
+		absent   []string
+	}{
+		{"template values", &Manifest{TargetSdkVersion: 31},
+			[]string{"cleartext", "android:exported"}, nil},
+		// usesCleartextTraffic 和深度链接设置的 exported 在检查前已经生效
+		{"edited values", &Manifest{TargetSdkVersion: 31, UsesCleartextTraffic: &cleartext, DeepLinks: []DeepLink{{Scheme: "myapp"}}},
+			nil, []string{"cleartext", "android:exported"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestEditor(t)
+			a.Url = "https://example.com"
+			a.ManifestXML = manifestXML
+			a.Manifest = tt.manifest
+			mustEdit(t, a)
+			warnings := strings.Join(a.Warnings, "\n")
+			for _, s := range tt.want {
+				if !strings.Contains(warnings, s) {
+					t.Errorf("warnings %q, want %q", warnings, s)
+				}
+			}
+			for _, s := range tt.absent {
+				if strings.Contains(warnings, s) {
+					t.Errorf("warnings %q, want no %q", warnings, s)
+				}
+			}
+		})
+	}
+}
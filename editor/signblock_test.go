@@ -0,0 +1,119 @@
+package editor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pzx521521/apk-editor/editor/signv2"
+)
+
+func TestSigningBlockPairs(t *testing.T) {
+	a := newTestEditor(t)
+	a.Url = "https://example.com"
+	apk := mustEdit(t, a)
+	z, err := signv2.NewApkSign(apk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairs := z.SigningBlock()
+	if len(pairs) != 2 || pairs[0].ID != signv2.V2BlockID || pairs[1].ID != signv2.PaddingBlockID {
+		t.Fatalf("pairs %v", pairs)
+	}
+	// 重新生成的签名块与apk中的相同, 大小是4096的倍数
+	block := signv2.MarshalSigningBlock(pairs)
+	if len(block)%4096 != 0 || !bytes.Contains(apk, block) {
+		t.Errorf("signing block of %d bytes not round-tripped", len(block))
+	}
+
+	// 渠道信息和失效的v3签名
+	channel := signv2.BlockPair{ID: 0x71777777, Value: []byte(`{"channel":"test"}`)}
+	v3 := signv2.BlockPair{ID: signv2.V3BlockID, Value: []byte("stale")}
+	apk = z.InjectBeforeCD(signv2.MarshalSigningBlock([]signv2.BlockPair{pairs[0], v3, channel}))
+	if z, err = signv2.NewApkSign(apk); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.VerifyV2(); err != nil {
+		t.Fatal(err)
+	}
+	_, key, crt := templateFiles(t)
+	keys, err := signingKeys(key, crt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resigned, err := z.SignV2(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if z, err = signv2.NewApkSign(resigned); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.VerifyV2(); err != nil {
+		t.Fatal(err)
+	}
+	pairs = z.SigningBlock()
+	if len(pairs) != 3 || pairs[0].ID != signv2.V2BlockID || pairs[1].ID != channel.ID || !bytes.Equal(pairs[1].Value, channel.Value) || pairs[2].ID != signv2.PaddingBlockID {
+		t.Errorf("pairs after re-signing %v", pairs)
+	}
+}
+
+func TestMarshalSigningBlockPadding(t *testing.T) {
+	// 剩余空间不够一个填充对(12字节)时再填充4096字节
+	block := signv2.MarshalSigningBlock([]signv2.BlockPair{{ID: 1, Value: make([]byte, 4096-32-12-5)}})
+	if len(block) != 8192 {
+		t.Fatalf("block size %d", len(block))
+	}
+	pairs, err := signv2.ParseSigningBlockPairs(block[8 : len(block)-24])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pairs) != 2 || pairs[1].ID != signv2.PaddingBlockID || len(pairs[1].Value) != 4096+5-12 {
+		t.Errorf("padding %v", pairs)
+	}
+	// 两个v2签名块是错误
+	v2 := signv2.BlockPair{ID: signv2.V2BlockID, Value: []byte{0, 0, 0, 0}}
+	if _, err := signv2.ParseV2Block(append(v2.Marshal(), v2.Marshal()...)); err == nil {
+		t.Error("duplicate v2 blocks accepted")
+	}
+}
+
+func TestParseSigningBlockPairs(t *testing.T) {
+	v2 := signv2.BlockPair{ID: signv2.V2BlockID, Value: []byte("v2")}
+	empty := signv2.BlockPair{ID: 0x71777777, Value: []byte{}}
+	pairs := append(v2.Marshal(), empty.Marshal()...)
+	for _, tt := range []struct {
+		name string
+		b    []byte
+		want []signv2.BlockPair
+		err  string // 为空时应当解析成功
+	}{
+		{"no pairs", nil, nil, ""},
+		{"pairs in order", pairs, []signv2.BlockPair{v2, empty}, ""},
+		{"short pair", pairs[:11], nil, "short ID-value pair"},
+		{"trailing bytes", append(bytes.Clone(pairs), 1, 2, 3), nil, "short ID-value pair"},
+		{"length beyond block", pairs[:len(v2.Marshal())-1], nil, "bad ID-value pair length"},
+		{"length without id", []byte{3, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4}, nil, "bad ID-value pair length"},
+		{"huge length", []byte{0, 0, 0, 0, 0, 0, 0, 0x80, 1, 2, 3, 4}, nil, "bad ID-value pair length"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := signv2.ParseSigningBlockPairs(tt.b)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("err %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("pairs %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].ID != tt.want[i].ID || !bytes.Equal(got[i].Value, tt.want[i].Value) {
+					t.Errorf("pair %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -26,6 +26,7 @@ type ApkSign struct {
 	cdOffset   uint64
 	asv2Offset uint64
 	rawASv2    []byte
+	pairs      []BlockPair
 }
 
 // NewZip attempts to parse its input as a ApkSign file, determining along the way whether the input is
@@ -56,13 +57,16 @@ func NewApkSign(buf []byte) (*ApkSign, error) {
 
 	var b []byte
 	var start int64
-	for i := uint32(0); i < 65535; i++ {
+	// the comment is at most 65535 bytes long, and the EOCD cannot start before the file does
+	maxComment := min(z.size-22, 65535)
+	var adjacencyErr error
+	for i := int64(0); i <= maxComment; i++ {
 		// The "end of central directory" block has 22 bytes of fixed headers, followed by a variable
 		// length comment, whose length is stored in the final 16 bits of the EOCD block. This means
 		// that we can't just look at EOF - 22 for the EOCD magic identifier, we have to read backward
 		// to accommodate a possible zip file comment.
 
-		start = z.size - 22 - int64(i)
+		start = z.size - 22 - i
 		b = z.raw[start : start+22]
 
 		// check for the EOCD magic string, 0x06054b50. note that zip files are little endian
@@ -73,7 +77,7 @@ func NewApkSign(buf []byte) (*ApkSign, error) {
 			// verification requirement:
 			// Spec: "verify that ... ZIP End of Central Directory is not followed by more data"
 			commentLen := binary.LittleEndian.Uint16(b[20:22])
-			if uint16(i) != commentLen {
+			if int64(commentLen) != i {
 				continue // can't be the EOCD; keep going
 			}
 
@@ -81,14 +85,19 @@ func NewApkSign(buf []byte) (*ApkSign, error) {
 			candidateEOCD := uint64(z.size) - 22 - uint64(i)
 			eocdCD := binary.LittleEndian.Uint32(b[16:20])
 			eocdCDLen := binary.LittleEndian.Uint32(b[12:16])
+			if uint64(eocdCD)+4 > candidateEOCD {
+				continue // CD offset points past the "EOCD", so this is comment bytes
+			}
 			b2 := z.raw[int64(eocdCD):]
 			if binary.LittleEndian.Uint32(b2) != 0x02014b50 {
 				continue // CD pointed to by "EOCD" is not a valid CD, but there may still be comment bytes to unwind
 			}
 
-			// Spec: "verify that ... ZIP Central Directory is immediately followed by ZIP End of Central Directory record"
+			// Spec: "verify that ... ZIP Central Directory is immediately followed by ZIP End of Central Directory record".
+			// A fake EOCD inside the comment can point to the real CD too, so keep unwinding the comment
 			if uint64(eocdCD)+uint64(eocdCDLen) != candidateEOCD {
-				return nil, errors.New("CD not adjacent to EOCD")
+				adjacencyErr = errors.New("CD not adjacent to EOCD")
+				continue
 			}
 
 			// now we have an EOCD that checks out and appears to point to a CD, so we are pretty sure this is a zip file
@@ -120,9 +129,12 @@ func NewApkSign(buf []byte) (*ApkSign, error) {
 			z.IsAPK = hasClassesDex && hasAndroidManifestXML && hasResourcesARSC
 
 			// now see if there is an Android signing v2 block
+			if z.cdOffset < 32 { // no room for the size fields and magic of a signing block
+				return z, nil
+			}
 			start = int64(z.cdOffset) - 16
 			magic := z.raw[start:z.cdOffset]
-			if string(magic) != "APK Sig Block 42" {
+			if string(magic) != signingBlockMagic {
 				return z, nil
 			}
 
@@ -131,6 +143,9 @@ func NewApkSign(buf []byte) (*ApkSign, error) {
 			start = int64(z.cdOffset - 16 - 8)
 			b64 := z.raw[start : start+8]
 			postSize := binary.LittleEndian.Uint64(b64)
+			if postSize < 24 || postSize > z.cdOffset-8 {
+				return nil, errors.New("malformed signing block - bad size")
+			}
 			start = int64(z.cdOffset - postSize - 8)
 			b64 = z.raw[start : start+8]
 			preSize := binary.LittleEndian.Uint64(b64)
@@ -139,9 +154,14 @@ func NewApkSign(buf []byte) (*ApkSign, error) {
 				z.rawASv2 = make([]byte, preSize-24)
 				start = int64(z.asv2Offset + 8)
 				copy(z.rawASv2, z.raw[start:])
+				if z.pairs, err = ParseSigningBlockPairs(z.rawASv2); err != nil {
+					return nil, err
+				}
 			}
 
-			z.IsV2Signed = z.asv2Offset > 0
+			for _, p := range z.pairs {
+				z.IsV2Signed = z.IsV2Signed || p.ID == V2BlockID
+			}
 
 			log.Println("ApkSign.New", "ASv2, CD, EOCD", z.asv2Offset, z.cdOffset, z.eocdOffset)
 
@@ -150,6 +170,9 @@ func NewApkSign(buf []byte) (*ApkSign, error) {
 	}
 
 	// if we fall past the end of the loop, means we exhausted all possibility of it being a zip
+	if adjacencyErr != nil {
+		return nil, adjacencyErr
+	}
 	return nil, errors.New("input is not a zip")
 }
 
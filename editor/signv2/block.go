@@ -0,0 +1,109 @@
+package signv2
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// IDs of the ID-value pairs found in APK Signing Blocks. Only the v2 pair is produced and verified
+// here, the others are listed so that re-signing knows which pairs it invalidates.
+const (
+	V2BlockID             uint32 = 0x7109871a
+	V3BlockID             uint32 = 0xf05368c0
+	V31BlockID            uint32 = 0x1b93ad61
+	SourceStampV1BlockID  uint32 = 0x2b09189e
+	SourceStampV2BlockID  uint32 = 0x6dff800d
+	PaddingBlockID        uint32 = 0x42726577
+	DependencyInfoBlockID uint32 = 0x504b4453
+)
+
+const (
+	signingBlockMagic = "APK Sig Block 42"
+	// signingBlockAlignment is the size apksigner pads the APK Signing Block to (the common page size),
+	// so that the Central Directory following it stays page aligned.
+	signingBlockAlignment = 4096
+)
+
+// BlockPair is one ID-value pair of an APK Signing Block.
+type BlockPair struct {
+	ID    uint32
+	Value []byte
+}
+
+// ParseSigningBlockPairs parses the sequence of uint64-length-prefixed ID-value pairs of an APK
+// Signing Block, i.e. the block without its size fields and magic, keeping their order.
+func ParseSigningBlockPairs(block []byte) ([]BlockPair, error) {
+	var pairs []BlockPair
+	for len(block) > 0 {
+		if len(block) < 12 {
+			return nil, errors.New("malformed signing block - short ID-value pair")
+		}
+		var size uint64
+		size, block = pop64(block)
+		if size < 4 || size > uint64(len(block)) {
+			return nil, errors.New("malformed signing block - bad ID-value pair length")
+		}
+		var pair []byte
+		pair, block = popN(block, int(size))
+		id, value := pop32(pair)
+		pairs = append(pairs, BlockPair{id, value})
+	}
+	return pairs, nil
+}
+
+// MarshalSigningBlock returns a complete APK Signing Block (size fields and magic included) holding
+// pairs in order. Like apksigner, a padding pair with ID PaddingBlockID is appended when needed so
+// that the block size is a multiple of 4096 bytes.
+func MarshalSigningBlock(pairs []BlockPair) []byte {
+	blocks := make([][]byte, 0, len(pairs)+1)
+	size := 8 + 8 + 16 // leading size + trailing size + magic
+	for _, p := range pairs {
+		b := p.Marshal()
+		blocks = append(blocks, b)
+		size += len(b)
+	}
+	if rem := size % signingBlockAlignment; rem != 0 {
+		padding := signingBlockAlignment - rem
+		if padding < 12 { // the smallest possible ID-value pair
+			padding += signingBlockAlignment
+		}
+		pad := make([]byte, padding)
+		binary.LittleEndian.PutUint64(pad, uint64(padding-8))
+		binary.LittleEndian.PutUint32(pad[8:], PaddingBlockID)
+		blocks = append(blocks, pad)
+		size += padding
+	}
+
+	// the size fields don't count the leading size field itself
+	sizeField := make([]byte, 8)
+	binary.LittleEndian.PutUint64(sizeField, uint64(size-8))
+	return concat(append(append([][]byte{sizeField}, blocks...), sizeField, []byte(signingBlockMagic))...)
+}
+
+// Marshal returns the pair with its uint64 length prefix, as stored in the signing block.
+func (p *BlockPair) Marshal() []byte {
+	pair := push64(push32(p.Value)) // abuse push32 as a cheesy way to allocate room for the ID
+	binary.LittleEndian.PutUint32(pair[8:12], p.ID)
+	return pair
+}
+
+// preservedOnResign reports whether a pair of an existing signing block is still valid after the APK
+// is re-signed. Signatures (v2, v3, v3.1, source stamps) cover the APK digests and are replaced or
+// invalidated, padding is regenerated; all other pairs (channel info, dependency info, ...) are kept.
+func preservedOnResign(id uint32) bool {
+	switch id {
+	case V2BlockID, V3BlockID, V31BlockID, SourceStampV1BlockID, SourceStampV2BlockID, PaddingBlockID:
+		return false
+	}
+	return true
+}
+
+// SigningBlock returns the ID-value pairs of the APK Signing Block in file order, padding included.
+// It is empty when the APK has no signing block.
+func (apkSign *ApkSign) SigningBlock() []BlockPair {
+	pairs := make([]BlockPair, len(apkSign.pairs))
+	for i, p := range apkSign.pairs {
+		pairs[i] = BlockPair{p.ID, append([]byte(nil), p.Value...)}
+	}
+	return pairs
+}
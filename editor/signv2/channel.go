@@ -0,0 +1,151 @@
+package signv2
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// ChannelBlockID is the ID of the signing block pair holding the channel payload, a JSON object of
+// string keys and values. It is the ID Walle uses, so APKs written here can be read by the Walle
+// Android library (WalleChannelReader.getChannel) and vice versa.
+const ChannelBlockID uint32 = 0x71777777
+
+// ChannelKey is the payload key of the channel itself, the other keys are free-form extra info.
+const ChannelKey = "channel"
+
+// Channel returns the channel payload of the APK, or nil if it has none.
+func (apkSign *ApkSign) Channel() (map[string]string, error) {
+	return channelPayload(apkSign.pairs)
+}
+
+// SetChannel returns a copy of the APK with payload stored in its signing block, replacing any
+// previous payload. See WriteChannel.
+func (apkSign *ApkSign) SetChannel(payload map[string]string) ([]byte, error) {
+	block, err := apkSign.channelBlock(payload)
+	if err != nil {
+		return nil, err
+	}
+	return apkSign.InjectBeforeCD(block), nil
+}
+
+// WriteChannel writes the APK with payload stored in its signing block to w, replacing any previous
+// payload. The v2 signature does not cover the signing block, so the APK stays validly signed and
+// SignV2 need not run again: writing many channel variants of one signed APK only costs the copy.
+// A v4 signature covers the whole file though, and must be regenerated for each variant.
+func (apkSign *ApkSign) WriteChannel(w io.Writer, payload map[string]string) error {
+	block, err := apkSign.channelBlock(payload)
+	if err != nil {
+		return err
+	}
+	eocd := make([]byte, apkSign.size-int64(apkSign.eocdOffset))
+	copy(eocd, apkSign.raw[apkSign.eocdOffset:])
+	binary.LittleEndian.PutUint32(eocd[16:], uint32(apkSign.asv2Offset+uint64(len(block))))
+	for _, b := range [][]byte{apkSign.raw[:apkSign.asv2Offset], block, apkSign.raw[apkSign.cdOffset:apkSign.eocdOffset], eocd} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// channelBlock returns the signing block of the APK with its channel pair set to payload.
+func (apkSign *ApkSign) channelBlock(payload map[string]string) ([]byte, error) {
+	if !apkSign.IsV2Signed {
+		return nil, errors.New("channel requires a v2 signed APK")
+	}
+	value, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var pairs []BlockPair
+	for _, p := range apkSign.pairs {
+		if p.ID != ChannelBlockID && p.ID != PaddingBlockID {
+			pairs = append(pairs, p)
+		}
+	}
+	return MarshalSigningBlock(append(pairs, BlockPair{ChannelBlockID, value})), nil
+}
+
+// ReadChannel returns the channel payload of the APK read through r, or nil if it has none. Only the
+// End of Central Directory and the signing block are read, so it is cheap on large files.
+func ReadChannel(r io.ReaderAt, size int64) (map[string]string, error) {
+	pairs, err := ReadSigningBlock(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return channelPayload(pairs)
+}
+
+// ReadSigningBlock returns the ID-value pairs of the APK Signing Block of the APK read through r,
+// without reading the rest of the file. It returns nil pairs if the APK has no signing block.
+func ReadSigningBlock(r io.ReaderAt, size int64) ([]BlockPair, error) {
+	cdOffset, err := readCDOffset(r, size)
+	if err != nil {
+		return nil, err
+	}
+	if cdOffset < 32 {
+		return nil, nil
+	}
+	footer := make([]byte, 24)
+	if _, err := r.ReadAt(footer, cdOffset-24); err != nil {
+		return nil, err
+	}
+	if string(footer[8:]) != signingBlockMagic {
+		return nil, nil
+	}
+	blockSize := binary.LittleEndian.Uint64(footer)
+	if blockSize < 24 || blockSize > uint64(cdOffset-8) {
+		return nil, errors.New("malformed signing block - bad size")
+	}
+	block := make([]byte, blockSize+8)
+	if _, err := r.ReadAt(block, cdOffset-int64(blockSize)-8); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint64(block) != blockSize {
+		return nil, errors.New("malformed signing block - size fields differ")
+	}
+	return ParseSigningBlockPairs(block[8 : len(block)-24])
+}
+
+// readCDOffset returns the Central Directory offset recorded in the End of Central Directory.
+func readCDOffset(r io.ReaderAt, size int64) (int64, error) {
+	tail := min(size, 22+65535)
+	if tail < 22 {
+		return 0, errors.New("input is too small to be a zip")
+	}
+	buf := make([]byte, tail)
+	if _, err := r.ReadAt(buf, size-tail); err != nil {
+		return 0, err
+	}
+	// scan backward for the EOCD magic, whose comment length must reach the end of the file
+	for i := len(buf) - 22; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(buf[i:]) != 0x06054b50 {
+			continue
+		}
+		if int(binary.LittleEndian.Uint16(buf[i+20:])) != len(buf)-22-i {
+			continue
+		}
+		cdOffset := int64(binary.LittleEndian.Uint32(buf[i+16:]))
+		if cdOffset > size-tail+int64(i) {
+			return 0, errors.New("central directory offset past the EOCD")
+		}
+		return cdOffset, nil
+	}
+	return 0, errors.New("input is not a zip")
+}
+
+// channelPayload decodes the channel pair among pairs, nil if there is none.
+func channelPayload(pairs []BlockPair) (map[string]string, error) {
+	for _, p := range pairs {
+		if p.ID == ChannelBlockID {
+			var payload map[string]string
+			if err := json.Unmarshal(p.Value, &payload); err != nil {
+				return nil, err
+			}
+			return payload, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,158 @@
+package signv2
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// JarEntry is a file covered by a JAR signature.
+type JarEntry struct {
+	Name string
+	Data []byte
+}
+
+// JarManifest is the name of the JAR manifest.
+const JarManifest = "META-INF/MANIFEST.MF"
+
+// jarCreatedBy is written as Created-By in the manifest and signature files.
+const jarCreatedBy = "1.0 (apk-editor)"
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidSHA256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+)
+
+// SignJar signs entries with JAR signing (the v1 scheme), which is what jarsigner and bundletool
+// use for Android App Bundles. It returns META-INF/MANIFEST.MF followed by a .SF signature file and
+// a PKCS#7 .RSA signature block for every key, named CERT, CERT2, ... The entries themselves must
+// not contain META-INF/MANIFEST.MF or other signature files. All digests are SHA-256.
+func SignJar(entries []JarEntry, keys []*SigningCert) ([]JarEntry, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+	main := jarSection("Manifest-Version: 1.0", "Created-By: "+jarCreatedBy)
+	manifest := bytes.NewBuffer(main)
+	sections := make([][]byte, len(entries))
+	for i, e := range entries {
+		if strings.EqualFold(e.Name, JarManifest) {
+			return nil, fmt.Errorf("%s: entries already contain a manifest", e.Name)
+		}
+		sum := sha256.Sum256(e.Data)
+		sections[i] = jarSection("Name: "+e.Name, "SHA-256-Digest: "+base64.StdEncoding.EncodeToString(sum[:]))
+		manifest.Write(sections[i])
+	}
+	mf := manifest.Bytes()
+	mfSum, mainSum := sha256.Sum256(mf), sha256.Sum256(main)
+	sf := bytes.NewBuffer(jarSection(
+		"Signature-Version: 1.0",
+		"Created-By: "+jarCreatedBy,
+		"SHA-256-Digest-Manifest: "+base64.StdEncoding.EncodeToString(mfSum[:]),
+		"SHA-256-Digest-Manifest-Main-Attributes: "+base64.StdEncoding.EncodeToString(mainSum[:]),
+	))
+	for i, e := range entries {
+		sum := sha256.Sum256(sections[i])
+		sf.Write(jarSection("Name: "+e.Name, "SHA-256-Digest: "+base64.StdEncoding.EncodeToString(sum[:])))
+	}
+	out := []JarEntry{{JarManifest, mf}}
+	for i, k := range keys {
+		name := "META-INF/CERT"
+		if i > 0 {
+			name += fmt.Sprint(i + 1)
+		}
+		block, err := pkcs7Sign(sf.Bytes(), k)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, JarEntry{name + ".SF", sf.Bytes()}, JarEntry{name + ".RSA", block})
+	}
+	return out, nil
+}
+
+// jarSection returns a manifest section: the attribute lines, wrapped at 72 bytes as the JAR
+// specification requires, and the blank line that ends the section.
+func jarSection(lines ...string) []byte {
+	var b bytes.Buffer
+	for _, l := range lines {
+		for width := 70; len(l) > width; width = 69 {
+			b.WriteString(l[:width] + "\r\n ")
+			l = l[width:]
+		}
+		b.WriteString(l + "\r\n")
+	}
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+type pkcs7AlgorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue
+}
+
+type pkcs7IssuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type pkcs7SignerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     pkcs7IssuerAndSerial
+	DigestAlgorithm           pkcs7AlgorithmIdentifier
+	DigestEncryptionAlgorithm pkcs7AlgorithmIdentifier
+	EncryptedDigest           []byte
+}
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms []pkcs7AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      pkcs7ContentInfo
+	Certificates     asn1.RawValue
+	SignerInfos      []pkcs7SignerInfo `asn1:"set"`
+}
+
+type pkcs7 struct {
+	ContentType asn1.ObjectIdentifier
+	Content     pkcs7SignedData `asn1:"explicit,tag:0"`
+}
+
+// pkcs7Sign returns a detached PKCS#7 SignedData of data with the certificate of k, without
+// signed attributes, as a JAR signature block.
+func pkcs7Sign(data []byte, k *SigningCert) ([]byte, error) {
+	if k.Certificate == nil {
+		return nil, errors.New("signing certificate is not resolved")
+	}
+	sig, err := k.Sign(data, crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	null := asn1.RawValue{Tag: asn1.TagNull}
+	sha256Alg := pkcs7AlgorithmIdentifier{oidSHA256, null}
+	return asn1.Marshal(pkcs7{
+		ContentType: oidSignedData,
+		Content: pkcs7SignedData{
+			Version:          1,
+			DigestAlgorithms: []pkcs7AlgorithmIdentifier{sha256Alg},
+			ContentInfo:      pkcs7ContentInfo{oidData},
+			Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: k.Certificate.Raw},
+			SignerInfos: []pkcs7SignerInfo{{
+				Version:                   1,
+				IssuerAndSerialNumber:     pkcs7IssuerAndSerial{asn1.RawValue{FullBytes: k.Certificate.RawIssuer}, k.Certificate.SerialNumber},
+				DigestAlgorithm:           sha256Alg,
+				DigestEncryptionAlgorithm: pkcs7AlgorithmIdentifier{oidRSAEncryption, null},
+				EncryptedDigest:           sig,
+			}},
+		},
+	})
+}
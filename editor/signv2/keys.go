@@ -126,6 +126,10 @@ func (sc *SigningCert) Resolve() error {
 	if err != nil {
 		return err
 	}
+	if sc.CertPath == "" && sc.Certificate != nil {
+		// already resolved; returning early also keeps a shared SigningCert safe for concurrent use
+		return nil
+	}
 
 	// parse Certificate
 	var someBytes []byte
@@ -9,7 +9,6 @@ import (
 	"crypto/x509"
 	"encoding/binary"
 	"errors"
-	"log"
 )
 
 type Digest struct {
@@ -49,33 +48,31 @@ func ParseV2Block(block []byte) (*V2Block, error) {
 
 	v2 := &V2Block{}
 
-	// check the key/value pair block; we expect only one entry, w/ key 0x7109871a (meaning signature v2 specifically)
-	asv2Len, block := pop64(block)
-	if uint64(len(block)) != asv2Len {
-		log.Println("ParseV2Block", "unsupported: multiple ID/Value pair blocks at top level", asv2Len, len(block))
-		return nil, errors.New("unsupported: multiple ID/Value pair blocks at top level")
-		// So there are 3 reasons there might be multiple blocks here:
-		//
-		// 1. there is a second v2 signing block, which is probably an attack (i.e. attempt to break verification)
-		// 2. there is a v3 or later signing block, which doesn't exist at time of this writing and we can't cope with
-		//    (Android itself has to be able to deal with this, but we don't)
-		// 3. someone added a block with their own ID in here as a way to store some random data
-		//
-		// The spec says "ID-value pairs with unknown IDs should be ignored when interpreting the
-		// block", so only #1 should be fatal here, technically. But out of an abundance of caution we
-		// abort anyway. This can be fixed later if there's an actual need to; this should be fine for
-		// the forseeable future.
+	// find the v2 pair among the ID/value pairs of the signing block. Spec: "ID-value pairs with
+	// unknown IDs should be ignored when interpreting the block", but a second v2 pair is probably an
+	// attempt to break verification, so that is fatal
+	pairs, err := ParseSigningBlockPairs(block)
+	if err != nil {
+		return nil, err
 	}
-
-	// verify the block ID
-	size32, block = pop32(block)
-	if size32 != 0x7109871a {
+	block = nil
+	for _, p := range pairs {
+		if p.ID != V2BlockID {
+			continue
+		}
+		if block != nil {
+			return nil, errors.New("multiple Android v2 signature blocks")
+		}
+		block = p.Value
+	}
+	if block == nil {
 		return nil, errors.New("unsupported: not an Android v2 signature block")
-		// note that even when/if they add a v3 signing scheme, we still should error out here b/c we
-		// won't know how to handle it anyway
+	}
+	if len(block) < 4 {
+		return nil, errors.New("malformed signing block - short v2 block")
 	}
 
-	// we now know we have exactly 1 signature block, w/ ID 0x7109871a, of length size64 - 4
+	// we now know we have exactly 1 signature block, w/ ID 0x7109871a
 
 	// now extract out all the signer blocks
 	size32, block = pop32(block) // length of all signer blocks combined
@@ -502,26 +499,19 @@ func (v2 *V2Block) Sign(z *ApkSign, keys []*SigningCert) ([]byte, error) {
 	signers := concat(blocks...)
 	asv2 := push32(signers)
 
-	// asv2Value now contains the concatenation of all 'signers' blocks; this will be the value of the
-	// signing-block attr in the outermost ASv2 block wrapper
-
-	// add the key for the signing block
-	asv2 = push32(asv2) // add 4 bytes for the magic ID
-	binary.LittleEndian.PutUint32(asv2[:4], 0x7109871a)
-
-	// add the length prefix for the ID/value pair
-	asv2 = push64(asv2)
-
-	// create & write the final block
-	finalSize := len(asv2) + 8 + 16    // size is key/value portion + uint64 footer size + 16-byte footer magic string
-	final := make([]byte, finalSize+8) // need another uint64 to prepend another copy of size
-	binary.LittleEndian.PutUint64(final[:8], uint64(finalSize))
-	copy(final[8:], asv2)
-	binary.LittleEndian.PutUint64(final[8+len(asv2):], uint64(finalSize))
-	copy(final[len(final)-16:], []byte("APK Sig Block 42"))
+	// asv2 now contains the concatenation of all 'signers' blocks; this is the value of the v2 pair.
+	// The v2 digest does not cover the signing block, so unrelated pairs of an existing block (channel
+	// info etc.) stay valid and are kept after it; apksigner-style padding is regenerated
+	pairs := []BlockPair{{V2BlockID, asv2}}
+	for _, p := range z.pairs {
+		if preservedOnResign(p.ID) {
+			pairs = append(pairs, p)
+		}
+	}
+	final := MarshalSigningBlock(pairs)
 
 	// just a quick sanity check to make sure we generated a block that parses
-	_, er := ParseV2Block(asv2)
+	_, er := ParseV2Block(final[8 : len(final)-24])
 	if er != nil {
 		return nil, er
 	}
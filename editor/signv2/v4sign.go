@@ -0,0 +1,311 @@
+package signv2
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+)
+
+// Constants of the APK Signature Scheme v4 file format, as written by apksigner.
+//
+// See https://source.android.com/docs/security/features/apksigning/v4
+const (
+	V4Version       = 2
+	V4HashSHA256    = 1
+	V4Log2BlockSize = 12
+	verityBlockSize = 1 << V4Log2BlockSize
+)
+
+// V4Signature is the parsed content of an .idsig file. The signature covers the size of the APK, the
+// fs-verity root hash of the whole APK and the v2 digest of the signer with the same key, so it is
+// only valid next to the exact v2-signed APK it was generated from.
+type V4Signature struct {
+	Version       uint32
+	HashAlgorithm uint32
+	Log2BlockSize uint8
+	Salt          []byte
+	RootHash      []byte
+
+	ApkDigest            []byte
+	Certificate          []byte
+	AdditionalData       []byte
+	PublicKey            []byte
+	SignatureAlgorithmID uint32
+	Signature            []byte
+
+	// Tree is the fs-verity Merkle tree over the APK, top level first. It is optional in the file
+	// (apksigner --v4-no-merkle-tree), the platform regenerates it when missing.
+	Tree []byte
+}
+
+// MerkleTree returns the fs-verity Merkle tree of data with 4096-byte blocks, SHA-256 and no salt,
+// laid out top level first, each level padded to the block size, along with its root hash. This is
+// the same tree apksigner stores in the v4 signature.
+func MerkleTree(data []byte) (rootHash, tree []byte) {
+	var levels [][]byte
+	level := data
+	for {
+		level = hashBlocks(level)
+		levels = append(levels, level)
+		if len(level) <= verityBlockSize {
+			break
+		}
+	}
+	for i := len(levels) - 1; i >= 0; i-- {
+		tree = append(tree, levels[i]...)
+	}
+	root := sha256.Sum256(tree[:verityBlockSize])
+	return root[:], tree
+}
+
+// hashBlocks returns the SHA-256 hashes of each 4096-byte block of data, the last block zero
+// padded, concatenated and zero padded to a multiple of the block size.
+func hashBlocks(data []byte) []byte {
+	count := (len(data) + verityBlockSize - 1) / verityBlockSize
+	if count == 0 {
+		count = 1
+	}
+	size := (count*sha256.Size + verityBlockSize - 1) / verityBlockSize * verityBlockSize
+	out := make([]byte, size)
+	block := make([]byte, verityBlockSize)
+	for i := 0; i < count; i++ {
+		n := copy(block, data[min(i*verityBlockSize, len(data)):])
+		clear(block[n:])
+		sum := sha256.Sum256(block)
+		copy(out[i*sha256.Size:], sum[:])
+	}
+	return out
+}
+
+// SignV4 returns the v4 signature (.idsig file content) of the represented APK, which must already be
+// v2 signed with the first of keys. The v4 signature references the v2 digest of that signer, so the
+// APK must not be modified (or re-signed) afterwards.
+func (apkSign *ApkSign) SignV4(keys []*SigningCert) ([]byte, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+	sk := keys[0]
+	if err := sk.Resolve(); err != nil {
+		return nil, err
+	}
+	signer, err := apkSign.v2Signer(sk.Certificate.RawSubjectPublicKeyInfo)
+	if err != nil {
+		return nil, err
+	}
+	var algoID uint32
+	var hasher crypto.Hash
+	switch sk.Hash {
+	case SHA256:
+		algoID, hasher = 0x0103, crypto.SHA256
+	case SHA512:
+		algoID, hasher = 0x0104, crypto.SHA512
+	default:
+		return nil, errors.New("unsupported hash algorithm specified")
+	}
+	digest := signer.digest(algoID)
+	if digest == nil {
+		return nil, errors.New("v2 signature has no digest for the v4 signature algorithm")
+	}
+
+	v4 := &V4Signature{
+		Version:              V4Version,
+		HashAlgorithm:        V4HashSHA256,
+		Log2BlockSize:        V4Log2BlockSize,
+		ApkDigest:            digest,
+		Certificate:          sk.Certificate.Raw,
+		PublicKey:            sk.Certificate.RawSubjectPublicKeyInfo,
+		SignatureAlgorithmID: algoID,
+	}
+	v4.RootHash, v4.Tree = MerkleTree(apkSign.raw)
+	v4.Signature, err = sk.Sign(v4.signedData(apkSign.size), hasher)
+	if err != nil {
+		return nil, err
+	}
+	return v4.Marshal(), nil
+}
+
+// VerifyV4 returns a non-nil error if idsig is not a valid v4 signature of the represented APK: the
+// root hash (and the Merkle tree, if present) must match the APK, the APK digest must be the v2
+// digest of the signer with the same public key, and the signature must verify with that key.
+func (apkSign *ApkSign) VerifyV4(idsig []byte) error {
+	v4, err := ParseV4Signature(idsig)
+	if err != nil {
+		return err
+	}
+	if v4.Version != V4Version || v4.HashAlgorithm != V4HashSHA256 || v4.Log2BlockSize != V4Log2BlockSize {
+		return errors.New("unsupported v4 signature version or hashing parameters")
+	}
+	if len(v4.Salt) != 0 {
+		return errors.New("unsupported: salted v4 Merkle tree")
+	}
+	root, tree := MerkleTree(apkSign.raw)
+	if !bytes.Equal(root, v4.RootHash) {
+		return errors.New("v4 root hash mismatch")
+	}
+	if v4.Tree != nil && !bytes.Equal(tree, v4.Tree) {
+		return errors.New("v4 Merkle tree mismatch")
+	}
+
+	// the v4 signature is only valid together with the v2 signature it references
+	if err := apkSign.VerifyV2(); err != nil {
+		return err
+	}
+	signer, err := apkSign.v2Signer(v4.PublicKey)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(signer.digest(v4.SignatureAlgorithmID), v4.ApkDigest) {
+		return errors.New("v4 APK digest does not match the v2 digest")
+	}
+
+	cert, err := x509.ParseCertificate(v4.Certificate)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(cert.RawSubjectPublicKeyInfo, v4.PublicKey) {
+		return errors.New("v4 SubjectPublicKeyInfo mismatch")
+	}
+	pubkey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("unsupported signature algorithm (only RSA currently supported)")
+	}
+	signed := v4.signedData(apkSign.size)
+	switch v4.SignatureAlgorithmID {
+	case 0x0103:
+		hashed := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(pubkey, crypto.SHA256, hashed[:], v4.Signature)
+	case 0x0104:
+		hashed := sha512.Sum512(signed)
+		return rsa.VerifyPKCS1v15(pubkey, crypto.SHA512, hashed[:], v4.Signature)
+	}
+	return errors.New("unsupported signature/hash combination")
+}
+
+// v2Signer returns the signer of the v2 signature whose public key is publicKey.
+func (apkSign *ApkSign) v2Signer(publicKey []byte) (*Signer, error) {
+	if !apkSign.IsV2Signed {
+		return nil, errors.New("v4 signature requires a v2 signed APK")
+	}
+	v2, err := ParseV2Block(apkSign.rawASv2)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range v2.Signers {
+		if bytes.Equal(s.PublicKey, publicKey) {
+			return s, nil
+		}
+	}
+	return nil, errors.New("no v2 signer with the v4 signing key")
+}
+
+// digest returns the content digest of the signer computed for the signature algorithm algoID.
+func (s *Signer) digest(algoID uint32) []byte {
+	for _, d := range s.SignedData.Digests {
+		if d.AlgorithmID == algoID {
+			return d.Digest
+		}
+	}
+	return nil
+}
+
+// signedData returns the bytes covered by the v4 signature of an APK of the given size.
+func (v4 *V4Signature) signedData(size int64) []byte {
+	header := make([]byte, 4+8+4+1)
+	binary.LittleEndian.PutUint64(header[4:], uint64(size))
+	binary.LittleEndian.PutUint32(header[12:], v4.HashAlgorithm)
+	header[16] = v4.Log2BlockSize
+	data := concat(header, push32(v4.Salt), push32(v4.RootHash), push32(v4.ApkDigest),
+		push32(v4.Certificate), push32(v4.AdditionalData))
+	binary.LittleEndian.PutUint32(data, uint32(len(data)))
+	return data
+}
+
+// Marshal returns the .idsig file content: version, hashing info, signing info and the Merkle tree,
+// each block prefixed with its uint32 length.
+func (v4 *V4Signature) Marshal() []byte {
+	hashing := concat([]byte{0, 0, 0, 0, v4.Log2BlockSize}, push32(v4.Salt), push32(v4.RootHash))
+	binary.LittleEndian.PutUint32(hashing, v4.HashAlgorithm)
+
+	algo := make([]byte, 4)
+	binary.LittleEndian.PutUint32(algo, v4.SignatureAlgorithmID)
+	signing := concat(push32(v4.ApkDigest), push32(v4.Certificate), push32(v4.AdditionalData),
+		push32(v4.PublicKey), algo, push32(v4.Signature))
+
+	version := make([]byte, 4)
+	binary.LittleEndian.PutUint32(version, v4.Version)
+	out := concat(version, push32(hashing), push32(signing))
+	if v4.Tree != nil {
+		out = concat(out, push32(v4.Tree))
+	}
+	return out
+}
+
+// ParseV4Signature parses the content of an .idsig file.
+func ParseV4Signature(b []byte) (*V4Signature, error) {
+	v4 := &V4Signature{}
+	var err error
+	var hashing, signing []byte
+	if len(b) < 4 {
+		return nil, errors.New("malformed v4 signature - too short")
+	}
+	v4.Version, b = pop32(b)
+	if hashing, b, err = popSized(b); err != nil {
+		return nil, err
+	}
+	if signing, b, err = popSized(b); err != nil {
+		return nil, err
+	}
+	if len(b) > 0 {
+		if v4.Tree, b, err = popSized(b); err != nil {
+			return nil, err
+		}
+		if len(b) != 0 {
+			return nil, errors.New("malformed v4 signature - extra bytes")
+		}
+	}
+
+	if len(hashing) < 5 {
+		return nil, errors.New("malformed v4 hashing info")
+	}
+	v4.HashAlgorithm, hashing = pop32(hashing)
+	v4.Log2BlockSize, hashing = hashing[0], hashing[1:]
+	if v4.Salt, hashing, err = popSized(hashing); err != nil {
+		return nil, err
+	}
+	if v4.RootHash, hashing, err = popSized(hashing); err != nil {
+		return nil, err
+	}
+
+	for _, field := range []*[]byte{&v4.ApkDigest, &v4.Certificate, &v4.AdditionalData, &v4.PublicKey} {
+		if *field, signing, err = popSized(signing); err != nil {
+			return nil, err
+		}
+	}
+	if len(signing) < 4 {
+		return nil, errors.New("malformed v4 signing info - missing algorithm")
+	}
+	v4.SignatureAlgorithmID, signing = pop32(signing)
+	if v4.Signature, signing, err = popSized(signing); err != nil {
+		return nil, err
+	}
+	// version 2 may be followed by signing info blocks of rotated keys, which we don't produce
+	return v4, nil
+}
+
+// popSized pops a uint32 length prefixed byte array off the input.
+func popSized(in []byte) ([]byte, []byte, error) {
+	if len(in) < 4 {
+		return nil, nil, errors.New("malformed v4 signature - missing length prefix")
+	}
+	size, in := pop32(in)
+	if uint64(size) > uint64(len(in)) {
+		return nil, nil, errors.New("malformed v4 signature - length longer than available bytes")
+	}
+	out, in := popN(in, int(size))
+	return out, in, nil
+}
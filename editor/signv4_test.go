@@ -0,0 +1,133 @@
+package editor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/pzx521521/apk-editor/editor/signv2"
+)
+
+func TestEditV4(t *testing.T) {
+	a := newTestEditor(t)
+	a.Url = "https://example.com"
+	a.V4 = true
+	apk := mustEdit(t, a)
+	if a.IDSig == nil {
+		t.Fatal("no idsig")
+	}
+	z, err := signv2.NewApkSign(apk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := z.VerifyV4(a.IDSig); err != nil {
+		t.Fatal(err)
+	}
+	v4, err := signv2.ParseV4Signature(a.IDSig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Merkle树: 每4096字节一个hash, 按层对齐到4096
+	if root, tree := signv2.MerkleTree(apk); !bytes.Equal(root, v4.RootHash) || !bytes.Equal(tree, v4.Tree) || len(tree)%4096 != 0 {
+		t.Error("tree mismatch")
+	}
+	// 修改apk后v4签名失效
+	tampered := bytes.Clone(apk)
+	tampered[100] ^= 1
+	if z, err = signv2.NewApkSign(tampered); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.VerifyV4(a.IDSig); err == nil {
+		t.Error("tampered apk verified")
+	}
+	// 重新签名后引用的v2摘要不同
+	a.Url = "https://example.org"
+	other := mustEdit(t, a)
+	if z, err = signv2.NewApkSign(other); err != nil {
+		t.Fatal(err)
+	}
+	v4.RootHash, v4.Tree = signv2.MerkleTree(other)
+	if err := z.VerifyV4(v4.Marshal()); err == nil {
+		t.Error("idsig of another apk verified")
+	}
+}
+
+func TestMerkleTree(t *testing.T) {
+	data := bytes.Repeat([]byte{1}, 5000)
+	root, tree := signv2.MerkleTree(data)
+	if len(tree) != 4096 {
+		t.Fatalf("tree size %d", len(tree))
+	}
+	block := make([]byte, 4096)
+	copy(block, data[4096:])
+	first, second := sha256.Sum256(data[:4096]), sha256.Sum256(block)
+	if !bytes.Equal(tree[:32], first[:]) || !bytes.Equal(tree[32:64], second[:]) || !bytes.Equal(tree[64:], make([]byte, 4096-64)) {
+		t.Error("leaf level mismatch")
+	}
+	if sum := sha256.Sum256(tree); !bytes.Equal(root, sum[:]) {
+		t.Error("root hash mismatch")
+	}
+	// 超过128个块时有两层, 上层在前
+	_, tree = signv2.MerkleTree(make([]byte, 129*4096))
+	if len(tree) != 4096+2*4096 {
+		t.Errorf("two level tree size %d", len(tree))
+	}
+}
+
+func TestParseV4Signature(t *testing.T) {
+	v4 := &signv2.V4Signature{
+		Version: 2, HashAlgorithm: 1, Log2BlockSize: 12,
+		Salt: []byte{}, RootHash: bytes.Repeat([]byte{1}, 32),
+		ApkDigest: bytes.Repeat([]byte{2}, 32), Certificate: []byte("cert"), AdditionalData: []byte{},
+		PublicKey: []byte("key"), SignatureAlgorithmID: 0x0103, Signature: []byte("sig"),
+		Tree: bytes.Repeat([]byte{3}, 4096),
+	}
+	full := v4.Marshal()
+	noTree := *v4
+	noTree.Tree = nil
+	// sized 返回带uint32长度前缀的b
+	sized := func(b ...byte) []byte {
+		return append(binary.LittleEndian.AppendUint32(nil, uint32(len(b))), b...)
+	}
+	version := binary.LittleEndian.AppendUint32(nil, 2)
+	hashing := append(binary.LittleEndian.AppendUint32(nil, 1), 12)
+	hashing = append(append(hashing, sized()...), sized(make([]byte, 32)...)...)
+	signing := append(append(append(sized(1), sized(2)...), sized()...), sized(3)...)
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	for _, tt := range []struct {
+		name string
+		b    []byte
+		err  string // 为空时应当解析成功并能还原
+	}{
+		{"with tree", full, ""},
+		{"without tree", noTree.Marshal(), ""},
+		{"empty", nil, "too short"},
+		{"version only", version, "missing length prefix"},
+		{"hashing beyond data", join(version, binary.LittleEndian.AppendUint32(nil, 100)), "longer than available"},
+		{"no signing info", join(version, sized(hashing...)), "missing length prefix"},
+		{"extra bytes", append(bytes.Clone(full), 0), "extra bytes"},
+		{"short hashing info", join(version, sized(1, 2, 3), sized(signing...)), "hashing info"},
+		{"hashing without root hash", join(version, sized(hashing[:5]...), sized(signing...)), "missing length prefix"},
+		{"signing without algorithm", join(version, sized(hashing...), sized(signing...)), "missing algorithm"},
+		{"signing without signature", join(version, sized(hashing...), sized(append(bytes.Clone(signing), 3, 1, 0, 0)...)), "missing length prefix"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := signv2.ParseV4Signature(tt.b)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("err %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Marshal(), tt.b) {
+				t.Error("marshal of the parsed signature differs")
+			}
+		})
+	}
+}
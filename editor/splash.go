@@ -0,0 +1,152 @@
+package editor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"path"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+	"github.com/pzx521521/apk-editor/editor/zip"
+)
+
+// Splash 启动画面, 在网页加载前显示背景色和居中的图片. Android 12 以上由系统的启动画面显示,
+// 图片作为圆形裁剪的图标, 应使用方形的图片
+type Splash struct {
+	// Image png/jpeg, 为空时只显示背景色
+	Image []byte `json:"image,omitempty"`
+	// Background 背景色 #RRGGBB, 为空时使用 Theme.Background 或白色
+	Background string `json:"background,omitempty"`
+	// MinDuration 至少显示的毫秒数, 写入 assets/webview.json 由模板读取
+	MinDuration int `json:"min_duration,omitempty"`
+}
+
+// 启动画面新增的资源名
+const (
+	SplashDrawable   = "splash"
+	SplashImage      = "splash_image"
+	SplashBackground = "splash_background"
+)
+
+// splashImageSize 启动画面图片所在的区域, 单位dp
+const splashImageSize = 192
+
+// splashDensities 启动画面图片生成的密度, 默认配置为mdpi
+var splashDensities = []uint16{res.DensityMedium, res.DensityHigh, res.DensityXHigh, res.DensityXXHigh, res.DensityXXXHigh}
+
+// splashContent 在 table 中添加启动画面的颜色, 图片和 layer-list, 把它们设为 x 中所有主题的窗口背景和
+// Android 12 的 windowSplashScreen* 属性, 返回新的图片和xml
+func (a *ApkEditor) splashContent(r *zip.Reader, x *res.XML, table *res.Table) ([]*MergeEntry, error) {
+	s := a.Splash
+	if s == nil {
+		return nil, nil
+	}
+	if s.MinDuration < 0 {
+		return nil, invalid(errors.New("splash: negative min duration"))
+	}
+	if table == nil {
+		return nil, fmt.Errorf("splash: template has no %s", RESOURCES_ARSC)
+	}
+	background := s.Background
+	if background == "" && a.Theme != nil {
+		background = a.Theme.Background
+	}
+	if background == "" {
+		background = "#FFFFFF"
+	}
+	color, ok := res.ParseColor(background)
+	if !ok {
+		return nil, invalid(fmt.Errorf("splash: background %q: want #RRGGBB or #AARRGGBB", background))
+	}
+	x, err := finalManifest(r, x)
+	if err != nil {
+		return nil, fmt.Errorf("splash: %w", err)
+	}
+	themes := manifestThemes(x)
+	if len(themes) == 0 {
+		return nil, errors.New("splash: manifest has no android:theme")
+	}
+	p := table.Package(0x7f)
+	if p == nil {
+		return nil, errors.New("splash: no app package in resources")
+	}
+	colorID, err := table.AddEntry(p, "color", SplashBackground, color)
+	if err != nil {
+		return nil, fmt.Errorf("splash: %w", err)
+	}
+	ref := func(id uint32) res.Value {
+		return res.Value{Type: res.TypeReference, Data: id}
+	}
+	items := map[uint32]res.Value{res.AttrWindowSplashScreenBackground: ref(colorID)}
+	layers := res.NewElement("layer-list")
+	layers.Namespaces = []res.Namespace{{Prefix: "android", URI: res.AndroidNS}}
+	layers.Children = append(layers.Children, res.NewElement("item",
+		res.ValueAttr(res.AndroidNS, "drawable", res.AttrDrawable, ref(colorID))))
+	var mergeEntries []*MergeEntry
+	if len(s.Image) > 0 {
+		img, _, err := image.Decode(bytes.NewReader(s.Image))
+		if err != nil {
+			return nil, invalid(fmt.Errorf("splash: %w", err))
+		}
+		if b := img.Bounds(); b.Dx() != b.Dy() {
+			a.Warnings = append(a.Warnings, "splash image is not square, Android 12+ crops it to a circle")
+		}
+		var imageID uint32
+		for _, density := range splashDensities {
+			var c res.Config
+			dir := "res/drawable"
+			if density != res.DensityMedium {
+				c.SetDensity(density)
+				dir += "-" + c.String()
+			}
+			file := path.Join(dir, SplashImage+".png")
+			buf := new(bytes.Buffer)
+			w, h := splashSize(img.Bounds(), density)
+			if err := png.Encode(buf, scaleImage(img, w, h)); err != nil {
+				return nil, err
+			}
+			v := res.Value{Type: res.TypeString, Data: table.Strings.Add(file)}
+			if density == res.DensityMedium {
+				imageID, err = table.AddEntry(p, "drawable", SplashImage, v)
+			} else {
+				err = table.SetValue(imageID, c, v)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("splash: %w", err)
+			}
+			mergeEntries = append(mergeEntries, &MergeEntry{file, buf.Bytes()})
+		}
+		items[res.AttrWindowSplashScreenAnimatedIcon] = ref(imageID)
+		layers.Children = append(layers.Children, res.NewElement("item",
+			res.ValueAttr(res.AndroidNS, "gravity", res.AttrGravity, res.Value{Type: res.TypeIntHex, Data: 0x11}), // center
+			res.ValueAttr(res.AndroidNS, "drawable", res.AttrDrawable, ref(imageID))))
+	}
+	file := "res/drawable/" + SplashDrawable + ".xml"
+	drawableID, err := table.AddEntry(p, "drawable", SplashDrawable, res.Value{Type: res.TypeString, Data: table.Strings.Add(file)})
+	if err != nil {
+		return nil, fmt.Errorf("splash: %w", err)
+	}
+	mergeEntries = append(mergeEntries, &MergeEntry{file, (&res.XML{Root: layers}).Marshal()})
+	items[res.AttrWindowBackground] = ref(drawableID)
+	for _, id := range themes {
+		for _, ce := range table.Entries(id) {
+			if ce.Entry.IsComplex() {
+				for name, v := range items {
+					ce.Entry.SetMap(name, v)
+				}
+			}
+		}
+	}
+	return mergeEntries, nil
+}
+
+// splashSize 返回图片在 density 下缩放到 splashImageSize 区域内的像素大小, 保持宽高比
+func splashSize(b image.Rectangle, density uint16) (int, int) {
+	box := splashImageSize * int(density) / res.DensityMedium
+	if b.Dx() >= b.Dy() {
+		return box, max(1, box*b.Dy()/b.Dx())
+	}
+	return max(1, box*b.Dx()/b.Dy()), box
+}
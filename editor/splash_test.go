@@ -0,0 +1,81 @@
+package editor
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+	"github.com/pzx521521/apk-editor/editor/zip"
+)
+
+func TestEditSplash(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, image.NewNRGBA(image.Rect(0, 0, 300, 200))); err != nil {
+		t.Fatal(err)
+	}
+	a := newTestEditor(t)
+	a.Url = "https://example.com"
+	a.Splash = &Splash{Image: buf.Bytes(), Background: "#123456", MinDuration: 1500}
+	out := mustEdit(t, a)
+	if len(a.Warnings) != 1 {
+		t.Errorf("warnings %v", a.Warnings)
+	}
+	r, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := readEntry(r, "assets/webview.json")
+	if err != nil || !strings.Contains(string(b), `"splash_min_duration":1500`) {
+		t.Errorf("webview.json %s %v", b, err)
+	}
+	_, table, err := readTable(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imageID, _ := table.Find("drawable/" + SplashImage)
+	sizes := map[string]image.Point{}
+	for _, ce := range table.Entries(imageID) {
+		file, _ := table.String(ce.Entry.Value)
+		b, err := readEntry(r, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := png.DecodeConfig(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes[ce.Config.String()] = image.Pt(cfg.Width, cfg.Height)
+	}
+	if len(sizes) != 5 || sizes["default"] != image.Pt(192, 128) || sizes["xxhdpi"] != image.Pt(576, 384) {
+		t.Errorf("image sizes %v", sizes)
+	}
+	text, err := DumpXML(bytes.NewReader(out), int64(len(out)), "res/drawable/"+SplashDrawable+".xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`android:drawable="@color/splash_background"`, `android:gravity="0x11"`, `android:drawable="@drawable/splash_image"`} {
+		if !strings.Contains(string(text), want) {
+			t.Errorf("missing %s in\n%s", want, text)
+		}
+	}
+	drawableID, _ := table.Find("drawable/" + SplashDrawable)
+	colorID, _ := table.Find("color/" + SplashBackground)
+	if v := table.Resolve(res.Value{Type: res.TypeReference, Data: colorID}); v.Data != 0xFF123456 {
+		t.Errorf("splash background %v", v)
+	}
+	theme, _ := table.Find("style/Theme.WebviewDemo")
+	for _, ce := range table.Entries(theme) {
+		for name, id := range map[uint32]uint32{
+			res.AttrWindowBackground:               drawableID,
+			res.AttrWindowSplashScreenBackground:   colorID,
+			res.AttrWindowSplashScreenAnimatedIcon: imageID,
+		} {
+			if v, _ := ce.Entry.MapValue(name); v != (res.Value{Type: res.TypeReference, Data: id}) {
+				t.Errorf("%s 0x%08x = %v", ce.Config, name, v)
+			}
+		}
+	}
+}
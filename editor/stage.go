@@ -0,0 +1,47 @@
+package editor
+
+// ApkEditor.Edit 和 EditBundle 的各个阶段, 通过 ApkEditor.OnStage 和 StageError 报告
+const (
+	StageMerge     = "merge"     // 读取模板并合并网页内容
+	StageManifest  = "manifest"  // 修改 AndroidManifest.xml
+	StageResources = "resources" // 修改 resources.arsc 和图片等资源
+	StageFinalize  = "finalize"  // 写入zip的中央目录
+	StageAlign     = "align"     // 检查不压缩文件的对齐, 模板没有对齐时重写apk
+	StageSign      = "sign"      // v2签名
+	StageBundle    = "bundle"    // EditBundle 转换为aab并JAR签名, 代替 StageSign
+)
+
+// StageError 记录Edit失败时所处的阶段
+type StageError struct {
+	Stage string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return e.Stage + ": " + e.Err.Error()
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError 表示 ApkEditor 的设置不合法或与模板不兼容, 修改设置后才能成功, 与模板损坏等错误区分
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// invalid 把 err 包装为 ValidationError, err 为nil时返回nil
+func invalid(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ValidationError{err}
+}
@@ -0,0 +1,41 @@
+package editor
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEditValidationError(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		stage string
+		edit  func(a *ApkEditor)
+	}{
+		{"no content", StageMerge, func(a *ApkEditor) { a.Url = "" }},
+		{"remove required", StageMerge, func(a *ApkEditor) { a.Remove = []string{RESOURCES_ARSC} }},
+		{"manifest xml", StageManifest, func(a *ApkEditor) { a.ManifestXML = []byte("<application/>") }},
+		{"deep link activity", StageManifest, func(a *ApkEditor) {
+			a.Manifest = &Manifest{DeepLinks: []DeepLink{{Scheme: "myapp", Activity: ".Missing"}}}
+		}},
+		{"label locale", StageManifest, func(a *ApkEditor) { a.Manifest = &Manifest{Labels: map[string]string{"not a locale": "x"}} }},
+		{"icon", StageResources, func(a *ApkEditor) { a.Icon = []byte("not an image") }},
+		{"theme color", StageResources, func(a *ApkEditor) { a.Theme = &ThemeColors{Primary: "blue"} }},
+		{"splash duration", StageResources, func(a *ApkEditor) { a.Splash = &Splash{MinDuration: -1} }},
+		{"splash background", StageResources, func(a *ApkEditor) { a.Splash = &Splash{Background: "white"} }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestEditor(t)
+			a.Url = "https://example.com"
+			tt.edit(a)
+			_, err := a.Edit()
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("err %v is not a ValidationError", err)
+			}
+			var stageErr *StageError
+			if !errors.As(err, &stageErr) || stageErr.Stage != tt.stage {
+				t.Errorf("err %v, want stage %s", err, tt.stage)
+			}
+		})
+	}
+}
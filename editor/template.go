@@ -0,0 +1,77 @@
+package editor
+
+import (
+	"bytes"
+	"crypto/x509"
+
+	"github.com/pzx521521/apk-editor/editor/signv2"
+	"github.com/pzx521521/apk-editor/editor/zip"
+)
+
+// Template 是解析好的模板apk和签名密钥. 批量构建时所有 ApkEditor 共用一个 Template,
+// 避免每个变体都重新解析zip和密钥. Template 创建后只读, 可以并发使用
+type Template struct {
+	apkRaw []byte
+	reader *zip.Reader
+	keys   []*signv2.SigningCert
+	// descriptor 模板中 assets/apk-editor.json 的内容, 没有时为nil
+	descriptor *Descriptor
+}
+
+// NewTemplate 解析模板apk, 并加载PEM格式的RSA私钥和证书.
+// 模板中有 assets/apk-editor.json 时按其中描述的布局编辑
+func NewTemplate(apk, keyBytes, certBytes []byte) (*Template, error) {
+	keys, err := signingKeys(keyBytes, certBytes)
+	if err != nil {
+		return nil, err
+	}
+	return newTemplate(apk, keys)
+}
+
+// signingKeys 加载PEM格式的RSA私钥和证书
+func signingKeys(keyBytes, certBytes []byte) ([]*signv2.SigningCert, error) {
+	keys := []*signv2.SigningCert{
+		{SigningKey: signv2.SigningKey{
+			KeyBytes: keyBytes,
+			Type:     signv2.RSA,
+			Hash:     signv2.SHA256,
+		},
+			CertBytes: certBytes,
+		},
+	}
+	for _, k := range keys {
+		if err := k.Resolve(); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// newTemplate 解析模板apk, 使用已加载的签名密钥
+func newTemplate(apk []byte, keys []*signv2.SigningCert) (*Template, error) {
+	r, err := zip.NewReader(bytes.NewReader(apk), int64(len(apk)))
+	if err != nil {
+		return nil, err
+	}
+	t := &Template{apkRaw: apk, reader: r, keys: keys}
+	if b, err := readEntry(r, DESCRIPTOR); err == nil {
+		if t.descriptor, err = ParseDescriptor(b); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// NewEditor 返回一个使用该模板的 ApkEditor
+func (t *Template) NewEditor() *ApkEditor {
+	return &ApkEditor{template: t}
+}
+
+// AssetLinks 返回包名为 pkg, 用模板密钥签名的应用的 assetlinks.json
+func (t *Template) AssetLinks(pkg string) ([]byte, error) {
+	var certs []*x509.Certificate
+	for _, k := range t.keys {
+		certs = append(certs, k.Certificate)
+	}
+	return AssetLinks(pkg, certs...)
+}
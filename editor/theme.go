@@ -0,0 +1,157 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+	"github.com/pzx521521/apk-editor/editor/zip"
+)
+
+// ThemeColors 修改 application 和 activity 的主题中的颜色, 网页加载前的启动窗口和状态栏使用这些颜色.
+// 颜色为 #RRGGBB 或 #AARRGGBB, 为空时不修改. 夜间模式没有设置时 Primary 和 PrimaryDark 同样用于夜间主题,
+// 背景不变
+type ThemeColors struct {
+	// Primary 主色, 对应 colorPrimary
+	Primary string `json:"primary,omitempty"`
+	// PrimaryDark 状态栏颜色, 对应 colorPrimaryVariant/colorPrimaryDark 和 android:statusBarColor
+	PrimaryDark string `json:"primary_dark,omitempty"`
+	// Background 窗口背景, 对应 android:windowBackground 和 android:colorBackground
+	Background       string `json:"background,omitempty"`
+	NightPrimary     string `json:"night_primary,omitempty"`
+	NightPrimaryDark string `json:"night_primary_dark,omitempty"`
+	NightBackground  string `json:"night_background,omitempty"`
+}
+
+// themeAttrs 返回颜色对应的主题属性. 模板有 appcompat/material 的 attr/colorPrimary 等属性时使用它们,
+// 否则使用 android: 的属性
+func themeAttrs(table *res.Table) (primary, primaryDark, background []uint32) {
+	find := func(fallback uint32, names ...string) []uint32 {
+		var ids []uint32
+		for _, name := range names {
+			if id, ok := table.Find("attr/" + name); ok {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			ids = append(ids, fallback)
+		}
+		return ids
+	}
+	primary = find(res.AttrColorPrimary, "colorPrimary")
+	primaryDark = append(find(res.AttrColorPrimaryDark, "colorPrimaryVariant", "colorPrimaryDark"), res.AttrStatusBarColor)
+	background = []uint32{res.AttrWindowBackground, res.AttrColorBackground}
+	return
+}
+
+// themeItems 解析颜色, 返回白天和夜间主题中要修改的属性和值
+func (c *ThemeColors) themeItems(table *res.Table) (day, night map[uint32]res.Value, err error) {
+	primary, primaryDark, background := themeAttrs(table)
+	day, night = map[uint32]res.Value{}, map[uint32]res.Value{}
+	for _, f := range []struct {
+		name  string
+		color string
+		attrs []uint32
+		items map[uint32]res.Value
+	}{
+		{"primary", c.Primary, primary, day},
+		{"primaryDark", c.PrimaryDark, primaryDark, day},
+		{"background", c.Background, background, day},
+		{"primary", c.Primary, primary, night},
+		{"primaryDark", c.PrimaryDark, primaryDark, night},
+		{"nightPrimary", c.NightPrimary, primary, night},
+		{"nightPrimaryDark", c.NightPrimaryDark, primaryDark, night},
+		{"nightBackground", c.NightBackground, background, night},
+	} {
+		if f.color == "" {
+			continue
+		}
+		v, ok := res.ParseColor(f.color)
+		if !ok {
+			return nil, nil, invalid(fmt.Errorf("theme %s %q: want #RRGGBB or #AARRGGBB", f.name, f.color))
+		}
+		for _, id := range f.attrs {
+			f.items[id] = v
+		}
+	}
+	return day, night, nil
+}
+
+// applyTheme 把 a.Theme 的颜色写入 x 中 application 和各 activity 使用的主题. 主题没有夜间配置而设置了夜间颜色时,
+// 复制默认配置作为夜间主题
+func (a *ApkEditor) applyTheme(r *zip.Reader, x *res.XML, table *res.Table) error {
+	c := a.Theme
+	if c == nil {
+		return nil
+	}
+	if table == nil {
+		return fmt.Errorf("theme: template has no %s", RESOURCES_ARSC)
+	}
+	day, night, err := c.themeItems(table)
+	if err != nil {
+		return err
+	}
+	if x, err = finalManifest(r, x); err != nil {
+		return fmt.Errorf("theme: %w", err)
+	}
+	themes := manifestThemes(x)
+	if len(themes) == 0 {
+		return errors.New("theme: manifest has no android:theme")
+	}
+	explicitNight := c.NightPrimary != "" || c.NightPrimaryDark != "" || c.NightBackground != ""
+	for _, id := range themes {
+		entries := table.Entries(id)
+		var def *res.Entry
+		hasNight := false
+		for _, ce := range entries {
+			if ce.Entry.IsComplex() && ce.Config.IsDefault() {
+				def = ce.Entry
+			}
+			hasNight = hasNight || ce.Config.NightMode() == res.NightYes
+		}
+		if def == nil {
+			return fmt.Errorf("theme: 0x%08x is not a style", id)
+		}
+		// 夜间主题从修改前的默认配置复制, 不带白天的颜色
+		nightEntry := &res.Entry{Flags: def.Flags, Parent: def.Parent, Map: append([]res.MapEntry(nil), def.Map...)}
+		for _, ce := range entries {
+			if !ce.Entry.IsComplex() {
+				continue
+			}
+			items := day
+			if ce.Config.NightMode() == res.NightYes {
+				items = night
+			}
+			for name, v := range items {
+				ce.Entry.SetMap(name, v)
+			}
+		}
+		if explicitNight && !hasNight {
+			for name, v := range night {
+				nightEntry.SetMap(name, v)
+			}
+			var nightConfig res.Config
+			nightConfig.SetNightMode(res.NightYes)
+			if err := table.SetEntry(id, nightConfig, nightEntry); err != nil {
+				return fmt.Errorf("theme: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// manifestThemes 返回 application 和 activity 的 android:theme 引用的主题, 不重复
+func manifestThemes(x *res.XML) []uint32 {
+	var themes []uint32
+	seen := map[uint32]bool{}
+	x.Walk(func(e *res.Element) {
+		if e.Name != "application" && e.Name != "activity" {
+			return
+		}
+		if a := e.AndroidAttr(res.AttrTheme); a != nil && a.Value.Type == res.TypeReference && !seen[a.Value.Data] {
+			seen[a.Value.Data] = true
+			themes = append(themes, a.Value.Data)
+		}
+	})
+	return themes
+}
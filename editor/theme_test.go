@@ -0,0 +1,91 @@
+package editor
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pzx521521/apk-editor/editor/res"
+	"github.com/pzx521521/apk-editor/editor/zip"
+)
+
+func TestEditTheme(t *testing.T) {
+	a := newTestEditor(t)
+	a.Url = "https://example.com"
+	a.Theme = &ThemeColors{Primary: "red"}
+	if _, err := a.Edit(); err == nil {
+		t.Error("bad color accepted")
+	}
+	a.Theme = &ThemeColors{Primary: "#112233", PrimaryDark: "#001122", Background: "#ffffff", NightBackground: "#ff000000"}
+	out := mustEdit(t, a)
+	r, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, table, err := readTable(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := table.Find("style/Theme.WebviewDemo")
+	colorPrimary, _ := table.Find("attr/colorPrimary")
+	colorPrimaryVariant, _ := table.Find("attr/colorPrimaryVariant")
+	want := map[uint8]map[uint32]uint32{
+		res.NightAny: {colorPrimary: 0xFF112233, colorPrimaryVariant: 0xFF001122, res.AttrStatusBarColor: 0xFF001122, res.AttrWindowBackground: 0xFFFFFFFF},
+		// 夜间主题使用白天的主色, 背景为夜间颜色
+		res.NightYes: {colorPrimary: 0xFF112233, colorPrimaryVariant: 0xFF001122, res.AttrWindowBackground: 0xFF000000, res.AttrColorBackground: 0xFF000000},
+	}
+	entries := table.Entries(id)
+	if len(entries) != 2 {
+		t.Fatalf("%d configs", len(entries))
+	}
+	for _, ce := range entries {
+		for name, color := range want[ce.Config.NightMode()] {
+			if v, ok := ce.Entry.MapValue(name); !ok || v.Data != color {
+				t.Errorf("%s 0x%08x = %v, want %#x", ce.Config, name, v, color)
+			}
+		}
+		for i := 1; i < len(ce.Entry.Map); i++ {
+			if ce.Entry.Map[i-1].Name >= ce.Entry.Map[i].Name {
+				t.Errorf("%s: map not sorted", ce.Config)
+			}
+		}
+	}
+}
+
+func TestApplyThemeAddsNight(t *testing.T) {
+	table, err := res.ParseTable(templateEntry(t, RESOURCES_ARSC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := res.ParseXML(templateManifest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := table.Find("style/Theme.WebviewDemo")
+	// 去掉模板的夜间主题
+	s := table.Package(0x7f).Spec(uint8(id >> 16))
+	var types []*res.Type
+	for _, typ := range s.Types {
+		if typ.Config.NightMode() != res.NightYes {
+			types = append(types, typ)
+		}
+	}
+	s.Types = types
+	a := &ApkEditor{Theme: &ThemeColors{Background: "#ffffff", NightBackground: "#000000"}}
+	if err := a.applyTheme(nil, x, table); err != nil {
+		t.Fatal(err)
+	}
+	entries := table.Entries(id)
+	if len(entries) != 2 {
+		t.Fatalf("%d configs", len(entries))
+	}
+	night := entries[1]
+	if night.Config.NightMode() != res.NightYes || night.Entry.Parent != entries[0].Entry.Parent {
+		t.Fatalf("night theme %s %+v", night.Config, night.Entry)
+	}
+	if v, _ := night.Entry.MapValue(res.AttrWindowBackground); v.Data != 0xFF000000 {
+		t.Errorf("night windowBackground %v", v)
+	}
+	if v, _ := entries[0].Entry.MapValue(res.AttrWindowBackground); v.Data != 0xFFFFFFFF {
+		t.Errorf("windowBackground %v", v)
+	}
+}
@@ -0,0 +1,24 @@
+package editor
+
+import "encoding/json"
+
+// WebViewConfig 写入 assets/webview.json, 由模板中的 MainActivity 读取.
+// 为空的字段使用模板的默认行为
+type WebViewConfig struct {
+	UserAgent       string `json:"user_agent,omitempty"`
+	JavaScript      *bool  `json:"javascript,omitempty"`
+	Zoom            *bool  `json:"zoom,omitempty"`
+	SwipeRefresh    *bool  `json:"swipe_refresh,omitempty"`
+	Fullscreen      *bool  `json:"fullscreen,omitempty"`
+	IgnoreSslErrors *bool  `json:"ignore_ssl_errors,omitempty"`
+	// SplashMinDuration 启动画面至少显示的毫秒数, 由 Splash.MinDuration 设置
+	SplashMinDuration int `json:"splash_min_duration,omitempty"`
+}
+
+func (c *WebViewConfig) content(name string) (*MergeEntry, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return &MergeEntry{name, b}, nil
+}
@@ -192,6 +192,7 @@ func (z *Reader) AppendOffset() int64 {
 // When the returned writer is closed, any entries with names that
 // already exist in the archive will have been "replaced" by the new
 // entries, although the original data will still be there.
+// The archive comment of z is kept unless replaced with SetComment.
 func (z *Reader) Append(w io.Writer, skipManifest bool) *Writer {
 	return newAppendingWriter(z, w, skipManifest)
 }
@@ -12,6 +12,7 @@ import (
 	"hash/crc32"
 	"io"
 	"log"
+	"strings"
 )
 
 // Writer implements a zip file writer.
@@ -22,11 +23,20 @@ type Writer struct {
 	closed      bool
 	compressors map[uint16]Compressor
 	names       map[string]int // filename -> index in dir slice.
+	align       int            // alignment of the data of stored entries, 0 for none
+	comment     string
+	files       map[string]*File // live entries of the archive being appended to, by name
 }
 
 type header struct {
 	*FileHeader
-	offset uint64
+	offset  uint64
+	deleted bool // removed with Delete, possibly while its data is still being written
+}
+
+// live reports whether the entry is still part of the archive.
+func (h *header) live() bool {
+	return h.FileHeader != nil && !h.deleted
 }
 
 // NewWriter returns a new Writer writing a zip file to w.
@@ -45,29 +55,116 @@ func (w *Writer) SetOffset(n int64) {
 	w.cw.count = n
 }
 
+// SetAlignment makes the data of entries written with the Store method start at a multiple of n
+// bytes, the way zipalign does, by padding the extra field of their local headers. Android
+// requires this for resources.arsc and memory-mapped assets.
+func (w *Writer) SetAlignment(n int) {
+	w.align = n
+}
+
+// SetComment sets the end-of-central-directory comment field.
+// It can only be called before Close.
+func (w *Writer) SetComment(comment string) error {
+	if len(comment) > uint16max {
+		return errors.New("zip: Writer.Comment too long")
+	}
+	w.comment = comment
+	return nil
+}
+
 func newAppendingWriter(r *Reader, fw io.Writer, skipManifest bool) *Writer {
 	w := &Writer{
 		cw: &countWriter{
 			w:     bufio.NewWriter(fw),
 			count: r.AppendOffset(),
 		},
-		dir:   make([]*header, 0, len(r.File)*3/2),
-		names: make(map[string]int),
+		dir:     make([]*header, 0, len(r.File)*3/2),
+		names:   make(map[string]int),
+		comment: r.Comment,
+		files:   make(map[string]*File),
 	}
 	for _, f := range r.File {
 		if skipManifest && f.Name == ANDROIDMANIFEST {
 			continue
 		}
+		// copy the header so that several writers can append to the same Reader concurrently
+		fh := f.FileHeader
+		fh.Extra = fh.Extra[:len(fh.Extra):len(fh.Extra)]
 		h := &header{
-			FileHeader: &f.FileHeader,
+			FileHeader: &fh,
 			offset:     uint64(f.headerOffset),
 		}
 		w.dir = append(w.dir, h)
 		w.names[f.Name] = len(w.dir) - 1
+		w.files[f.Name] = f
 	}
 	return w
 }
 
+// Delete removes the entry name from the central directory, so that it is no longer part of the
+// archive; its data, if already written, stays in the file but is unreachable. It reports whether
+// there was such an entry.
+func (w *Writer) Delete(name string) bool {
+	deleted := false
+	for _, h := range w.dir {
+		if h.live() && h.Name == name {
+			h.deleted = true
+			deleted = true
+		}
+	}
+	delete(w.names, name)
+	delete(w.files, name)
+	return deleted
+}
+
+// DeletePrefix removes all entries whose names start with prefix, such as "lib/x86/", and
+// returns how many were removed. See Delete.
+func (w *Writer) DeletePrefix(prefix string) int {
+	n := 0
+	for _, h := range w.dir {
+		if h.live() && strings.HasPrefix(h.Name, prefix) {
+			delete(w.names, h.Name)
+			delete(w.files, h.Name)
+			h.deleted = true
+			n++
+		}
+	}
+	return n
+}
+
+// Rename renames the entry old of the archive being appended to as new. The name is also stored
+// in the local file header, and Android rejects entries whose local and central directory names
+// differ, so the compressed data is copied under a new local header and the old entry is deleted.
+// The copy is read from the original archive: entries written by w, including ones that replaced
+// an original entry of the same name, can't be renamed.
+func (w *Writer) Rename(old, new string) error {
+	f, ok := w.files[old]
+	if !ok {
+		if w.has(old) {
+			return errors.New("zip: rename of " + old + ", which was written by this Writer")
+		}
+		return errors.New("zip: rename of missing entry " + old)
+	}
+	if w.has(new) {
+		return errors.New("zip: rename target " + new + " already exists")
+	}
+	if err := w.copyAs(f, new); err != nil {
+		return err
+	}
+	w.Delete(old)
+	return nil
+}
+
+// has reports whether name is a live entry of the archive.
+func (w *Writer) has(name string) bool {
+	for _, h := range w.dir {
+		if h.live() && h.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // Flush flushes any buffered data to the underlying writer.
 // Calling Flush is not normally necessary; calling Close is sufficient.
 func (w *Writer) Flush() error {
@@ -92,9 +189,9 @@ func (w *Writer) Close() error {
 	start := w.cw.count
 	records := uint64(0)
 	for _, h := range w.dir {
-		if h.FileHeader == nil {
+		if !h.live() {
 			// This entry has been superceded by a later
-			// appended entry.
+			// appended entry, or deleted.
 			continue
 		}
 		records++
@@ -193,15 +290,18 @@ func (w *Writer) Close() error {
 	var buf [directoryEndLen]byte
 	b := writeBuf(buf[:])
 	b.uint32(uint32(directoryEndSignature))
-	b = b[4:]                 // skip over disk number and first disk number (2x uint16)
-	b.uint16(uint16(records)) // number of entries this disk
-	b.uint16(uint16(records)) // number of entries total
-	b.uint32(uint32(size))    // size of directory
-	b.uint32(uint32(offset))  // start of directory
-	// skipped size of comment (always zero)
+	b = b[4:]                        // skip over disk number and first disk number (2x uint16)
+	b.uint16(uint16(records))        // number of entries this disk
+	b.uint16(uint16(records))        // number of entries total
+	b.uint32(uint32(size))           // size of directory
+	b.uint32(uint32(offset))         // start of directory
+	b.uint16(uint16(len(w.comment))) // byte size of EOCD comment
 	if _, err := w.cw.Write(buf[:]); err != nil {
 		return err
 	}
+	if _, err := io.WriteString(w.cw, w.comment); err != nil {
+		return err
+	}
 
 	return w.cw.w.(*bufio.Writer).Flush()
 }
@@ -251,6 +351,7 @@ func (w *Writer) CreateHeader(fh *FileHeader) (io.Writer, error) {
 		// be added to the index.
 		w.dir[i].FileHeader = nil
 		delete(w.names, fh.Name)
+		delete(w.files, fh.Name)
 	}
 
 	fh.Flags |= 0x8 // we will write a data descriptor
@@ -281,7 +382,12 @@ func (w *Writer) CreateHeader(fh *FileHeader) (io.Writer, error) {
 	w.dir = append(w.dir, h)
 	fw.header = h
 
-	if err := writeHeader(w.cw, fh); err != nil {
+	pad := 0
+	if fh.Method == Store && w.align > 1 {
+		dataOffset := int(w.cw.count) + fileHeaderLen + len(fh.Name) + len(fh.Extra)
+		pad = (w.align - dataOffset%w.align) % w.align
+	}
+	if err := writeHeader(w.cw, fh, pad); err != nil {
 		return nil, err
 	}
 
@@ -315,6 +421,11 @@ func (w *Writer) PaddingHeader(fh *FileHeader) {
 // Copy copies the file f (obtained from a Reader) into w.
 // It copies the compressed form directly.
 func (w *Writer) Copy(f *File) error {
+	return w.copyAs(f, f.Name)
+}
+
+// copyAs copies the compressed form of f into w as an entry named name.
+func (w *Writer) copyAs(f *File, name string) error {
 	dataOffset, err := f.DataOffset()
 	if err != nil {
 		return err
@@ -323,15 +434,30 @@ func (w *Writer) Copy(f *File) error {
 		return err
 	}
 
+	if i, ok := w.names[name]; ok {
+		// like CreateHeader, replace an entry of the same name
+		w.dir[i].FileHeader = nil
+		delete(w.files, name)
+	}
 	fh := f.FileHeader
+	fh.Name = name
+	fh.Extra = fh.Extra[:len(fh.Extra):len(fh.Extra)]
 	h := &header{
 		FileHeader: &fh,
 		offset:     uint64(w.cw.count),
 	}
 	fh.Flags |= 0x8 // we will write a data descriptor
 	w.dir = append(w.dir, h)
-	//w.PaddingHeader(&fh)
-	if err := writeHeader(w.cw, &fh); err != nil {
+	if w.names != nil {
+		// when appending, a later CreateHeader or Copy of the same name replaces this entry
+		w.names[name] = len(w.dir) - 1
+	}
+	pad := 0
+	if fh.Method == Store && w.align > 1 {
+		dataOffset := int(w.cw.count) + fileHeaderLen + len(fh.Name) + len(fh.Extra)
+		pad = (w.align - dataOffset%w.align) % w.align
+	}
+	if err := writeHeader(w.cw, &fh, pad); err != nil {
 		return err
 	}
 
@@ -343,7 +469,8 @@ func (w *Writer) Copy(f *File) error {
 	return writeDesc(w.cw, &fh)
 }
 
-func writeHeader(w io.Writer, h *FileHeader) error {
+// writeHeader writes the local file header of h, followed by pad zero bytes of extra field.
+func writeHeader(w io.Writer, h *FileHeader, pad int) error {
 	var buf [fileHeaderLen]byte
 	b := writeBuf(buf[:])
 	b.uint32(uint32(fileHeaderSignature))
@@ -356,14 +483,17 @@ func writeHeader(w io.Writer, h *FileHeader) error {
 	b.uint32(0) // compressed size,
 	b.uint32(0) // and uncompressed size should be zero
 	b.uint16(uint16(len(h.Name)))
-	b.uint16(uint16(len(h.Extra)))
+	b.uint16(uint16(len(h.Extra) + pad))
 	if _, err := w.Write(buf[:]); err != nil {
 		return err
 	}
 	if _, err := io.WriteString(w, h.Name); err != nil {
 		return err
 	}
-	_, err := w.Write(h.Extra)
+	if _, err := w.Write(h.Extra); err != nil {
+		return err
+	}
+	_, err := w.Write(make([]byte, pad))
 	return err
 }
 
@@ -12,6 +12,7 @@ import (
 	"log"
 	"math/rand"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -219,6 +220,177 @@ func TestWriterOffset(t *testing.T) {
 	}
 }
 
+func TestWriterAlignment(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewWriter(buf)
+	w.SetAlignment(4)
+	stored := []WriteTest{
+		{Name: "a", Data: []byte("aligned"), Method: Store, Mode: 0666},
+		{Name: "bc.arsc", Data: []byte("also aligned"), Method: Store, Mode: 0666},
+		{Name: "deflated.txt", Data: []byte("not aligned"), Method: Deflate, Mode: 0666},
+		{Name: "def", Data: []byte("aligned again"), Method: Store, Mode: 0666},
+	}
+	for _, wt := range stored {
+		testCreate(t, w, &wt)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, wt := range stored {
+		testReadFile(t, r.File[i], &wt)
+		off, err := r.File[i].DataOffset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wt.Method == Store && off%4 != 0 {
+			t.Errorf("%s: data offset %d is not aligned", wt.Name, off)
+		}
+		if len(r.File[i].Extra) != 0 {
+			t.Errorf("%s: padding leaked into the central directory", wt.Name)
+		}
+	}
+}
+
+func TestWriterComment(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewWriter(buf)
+	if err := w.SetComment(string(make([]byte, uint16max+1))); err == nil {
+		t.Error("too long comment accepted")
+	}
+	if err := w.SetComment("build 42"); err != nil {
+		t.Fatal(err)
+	}
+	testCreate(t, w, &writeTests[0])
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zr.Comment != "build 42" {
+		t.Fatalf("comment %q", zr.Comment)
+	}
+
+	// Append keeps the comment, SetComment replaces it
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, comment := range []string{"build 42", "build 43"} {
+		abuf := bytes.NewBuffer(append([]byte(nil), buf.Bytes()[:r.AppendOffset()]...))
+		w = r.Append(abuf, false)
+		if comment != r.Comment {
+			w.SetComment(comment)
+		}
+		testCreate(t, w, &writeTests[2])
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		ar, err := NewReader(bytes.NewReader(abuf.Bytes()), int64(abuf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ar.Comment != comment || len(ar.File) != 2 {
+			t.Errorf("appended comment %q, %d files", ar.Comment, len(ar.File))
+		}
+	}
+}
+
+func TestAppendDeleteRename(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewWriter(buf)
+	for _, wt := range []WriteTest{
+		{Name: "a.txt", Data: []byte("a"), Method: Deflate, Mode: 0666},
+		{Name: "lib/x86/a.so", Data: []byte("x86 a"), Method: Store, Mode: 0666},
+		{Name: "lib/x86/b.so", Data: []byte("x86 b"), Method: Store, Mode: 0666},
+		{Name: "lib/arm64-v8a/a.so", Data: []byte("arm64 a"), Method: Store, Mode: 0666},
+		{Name: "old.bin", Data: []byte("stored data"), Method: Store, Mode: 0666},
+		{Name: "old.txt", Data: []byte("deflated data"), Method: Deflate, Mode: 0666},
+	} {
+		testCreate(t, w, &wt)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	abuf := bytes.NewBuffer(append([]byte(nil), buf.Bytes()[:r.AppendOffset()]...))
+	w = r.Append(abuf, false)
+	w.SetAlignment(4)
+	if !w.Delete("a.txt") || w.Delete("missing.txt") {
+		t.Error("Delete result")
+	}
+	if n := w.DeletePrefix("lib/x86/"); n != 2 {
+		t.Errorf("DeletePrefix removed %d entries", n)
+	}
+	testCreate(t, w, &WriteTest{Name: "new.txt", Data: []byte("new"), Method: Deflate, Mode: 0666})
+	testCreate(t, w, &WriteTest{Name: "tmp.txt", Data: []byte("tmp"), Method: Deflate, Mode: 0666})
+	if !w.Delete("tmp.txt") {
+		t.Error("new entry not deleted")
+	}
+	for _, names := range [][2]string{{"old.bin", "renamed/new.bin"}, {"old.txt", "renamed/new.txt"}} {
+		if err := w.Rename(names[0], names[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Rename("a.txt", "b.txt"); err == nil {
+		t.Error("renamed a deleted entry")
+	}
+	if err := w.Rename("lib/arm64-v8a/a.so", "new.txt"); err == nil {
+		t.Error("renamed over an existing entry")
+	}
+	// a renamed entry is replaced by a later entry of the same name, not duplicated
+	testCreate(t, w, &WriteTest{Name: "renamed/new.txt", Data: []byte("replaced"), Method: Deflate, Mode: 0666})
+	// an original entry overwritten by w can't be renamed, its new data is not in the original archive
+	testCreate(t, w, &WriteTest{Name: "lib/arm64-v8a/a.so", Data: []byte("arm64 new"), Method: Store, Mode: 0666})
+	if err := w.Rename("lib/arm64-v8a/a.so", "lib/arm64-v8a/b.so"); err == nil || !strings.Contains(err.Error(), "written by this Writer") {
+		t.Errorf("rename of an overwritten entry: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ar, err := NewReader(bytes.NewReader(abuf.Bytes()), int64(abuf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []WriteTest{
+		{Name: "new.txt", Data: []byte("new"), Method: Deflate, Mode: 0666},
+		{Name: "renamed/new.bin", Data: []byte("stored data"), Method: Store, Mode: 0666},
+		{Name: "renamed/new.txt", Data: []byte("replaced"), Method: Deflate, Mode: 0666},
+		{Name: "lib/arm64-v8a/a.so", Data: []byte("arm64 new"), Method: Store, Mode: 0666},
+	}
+	if len(ar.File) != len(want) {
+		t.Fatalf("%d entries, want %d", len(ar.File), len(want))
+	}
+	for i, wt := range want {
+		f := ar.File[i]
+		testReadFile(t, f, &wt)
+		// the local header carries the new name too
+		local := make([]byte, fileHeaderLen+len(wt.Name))
+		if _, err := ar.r.ReadAt(local, f.headerOffset); err != nil {
+			t.Fatal(err)
+		}
+		if name := string(local[fileHeaderLen:]); name != wt.Name {
+			t.Errorf("local header name %q, want %q", name, wt.Name)
+		}
+		if off, _ := f.DataOffset(); f.Method == Store && f.headerOffset >= r.AppendOffset() && off%4 != 0 {
+			t.Errorf("%s: data offset %d is not aligned", f.Name, off)
+		}
+	}
+	zr, err := zip.NewReader(bytes.NewReader(abuf.Bytes()), int64(abuf.Len()))
+	if err != nil || len(zr.File) != len(want) {
+		t.Errorf("archive/zip: %v", err)
+	}
+}
+
 func TestWriterFlush(t *testing.T) {
 	var buf bytes.Buffer
 	w := NewWriter(struct{ io.Writer }{&buf})
@@ -4,4 +4,8 @@ go 1.23.1
 
 replace github.com/pzx521521/apk-editor/editor => ./editor
 
-require github.com/pzx521521/apk-editor/editor v0.0.0-00010101000000-000000000000
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/pzx521521/apk-editor/editor v0.0.0-00010101000000-000000000000
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -2,11 +2,14 @@ package main
 
 import (
 	"embed"
+	"errors"
 	"flag"
+	"fmt"
 	"github.com/pzx521521/apk-editor/editor"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -19,11 +22,71 @@ func main() {
 			log.Fatalf("%v\n", err)
 		}
 	}
-	versionCode := flag.Int("versionCode", 111, "应用的版本代码 (111)")
-	versionName := flag.String("versionName", "111.111.111", "应用的版本名称 (111.111.111)")
-	label := flag.String("label", "WebViewDemo", "应用的标签 (WebViewDemo)")
-	packageName := flag.String("package", "com.parap.webview", "应用的包名 (com.parap.webview)")
-	output := flag.String("o", "webview.apk", "输出文件路径")
+	if len(os.Args) > 1 && os.Args[1] == "build" {
+		checkErr(runBuild(os.Args[2:]))
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "batch" {
+		checkErr(runBatch(os.Args[2:]))
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "manifest" {
+		checkErr(runManifest(os.Args[2:]))
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "channel" {
+		checkErr(runChannel(os.Args[2:]))
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "dump-xml" {
+		checkErr(runDumpXML(os.Args[2:]))
+		return
+	}
+	versionCode := flag.Int("versionCode", 0, "应用的版本代码, 为空时不修改")
+	versionName := flag.String("versionName", "", "应用的版本名称, 为空时不修改")
+	label := flag.String("label", "", "应用的标签, 为空时不修改")
+	packageName := flag.String("package", "", "应用的包名, 为空时不修改")
+	minSdk := flag.Int("minSdk", 0, "minSdkVersion, 为0时不修改")
+	targetSdk := flag.Int("targetSdk", 0, "targetSdkVersion, 为0时不修改")
+	output := flag.String("o", "webview.apk", "输出文件路径, 扩展名为 .aab 时生成 Android App Bundle")
+	template := flag.String("template", "", "模板apk, 为空时使用内置的模板; .apks, .xapk 或目录时修改整个 split apk 集合, 输出与模板格式相同")
+	manifestXML := flag.String("manifest", "", "文本格式的 AndroidManifest.xml, 编译后替换模板的manifest")
+	descriptor := flag.String("descriptor", "", "模板的描述文件(json), 为空时使用模板中的 assets/apk-editor.json")
+	var permissions, removePermissions stringList
+	flag.Var(&permissions, "permission", "添加的权限, 可重复, 如 CAMERA 或 WRITE_EXTERNAL_STORAGE:28")
+	flag.Var(&removePermissions, "remove-permission", "删除的权限, 可重复")
+	var deepLinks, appLinks stringList
+	flag.Var(&deepLinks, "deeplink", "由应用打开的链接, 可重复, 如 myapp://open 或 https://app.example.com/docs/*")
+	var debuggable, allowBackup, cleartext, resizeable optionalBool
+	flag.Var(&debuggable, "debuggable", "android:debuggable, 为true时可以通过 chrome://inspect 调试WebView, 不指定时不修改")
+	flag.Var(&allowBackup, "allowBackup", "android:allowBackup, 不指定时不修改")
+	flag.Var(&cleartext, "cleartext", "android:usesCleartextTraffic, 不指定时不修改")
+	flag.Var(&resizeable, "resizeable", "android:resizeableActivity, 不指定时不修改")
+	screenOrientation := flag.String("orientation", "", "所有activity的屏幕方向, 如 portrait, landscape, sensorLandscape, unspecified 删除, 为空时不修改")
+	softInputMode := flag.String("softInputMode", "", "所有activity的软键盘模式, 如 adjustResize|stateHidden, 为空时不修改")
+	var metaData, metaDataInt, metaDataBool, metaDataResource keyValues
+	var colors keyValues
+	flag.Var(&colors, "color", "主题颜色, 可重复, 如 primary=#112233, 可用 primary, primaryDark, background 和 nightPrimary, nightPrimaryDark, nightBackground")
+	splash := flag.String("splash", "", "启动画面居中的图片(png/jpeg)")
+	splashBackground := flag.String("splash-background", "", "启动画面的背景色, 如 #112233, 为空时使用 background 颜色或白色")
+	splashMinDuration := flag.Int("splash-min-duration", 0, "启动画面至少显示的毫秒数")
+	var labels keyValues
+	flag.Var(&labels, "label-locale", "各语言的应用名, 可重复, 如 zh-CN=演示, 写入 string/app_name")
+	var removeMetaData stringList
+	flag.Var(&metaData, "meta", "application 下的 meta-data, 可重复, 如 com.example.API_KEY=abc")
+	flag.Var(&metaDataInt, "meta-int", "值为整数的 meta-data, 可重复, 如 com.example.LEVEL=3")
+	flag.Var(&metaDataBool, "meta-bool", "值为布尔的 meta-data, 可重复, 如 com.example.ENABLED=true")
+	flag.Var(&metaDataResource, "meta-res", "值为资源的 meta-data, 可重复, 如 com.example.CONFIG=@xml/config")
+	flag.Var(&removeMetaData, "remove-meta", "删除的 meta-data, 可重复")
+	var cleartextDomains, pins stringList
+	flag.Var(&cleartextDomains, "cleartext-domain", "重新生成网络安全配置, 只允许这些域名(包含子域名)使用明文http, 可重复")
+	flag.Var(&pins, "pin", "重新生成网络安全配置并固定证书公钥, 可重复, 如 api.example.com=sha256,sha256@2027-01-01")
+	debugUserCA := flag.Bool("debug-user-ca", false, "重新生成网络安全配置, 只在 debuggable 时信任用户安装的CA")
+	comment := flag.String("comment", "", "apk(zip)的注释, 如构建号, 为空时保留模板的注释")
+	var remove stringList
+	flag.Var(&remove, "remove", "从模板中删除的文件, 以/结尾时删除整个目录, 可重复, 如 assets/default.txt, lib/x86/")
+	v4 := flag.Bool("v4", false, "同时生成v4签名 <输出文件>.idsig, 用于 adb install --incremental")
+	flag.Var(&appLinks, "applink", "需要验证(autoVerify)的https链接, 可重复, 会在apk旁生成 assetlinks.json")
 	// 解析命令行参数
 	flag.Parse()
 	args := flag.Args()
@@ -34,6 +97,11 @@ func main() {
 		log.Printf("or:    %s <your-dir>\n", app)
 		log.Printf("or:    %s <your-dir>/demo.zip\n", app)
 		log.Printf("or:    %s <your-dir>/demo.apk\n", app)
+		log.Printf("or:    %s build -c app.yaml\n", app)
+		log.Printf("or:    %s batch -c batch.yaml\n", app)
+		log.Printf("or:    %s manifest <your-dir>/demo.apk\n", app)
+		log.Printf("or:    %s dump-xml <your-dir>/demo.apk AndroidManifest.xml\n", app)
+		log.Printf("or:    %s channel -o dist <your-dir>/demo.apk huawei xiaomi\n", app)
 		return
 	}
 	inputPath := args[0]
@@ -42,7 +110,14 @@ func main() {
 	crt, err := embedFiles.ReadFile("release/signing.crt")
 	checkErr(err)
 	var apk []byte
-	if filepath.Ext(inputPath) == ".apk" {
+	var set *editor.ApkSet
+	if *template != "" && isApkSet(*template) {
+		set, err = readApkSet(*template)
+		checkErr(err)
+	} else if *template != "" {
+		apk, err = os.ReadFile(*template)
+		checkErr(err)
+	} else if filepath.Ext(inputPath) == ".apk" {
 		apk, err = os.ReadFile(inputPath)
 		checkErr(err)
 	} else {
@@ -52,38 +127,215 @@ func main() {
 	key, err := embedFiles.ReadFile("release/signing.key")
 	checkErr(err)
 	apkEditor := editor.NewApkEditor(apk, key, crt)
+	apkEditor.V4 = *v4
+	apkEditor.Comment = *comment
+	apkEditor.Remove = remove
+	if *descriptor != "" {
+		apkEditor.Descriptor, err = readDescriptor(*descriptor)
+		checkErr(err)
+	}
+	if *manifestXML != "" {
+		apkEditor.ManifestXML, err = os.ReadFile(*manifestXML)
+		checkErr(err)
+	}
+	if err := setInput(apkEditor, inputPath); err != nil {
+		log.Println(err)
+		return
+	}
+	if len(colors) > 0 {
+		theme, err := parseTheme(colors)
+		checkErr(err)
+		apkEditor.Theme = theme.colors()
+	}
+	if *splash != "" || *splashBackground != "" || *splashMinDuration != 0 {
+		apkEditor.Splash = &editor.Splash{Background: *splashBackground, MinDuration: *splashMinDuration}
+		if *splash != "" {
+			apkEditor.Splash.Image, err = os.ReadFile(*splash)
+			checkErr(err)
+		}
+	}
+	if len(cleartextDomains) > 0 || len(pins) > 0 || *debugUserCA {
+		n := &NetworkSecurityConfig{CleartextDomains: cleartextDomains, Pins: pins, DebugUserCAs: *debugUserCA}
+		apkEditor.NetworkSecurity, err = n.config()
+		checkErr(err)
+	}
+	metaInt, err := parseMap(metaDataInt, func(s string) (int32, error) {
+		i, err := strconv.ParseInt(s, 0, 32)
+		return int32(i), err
+	})
+	checkErr(err)
+	metaBool, err := parseMap(metaDataBool, strconv.ParseBool)
+	checkErr(err)
+	apkEditor.Manifest, err = ManifestConfig{
+		VersionCode:          uint32(*versionCode),
+		VersionName:          *versionName,
+		Label:                *label,
+		Labels:               labels,
+		Package:              *packageName,
+		Permissions:          permissions,
+		RemovePermissions:    removePermissions,
+		MinSdkVersion:        *minSdk,
+		TargetSdkVersion:     *targetSdk,
+		DeepLinks:            deepLinks,
+		AppLinks:             appLinks,
+		Debuggable:           debuggable.value,
+		AllowBackup:          allowBackup.value,
+		UsesCleartextTraffic: cleartext.value,
+		ResizeableActivity:   resizeable.value,
+		ScreenOrientation:    *screenOrientation,
+		WindowSoftInputMode:  *softInputMode,
+		MetaData:             metaData,
+		MetaDataInt:          metaInt,
+		MetaDataBool:         metaBool,
+		MetaDataResource:     metaDataResource,
+		RemoveMetaData:       removeMetaData,
+	}.manifest()
+	checkErr(err)
+	checkErr(save(apkEditor, set, abs))
+}
+
+// editOutput 按输出文件的扩展名生成apk, 或上传 Google Play 用的aab
+func editOutput(apkEditor *editor.ApkEditor, output string) ([]byte, error) {
+	if strings.EqualFold(filepath.Ext(output), ".aab") {
+		return apkEditor.EditBundle()
+	}
+	return apkEditor.Edit()
+}
+
+// setInput 根据输入的类型设置要显示的网页: 网址, 目录, zip 或 html 文件
+func setInput(apkEditor *editor.ApkEditor, inputPath string) error {
 	stat, err := os.Stat(inputPath)
 	if os.IsNotExist(err) || stat == nil {
 		if strings.HasPrefix(inputPath, "http") {
 			apkEditor.Url = inputPath
-		} else {
-			log.Println("file '" + inputPath + "' does not exist")
-			return
+			return nil
 		}
+		return errors.New("file '" + inputPath + "' does not exist")
+	}
+	if stat.IsDir() {
+		apkEditor.Url = inputPath
+		return nil
+	}
+	file, err := os.ReadFile(inputPath)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(inputPath, ".zip") {
+		apkEditor.HtmlZip = file
 	} else {
-		if stat.IsDir() {
-			apkEditor.Url = inputPath
-		} else {
-			file, err := os.ReadFile(inputPath)
-			checkErr(err)
-			if strings.HasSuffix(inputPath, ".zip") {
-				apkEditor.HtmlZip = file
-			} else {
-				apkEditor.IndexHtml = file
-			}
-		}
+		apkEditor.IndexHtml = file
+	}
+	return nil
+}
+
+// logManifestChanges 输出对 AndroidManifest.xml 的实际修改和需要注意的问题
+func logManifestChanges(apkEditor *editor.ApkEditor) {
+	for _, c := range apkEditor.ManifestChanges {
+		log.Printf("manifest %s\n", c)
+	}
+	for _, w := range apkEditor.Warnings {
+		log.Printf("warning: %s\n", w)
+	}
+}
+
+// writeAssetLinks 有 App Link 时把 assetlinks.json 写到 apk 旁边, 文件名为 <apk名>.assetlinks.json
+func writeAssetLinks(apkEditor *editor.ApkEditor, apkPath string) error {
+	if apkEditor.AssetLinks == nil {
+		return nil
+	}
+	p := strings.TrimSuffix(apkPath, filepath.Ext(apkPath)) + ".assetlinks.json"
+	if err := os.WriteFile(p, apkEditor.AssetLinks, 0644); err != nil {
+		return err
+	}
+	log.Printf("assetlinks.json save at:%s, upload it to https://<host>/.well-known/assetlinks.json\n", p)
+	return nil
+}
+
+// writeIDSig 生成了v4签名时写到 apk 旁边, 文件名为 <apk文件名>.idsig, 与 adb install --incremental 查找的位置相同
+func writeIDSig(apkEditor *editor.ApkEditor, apkPath string) error {
+	if apkEditor.IDSig == nil {
+		return nil
+	}
+	p := apkPath + ".idsig"
+	if err := os.WriteFile(p, apkEditor.IDSig, 0644); err != nil {
+		return err
+	}
+	log.Printf("v4 signature save at:%s\n", p)
+	return nil
+}
+
+// stringList 是可以重复的字符串参数
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// keyValues 是可以重复的 key=value 参数
+type keyValues map[string]string
+
+func (kv *keyValues) String() string {
+	var s []string
+	for k, v := range *kv {
+		s = append(s, k+"="+v)
 	}
-	if len(*versionName+*label+*packageName) > 0 || *versionCode > 0 {
-		apkEditor.Manifest = &editor.Manifest{
-			VersionCode: uint32(*versionCode),
-			VersionName: *versionName,
-			Label:       *label,
-			Package:     *packageName,
+	return strings.Join(s, ",")
+}
+
+func (kv *keyValues) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("%q: want key=value", s)
+	}
+	if *kv == nil {
+		*kv = keyValues{}
+	}
+	(*kv)[k] = v
+	return nil
+}
+
+// parseMap 用 parse 转换 kv 中的值
+func parseMap[V any](kv keyValues, parse func(string) (V, error)) (map[string]V, error) {
+	if kv == nil {
+		return nil, nil
+	}
+	m := make(map[string]V, len(kv))
+	for k, s := range kv {
+		v, err := parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s=%s: %w", k, s, err)
 		}
+		m[k] = v
 	}
-	edit, err := apkEditor.Edit()
-	checkErr(err)
-	err = os.WriteFile(abs, edit, 0644)
-	checkErr(err)
-	log.Printf("success save at:%s\n", abs)
+	return m, nil
+}
+
+// optionalBool 是没有指定时为nil的布尔参数, -debuggable 等于 -debuggable=true
+type optionalBool struct {
+	value *bool
+}
+
+func (b *optionalBool) String() string {
+	if b.value == nil {
+		return ""
+	}
+	return strconv.FormatBool(*b.value)
+}
+
+func (b *optionalBool) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	b.value = &v
+	return nil
+}
+
+func (b *optionalBool) IsBoolFlag() bool {
+	return true
 }
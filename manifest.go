@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/pzx521521/apk-editor/editor"
+)
+
+// runManifest apkEditor manifest app.apk, 以json格式输出apk的manifest信息
+func runManifest(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: apkEditor manifest <apk>")
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	m, err := editor.ReadManifest(f, stat.Size())
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// runDumpXML apkEditor dump-xml app.apk res/layout/main.xml, 把apk中的二进制xml输出为文本
+func runDumpXML(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: apkEditor dump-xml <apk> <entry>")
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	text, err := editor.DumpXML(f, stat.Size(), args[1])
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(text)
+	return err
+}